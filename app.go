@@ -4,30 +4,209 @@ import (
 	"context"
 	"enodia/internal/apps"
 	"enodia/internal/firewall"
+	"enodia/internal/policy"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // App struct holds application state
 type App struct {
-	ctx           context.Context
-	fw            *firewall.Manager
-	installedApps []apps.InstalledApp
+	ctx        context.Context
+	fw         *firewall.Manager
+	owners     *policy.RuleOwners
+	folders    *policy.FolderWatcher
+	tracker    *policy.Tracker
+	publishers *policy.PublisherPolicy
+	autoBlock  *policy.AutoBlock
+	tagBlocks  *policy.TagPolicy
+	kinds      *apps.KindOverrides
+	tags       *apps.Tags
+	filters    *apps.Filters
+	portable   *apps.PortableRoots
+	sources    *apps.SourceSettings
+	catalog    *apps.Catalog
+	changes    *apps.ChangeFeed
+
+	mu              sync.RWMutex
+	hiddenApps      []apps.HiddenApp
+	sourceReports   []apps.SourceReport
+	cancelDiscovery context.CancelFunc
 }
 
 // NewApp creates a new App instance
 func NewApp() *App {
-	return &App{}
+	return &App{catalog: apps.NewCatalog()}
 }
 
 // startup is called when the app launches
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 	a.fw = firewall.NewManager()
-	a.installedApps = apps.DiscoverApps()
+	a.owners = policy.LoadRuleOwners()
+	a.folders = policy.NewFolderWatcher(a.fw, a.owners)
+	a.tracker = policy.NewTracker(a.fw, a.owners)
+	a.publishers = policy.NewPublisherPolicy(a.fw, a.owners)
+	a.autoBlock = policy.NewAutoBlock(a.fw, a.owners)
+	a.tagBlocks = policy.NewTagPolicy(a.fw, a.owners)
+	a.kinds = apps.LoadKindOverrides()
+	a.tags = apps.LoadTags()
+	a.filters = apps.LoadFilters()
+	a.portable = apps.LoadPortableRoots()
+	a.sources = apps.LoadSourceSettings()
+	a.changes = apps.LoadChangeFeed()
+	a.adoptRules()
+
+	// Discover in the background so the window opens right away; the
+	// frontend streams apps in through discovery events
+	go a.discover()
+}
+
+// adoptRules hands the rules that no block holds to the user the first
+// time rule owners are recorded, so policies leave them alone
+func (a *App) adoptRules() {
+	blocked, err := a.fw.GetBlockedApps()
+	if err != nil {
+		log.Printf("[Enodia] Warning: Could not list blocked apps: %v", err)
+		return
+	}
+	paths := make([]string, 0, len(blocked))
+	for _, b := range blocked {
+		paths = append(paths, b.AppPath)
+	}
+	a.owners.AdoptUnowned(paths)
 }
 
 // shutdown is called when the app closes
 func (a *App) shutdown(ctx context.Context) {
+	a.mu.Lock()
+	if a.cancelDiscovery != nil {
+		a.cancelDiscovery()
+	}
+	a.mu.Unlock()
+
+	if a.folders != nil {
+		a.folders.Close()
+	}
 	if a.fw != nil {
 		a.fw.Close()
 	}
 }
+
+// discover runs app discovery and emits discovery:progress and
+// discovery:app while it runs, and discovery:report with how each source
+// did and discovery:done when it finishes. Installed, updated and removed
+// apps are emitted as apps:changed, and apps the auto-block queued for
+// review as review:queued.
+// A discovery that is still running is cancelled first.
+func (a *App) discover() ([]apps.InstalledApp, error) {
+	a.mu.Lock()
+	if a.cancelDiscovery != nil {
+		a.cancelDiscovery()
+	}
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.cancelDiscovery = cancel
+	a.mu.Unlock()
+	defer cancel()
+
+	opts := apps.Options{
+		Rules:         a.filters.Rules(),
+		PortableRoots: a.portable.Roots(),
+		Sources:       a.sources.Settings(),
+	}
+	result, err := apps.DiscoverApps(ctx, opts, apps.Observer{
+		OnProgress: func(p apps.Progress) {
+			runtime.EventsEmit(a.ctx, "discovery:progress", p)
+		},
+		OnApp: func(app apps.InstalledApp) {
+			runtime.EventsEmit(a.ctx, "discovery:app", app)
+		},
+	})
+	if err != nil {
+		log.Printf("[Enodia] Warning: Could not discover apps: %v", err)
+		return nil, err
+	}
+
+	found := result.Apps
+	a.kinds.Apply(found)
+	a.tags.Apply(found)
+	a.catalog.Replace(found)
+	a.mu.Lock()
+	a.hiddenApps = result.Hidden
+	a.sourceReports = result.Sources
+	a.mu.Unlock()
+
+	a.tracker.Migrate(found)
+	a.publishers.Apply(found)
+	_, packages := a.installedPackages()
+	a.tagBlocks.Apply(found, packages)
+
+	if changes := a.changes.Record(result, opts); len(changes) > 0 {
+		runtime.EventsEmit(a.ctx, "apps:changed", changes)
+		if queued := a.autoBlock.Apply(addedApps(found, changes)); len(queued) > 0 {
+			runtime.EventsEmit(a.ctx, "review:queued", queued)
+		}
+	}
+	runtime.EventsEmit(a.ctx, "discovery:report", result.Sources)
+	runtime.EventsEmit(a.ctx, "discovery:done", found)
+	return found, nil
+}
+
+// discoveredApps returns the apps found by the last completed discovery
+func (a *App) discoveredApps() []apps.InstalledApp {
+	return a.catalog.Apps()
+}
+
+// installedPackages returns the uppercased SIDs and the lowercased names
+// of the Store packages the last discovery found, listed or hidden. Both
+// are nil when the Store source did not run cleanly, as package rules
+// cannot be judged then.
+func (a *App) installedPackages() (sids, names map[string]bool) {
+	a.mu.RLock()
+	reports, hidden := a.sourceReports, a.hiddenApps
+	a.mu.RUnlock()
+
+	scanned := false
+	for _, r := range reports {
+		if r.Name == "store" && r.Error == "" {
+			scanned = true
+		}
+	}
+	if !scanned {
+		return nil, nil
+	}
+
+	sids, names = make(map[string]bool), make(map[string]bool)
+	for _, app := range a.discoveredApps() {
+		if app.PackageSID != "" {
+			sids[strings.ToUpper(app.PackageSID)] = true
+			names[strings.ToLower(app.Name)] = true
+		}
+	}
+	for _, h := range hidden {
+		if h.PackageSID != "" {
+			sids[strings.ToUpper(h.PackageSID)] = true
+			names[strings.ToLower(h.Name)] = true
+		}
+	}
+	return sids, names
+}
+
+// addedApps returns the apps the changes report as newly installed
+func addedApps(found []apps.InstalledApp, changes []apps.Change) []apps.InstalledApp {
+	added := make(map[string]bool)
+	for _, c := range changes {
+		if c.Kind == apps.ChangeAdded {
+			added[c.AppID] = true
+		}
+	}
+	var result []apps.InstalledApp
+	for _, app := range found {
+		if added[app.ID] {
+			result = append(result, app)
+		}
+	}
+	return result
+}
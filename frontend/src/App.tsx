@@ -11,6 +11,7 @@ import {
   BlockInstalledApp,
   UnblockInstalledApp,
 } from "../wailsjs/go/main/App";
+import { EventsOn } from "../wailsjs/runtime/runtime";
 
 function App() {
   const [apps, setApps] = useState<InstalledApp[]>([]);
@@ -22,14 +23,21 @@ function App() {
   const [activeTab, setActiveTab] = useState("all");
 
   useEffect(() => {
-    // Retry a few times on startup in case backend isn't ready
-    const tryLoad = async (retries = 3) => {
-      await loadData();
-      if (apps.length === 0 && retries > 0) {
-        setTimeout(() => tryLoad(retries - 1), 500);
-      }
+    loadData();
+
+    // Discovery runs in the background; apps stream in as sources finish
+    const offApp = EventsOn("discovery:app", (app: InstalledApp) => {
+      setApps((prev) => (prev.some((a) => a.id === app.id) ? prev : [...prev, app]));
+      setLoading(false);
+    });
+    const offDone = EventsOn("discovery:done", (found: InstalledApp[]) => {
+      setApps(found || []);
+      setLoading(false);
+    });
+    return () => {
+      offApp();
+      offDone();
     };
-    tryLoad();
   }, []);
 
   const loadData = async () => {
@@ -40,7 +48,8 @@ function App() {
         GetBlockedApps(),
       ]);
 
-      setApps(installedApps || []);
+      // Keep streamed apps while the first discovery is still running
+      setApps((prev) => (installedApps?.length ? installedApps : prev));
 
       // Build sets for both path-based and PKG-based rules
       const blockedPathSet = new Set<string>();
@@ -104,7 +113,7 @@ function App() {
     const appsToBlock = apps.filter(app => selectedAppIds.has(app.id));
     
     for (const app of appsToBlock) {
-      await BlockInstalledApp(app);
+      await BlockInstalledApp(app.id);
     }
 
     await loadData();
@@ -116,7 +125,7 @@ function App() {
     const appsToUnblock = apps.filter(app => selectedAppIds.has(app.id));
     
     for (const app of appsToUnblock) {
-      await UnblockInstalledApp(app);
+      await UnblockInstalledApp(app.id);
     }
 
     await loadData();
@@ -9,6 +9,25 @@ export interface InstalledApp {
   appType: string;
   packageFamilyName: string;  // For Store/UWP apps
   packageSID: string;         // App Container SID for firewall blocking
+  version: string;
+  source: string;
+  users?: string[];           // Users with a per-user install; empty when installed for everyone
+  tags?: string[];            // User tags
+  categories?: string[];      // Automatic: publisher:*, source:* and well-known categories
+  installDate?: string;       // YYYY-MM-DD when the installer wrote one
+  estimatedSize?: number;     // Bytes
+  uninstallString?: string;
+  urlInfoAbout?: string;
+  productCode?: string;       // Windows Installer packages
+  noRemove?: boolean;
+  updates?: AppUpdate[];      // Updates and patches, as under Installed Updates
+}
+
+export interface AppUpdate {
+  name: string;
+  version: string;
+  installDate: string;
+  releaseType: string;
 }
 
 
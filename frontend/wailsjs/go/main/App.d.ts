@@ -7,7 +7,7 @@ export function BlockFile(arg1:string):Promise<string>;
 
 export function BlockFiles(arg1:Array<string>):Promise<Record<string, string>>;
 
-export function BlockInstalledApp(arg1:apps.InstalledApp):Promise<string>;
+export function BlockInstalledApp(arg1:string):Promise<string>;
 
 export function GetBlockedApps():Promise<Array<firewall.BlockedApp>>;
 
@@ -19,4 +19,4 @@ export function UnblockFile(arg1:string):Promise<string>;
 
 export function UnblockFiles(arg1:Array<string>):Promise<Record<string, string>>;
 
-export function UnblockInstalledApp(arg1:apps.InstalledApp):Promise<string>;
+export function UnblockInstalledApp(arg1:string):Promise<string>;
@@ -0,0 +1,117 @@
+package apps
+
+import (
+	"enodia/internal/config"
+	"log"
+	"sync"
+)
+
+const cacheFile = "discovery.json"
+
+// cacheVersion is bumped whenever discovery output changes shape, so
+// results produced by an older build are not reused
+const cacheVersion = 8
+
+// discoveryCache keeps the results of each discovery source together
+// with the fingerprint of the state they were computed from. It is safe
+// for use by concurrent discovery jobs.
+type discoveryCache struct {
+	Version int                      `json:"version"`
+	Sources map[string]*cachedSource `json:"sources"`
+
+	mu sync.Mutex
+
+	// used records the sources looked up in this run; the rest belong to
+	// uninstalled apps and are dropped on save
+	used map[string]bool
+}
+
+// cachedSource is the result of one source or registry entry
+type cachedSource struct {
+	Fingerprint string         `json:"fingerprint"`
+	Apps        []InstalledApp `json:"apps"`
+}
+
+// loadCache reads the discovery cache, starting empty if it is missing,
+// unreadable or from another cache version
+func loadCache() *discoveryCache {
+	c := &discoveryCache{}
+	if err := config.Load(cacheFile, c); err != nil {
+		log.Printf("[Enodia] Warning: Could not load discovery cache: %v", err)
+	}
+	if c.Version != cacheVersion || c.Sources == nil {
+		c.Version = cacheVersion
+		c.Sources = make(map[string]*cachedSource)
+	}
+	c.used = make(map[string]bool)
+	return c
+}
+
+// save writes the sources used in this run back to disk
+func (c *discoveryCache) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.Sources {
+		if !c.used[name] {
+			delete(c.Sources, name)
+		}
+	}
+	if err := config.Save(cacheFile, c); err != nil {
+		log.Printf("[Enodia] Warning: Could not save discovery cache: %v", err)
+	}
+}
+
+// apps returns the cached apps of a source if its fingerprint is
+// unchanged, and otherwise runs scan and caches its result. hit reports
+// whether the cache was used. When scan fails, its error is returned
+// together with the previous result, which is kept.
+func (c *discoveryCache) apps(name, fingerprint string, scan func() ([]InstalledApp, error)) (apps []InstalledApp, hit bool, err error) {
+	c.mu.Lock()
+	c.used[name] = true
+	cached, ok := c.Sources[name]
+	c.mu.Unlock()
+
+	if ok && cached.Fingerprint == fingerprint {
+		return cloneApps(cached.Apps), true, nil
+	}
+	result, err := scan()
+	if err != nil {
+		log.Printf("[Enodia] Warning: Could not scan %s: %v", name, err)
+		if ok {
+			return cloneApps(cached.Apps), true, err
+		}
+		return result, false, err
+	}
+
+	c.mu.Lock()
+	c.Sources[name] = &cachedSource{Fingerprint: fingerprint, Apps: cloneApps(result)}
+	c.mu.Unlock()
+	return result, false, nil
+}
+
+// cloneApps copies apps deeply enough that merging into the copy leaves
+// the cached apps untouched
+func cloneApps(apps []InstalledApp) []InstalledApp {
+	if apps == nil {
+		return nil
+	}
+	out := make([]InstalledApp, len(apps))
+	for i, app := range apps {
+		app.Executables = append([]string(nil), app.Executables...)
+		app.Binaries = append([]Executable(nil), app.Binaries...)
+		app.Origins = append([]Origin(nil), app.Origins...)
+		app.Users = append([]string(nil), app.Users...)
+		app.Tags = append([]string(nil), app.Tags...)
+		app.Categories = append([]string(nil), app.Categories...)
+		if app.Shims != nil {
+			shims := make(map[string]string, len(app.Shims))
+			for k, v := range app.Shims {
+				shims[k] = v
+			}
+			app.Shims = shims
+		}
+		out[i] = app
+	}
+	return out
+}
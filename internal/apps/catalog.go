@@ -0,0 +1,139 @@
+package apps
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Catalog owns the list of discovered apps. It is safe for concurrent
+// use, and the apps it returns are copies, so callers can keep them
+// while a new discovery replaces the list.
+type Catalog struct {
+	mu   sync.RWMutex
+	apps []InstalledApp
+	// Indexes into apps, keyed by lowercased ID, install folder, package
+	// family name and executable path
+	byID      map[string]int
+	byPath    map[string]int
+	byPackage map[string]int
+	byExe     map[string]int
+	version   uint64
+}
+
+// NewCatalog returns an empty catalog
+func NewCatalog() *Catalog {
+	c := &Catalog{}
+	c.index()
+	return c
+}
+
+// Replace swaps in the apps of a new discovery and returns the new version
+func (c *Catalog) Replace(apps []InstalledApp) uint64 {
+	apps = cloneApps(apps)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apps = apps
+	c.index()
+	c.version++
+	return c.version
+}
+
+// Update changes the apps in place, as when executable kinds are
+// overridden, and returns the new version
+func (c *Catalog) Update(fn func(apps []InstalledApp)) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c.apps)
+	c.index()
+	c.version++
+	return c.version
+}
+
+// Snapshot returns a copy of the apps with the version they belong to
+func (c *Catalog) Snapshot() ([]InstalledApp, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return cloneApps(c.apps), c.version
+}
+
+// Apps returns a copy of the apps
+func (c *Catalog) Apps() []InstalledApp {
+	apps, _ := c.Snapshot()
+	return apps
+}
+
+// Version counts the changes to the catalog; it starts at 0 before the
+// first discovery
+func (c *Catalog) Version() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// ByID returns the app with the given ID
+func (c *Catalog) ByID(id string) (InstalledApp, bool) {
+	return c.lookup(c.byID, strings.ToLower(id))
+}
+
+// ByPath returns the app installed in the given folder
+func (c *Catalog) ByPath(path string) (InstalledApp, bool) {
+	return c.lookup(c.byPath, pathKey(path))
+}
+
+// ByPackageFamily returns the Store app with the given package family name
+func (c *Catalog) ByPackageFamily(family string) (InstalledApp, bool) {
+	return c.lookup(c.byPackage, strings.ToLower(family))
+}
+
+// ForExecutable returns the app that owns an executable
+func (c *Catalog) ForExecutable(path string) (InstalledApp, bool) {
+	return c.lookup(c.byExe, pathKey(path))
+}
+
+// lookup returns a copy of the app an index points to
+func (c *Catalog) lookup(index map[string]int, key string) (InstalledApp, bool) {
+	if key == "" {
+		return InstalledApp{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	i, ok := index[key]
+	if !ok {
+		return InstalledApp{}, false
+	}
+	return cloneApps(c.apps[i : i+1])[0], true
+}
+
+// index rebuilds the lookup maps. The first app wins where two share a
+// key, such as an executable both an app and a game list. The caller
+// must hold mu.
+func (c *Catalog) index() {
+	c.byID = make(map[string]int, len(c.apps))
+	c.byPath = make(map[string]int, len(c.apps))
+	c.byPackage = make(map[string]int)
+	c.byExe = make(map[string]int)
+	for i, app := range c.apps {
+		addIndex(c.byID, strings.ToLower(app.ID), i)
+		addIndex(c.byPath, pathKey(app.InstallPath), i)
+		addIndex(c.byPackage, strings.ToLower(app.PackageFamilyName), i)
+		for _, exe := range app.Executables {
+			addIndex(c.byExe, pathKey(exe), i)
+		}
+	}
+}
+
+// addIndex adds a key to an index unless it is empty or taken
+func addIndex(index map[string]int, key string, i int) {
+	if _, taken := index[key]; key != "" && !taken {
+		index[key] = i
+	}
+}
+
+// pathKey normalizes a path for lookups, ignoring case
+func pathKey(path string) string {
+	if path == "" {
+		return ""
+	}
+	return strings.ToLower(filepath.Clean(path))
+}
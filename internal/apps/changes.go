@@ -0,0 +1,282 @@
+package apps
+
+import (
+	"encoding/json"
+	"enodia/internal/config"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const changesFile = "changes.json"
+
+// maxChanges caps the change feed; the oldest changes are dropped first
+const maxChanges = 1000
+
+// Kinds of software changes
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeUpdated = "updated"
+)
+
+// Change is an app that was installed, updated or removed between two
+// discoveries
+type Change struct {
+	Kind      string `json:"kind"`
+	AppID     string `json:"appId"`
+	Name      string `json:"name"`
+	Publisher string `json:"publisher"`
+	Version   string `json:"version,omitempty"`
+	// PreviousVersion is set on updates
+	PreviousVersion string    `json:"previousVersion,omitempty"`
+	At              time.Time `json:"at"`
+}
+
+// snapshotEntry is what the change feed remembers of an app
+type snapshotEntry struct {
+	Name      string `json:"name"`
+	Publisher string `json:"publisher"`
+	Version   string `json:"version,omitempty"`
+	// Sources are the sources that found the app, by setting name
+	Sources []string `json:"sources,omitempty"`
+}
+
+// savedChanges is the layout of changes.json
+type savedChanges struct {
+	// Snapshot holds the apps of the last discovery by ID; nil until the
+	// first discovery, which sets the baseline without reporting changes
+	Snapshot map[string]snapshotEntry `json:"snapshot"`
+	Changes  []Change                 `json:"changes"`
+	// Absent lists the sources that were disabled or failed in the last
+	// discovery
+	Absent []string `json:"absent,omitempty"`
+	// Settings fingerprints, by source, the settings that decided what
+	// the source listed in the last discovery
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// ChangeFeed diffs successive discoveries into a persisted list of
+// installed, updated and removed apps
+type ChangeFeed struct {
+	mu    sync.Mutex
+	state savedChanges
+}
+
+// LoadChangeFeed reads the saved change feed and the last snapshot
+func LoadChangeFeed() *ChangeFeed {
+	var saved savedChanges
+	if err := config.Load(changesFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load change feed: %v", err)
+	}
+	return &ChangeFeed{state: saved}
+}
+
+// Record compares a discovery with the previous one and returns the new
+// changes, which are added to the feed. Apps hidden by filter rules are
+// still installed: they are never reported as removed, and once shown
+// they are not reported as added. An updated app is one whose version
+// changed.
+// Sources that were disabled or failed cannot tell what is installed:
+// the apps they found before are kept rather than reported as removed,
+// and apps found only by sources that were absent last time are taken
+// as the baseline rather than reported as added.
+// The same goes for sources whose settings changed, such as a new
+// portable root or other filter rules: apps they list or drop only
+// because of the new settings are not reported.
+func (f *ChangeFeed) Record(result Result, opts Options) []Change {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	previous := f.state.Snapshot
+	settings := settingsFingerprints(opts)
+	// Apps found only by these sources are a new baseline
+	rebased := make(map[string]bool, len(f.state.Absent))
+	for _, name := range f.state.Absent {
+		rebased[name] = true
+	}
+	changed := make(map[string]bool)
+	for name, fp := range settings {
+		if old, ok := f.state.Settings[name]; ok && old != fp {
+			changed[name] = true
+			rebased[name] = true
+		}
+	}
+	absent := absentSources(result.Sources)
+	snapshot := make(map[string]snapshotEntry, len(result.Apps)+len(result.Hidden))
+	var changes []Change
+
+	for _, app := range result.Apps {
+		entry := snapshotEntry{Name: app.Name, Publisher: app.Publisher, Version: app.Version, Sources: originSources(app.Origins)}
+		snapshot[app.ID] = entry
+		if previous == nil {
+			continue
+		}
+		old, existed := previous[app.ID]
+		switch {
+		case !existed && allIn(entry.Sources, rebased):
+			// Its sources did not run last time or now look elsewhere,
+			// so it may have been installed long ago
+		case !existed:
+			changes = append(changes, Change{Kind: ChangeAdded, AppID: app.ID, Name: app.Name, Publisher: app.Publisher, Version: app.Version, At: now})
+		case old.Version != "" && app.Version != "" && old.Version != app.Version:
+			changes = append(changes, Change{
+				Kind:            ChangeUpdated,
+				AppID:           app.ID,
+				Name:            app.Name,
+				Publisher:       app.Publisher,
+				Version:         app.Version,
+				PreviousVersion: old.Version,
+				At:              now,
+			})
+		}
+	}
+	for _, h := range result.Hidden {
+		if _, listed := snapshot[h.ID]; listed {
+			continue
+		}
+		entry, ok := previous[h.ID]
+		if !ok {
+			entry = snapshotEntry{Name: h.Name, Publisher: h.Publisher}
+		}
+		entry.Sources = []string{sourceSetting(h.Source)}
+		snapshot[h.ID] = entry
+	}
+
+	var removed []Change
+	for id, old := range previous {
+		if _, ok := snapshot[id]; ok {
+			continue
+		}
+		if anyIn(old.Sources, absent) {
+			// A source that did not run cannot tell it is gone
+			snapshot[id] = old
+			continue
+		}
+		if allIn(old.Sources, changed) {
+			// Its sources no longer look for it
+			continue
+		}
+		removed = append(removed, Change{Kind: ChangeRemoved, AppID: id, Name: old.Name, Publisher: old.Publisher, Version: old.Version, At: now})
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Name < removed[j].Name })
+	changes = append(changes, removed...)
+
+	f.state.Snapshot = snapshot
+	f.state.Absent = nil
+	for name := range absent {
+		f.state.Absent = append(f.state.Absent, name)
+	}
+	sort.Strings(f.state.Absent)
+	f.state.Settings = settings
+	f.state.Changes = append(f.state.Changes, changes...)
+	if len(f.state.Changes) > maxChanges {
+		f.state.Changes = append([]Change(nil), f.state.Changes[len(f.state.Changes)-maxChanges:]...)
+	}
+	if err := config.Save(changesFile, f.state); err != nil {
+		log.Printf("[Enodia] Warning: Could not save change feed: %v", err)
+	}
+	if len(changes) > 0 {
+		log.Printf("[Enodia] Recorded %d software changes", len(changes))
+	}
+	return changes
+}
+
+// Changes returns the change feed, newest first
+func (f *ChangeFeed) Changes() []Change {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	changes := make([]Change, len(f.state.Changes))
+	for i, c := range f.state.Changes {
+		changes[len(changes)-1-i] = c
+	}
+	return changes
+}
+
+// Clear empties the change feed, keeping the snapshot to diff against
+func (f *ChangeFeed) Clear() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state.Changes = nil
+	return config.Save(changesFile, f.state)
+}
+
+// absentSources returns the sources that were disabled or failed in a
+// discovery with these reports
+func absentSources(reports []SourceReport) map[string]bool {
+	ran := make(map[string]bool, len(reports))
+	for _, r := range reports {
+		if r.Error == "" {
+			ran[r.Name] = true
+		}
+	}
+	absent := make(map[string]bool)
+	for _, s := range resolveSourceSettings(nil) {
+		if !ran[s.Name] {
+			absent[s.Name] = true
+		}
+	}
+	return absent
+}
+
+// settingsFingerprints summarizes, by source, the settings that decide
+// what the source lists: the filter rules every app passes through, and
+// the roots of the portable scan
+func settingsFingerprints(opts Options) map[string]string {
+	rules, _ := json.Marshal(opts.Rules)
+	roots, _ := json.Marshal(opts.PortableRoots)
+	settings := make(map[string]string)
+	for _, s := range resolveSourceSettings(nil) {
+		fp := newFingerprint()
+		fp.add(string(rules))
+		if s.Name == "portable" {
+			fp.add(string(roots))
+		}
+		settings[s.Name] = fp.String()
+	}
+	return settings
+}
+
+// originSources returns the setting names of the sources of origins
+func originSources(origins []Origin) []string {
+	var sources []string
+	for _, o := range origins {
+		name := sourceSetting(o.Source)
+		if !containsFold(sources, name) {
+			sources = append(sources, name)
+		}
+	}
+	return sources
+}
+
+// sourceSetting maps the source of an app to the name of its setting
+func sourceSetting(source string) string {
+	if source == "profile" {
+		return "profiles"
+	}
+	return source
+}
+
+// allIn reports whether every one of names is in set; an empty list is
+// not
+func allIn(names []string, set map[string]bool) bool {
+	for _, name := range names {
+		if !set[name] {
+			return false
+		}
+	}
+	return len(names) > 0
+}
+
+// anyIn reports whether any of names is in set
+func anyIn(names []string, set map[string]bool) bool {
+	for _, name := range names {
+		if set[name] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package apps
+
+import (
+	"reflect"
+	"testing"
+)
+
+// useTempConfig points the config directory at a temporary folder
+func useTempConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("AppData", dir)
+	t.Setenv("HOME", dir)
+}
+
+// reports lists every source as run, except the failed ones
+func reports(failed ...string) []SourceReport {
+	var r []SourceReport
+	for _, s := range resolveSourceSettings(nil) {
+		report := SourceReport{Name: s.Name}
+		if containsFold(failed, s.Name) {
+			report.Error = "failed"
+		}
+		r = append(r, report)
+	}
+	return r
+}
+
+// testApp makes an app found by the given source
+func testApp(source, name, version string) InstalledApp {
+	return InstalledApp{
+		ID:      appID(source, name),
+		Name:    name,
+		Version: version,
+		Source:  source,
+		Origins: []Origin{{Source: source, Key: name}},
+	}
+}
+
+// changeSummary lists changes as "kind name" for comparison
+func changeSummary(changes []Change) []string {
+	var s []string
+	for _, c := range changes {
+		s = append(s, c.Kind+" "+c.Name)
+	}
+	return s
+}
+
+func TestChangeFeedRecord(t *testing.T) {
+	useTempConfig(t)
+	feed := LoadChangeFeed()
+	opts := Options{Rules: DefaultFilterRules()}
+
+	editor := testApp("registry", "Editor", "1.0")
+	browser := testApp("registry", "Browser", "5.0")
+	if got := feed.Record(Result{Apps: []InstalledApp{editor}, Sources: reports()}, opts); len(got) != 0 {
+		t.Fatalf("first discovery reported %q, want the baseline only", changeSummary(got))
+	}
+
+	editor.Version = "1.1"
+	got := feed.Record(Result{Apps: []InstalledApp{editor, browser}, Sources: reports()}, opts)
+	if want := []string{"updated Editor", "added Browser"}; !reflect.DeepEqual(changeSummary(got), want) {
+		t.Errorf("changes = %q, want %q", changeSummary(got), want)
+	}
+	if got[0].PreviousVersion != "1.0" {
+		t.Errorf("previous version = %q, want 1.0", got[0].PreviousVersion)
+	}
+
+	got = feed.Record(Result{Apps: []InstalledApp{browser}, Sources: reports()}, opts)
+	if want := []string{"removed Editor"}; !reflect.DeepEqual(changeSummary(got), want) {
+		t.Errorf("changes = %q, want %q", changeSummary(got), want)
+	}
+
+	// The feed survives a restart, newest first
+	if got := changeSummary(LoadChangeFeed().Changes()); !reflect.DeepEqual(got, []string{"removed Editor", "added Browser", "updated Editor"}) {
+		t.Errorf("saved feed = %q", got)
+	}
+}
+
+func TestChangeFeedHiddenApps(t *testing.T) {
+	useTempConfig(t)
+	feed := LoadChangeFeed()
+	opts := Options{Rules: DefaultFilterRules()}
+
+	runtime := testApp("registry", "Contoso Runtime", "1.0")
+	hidden := []HiddenApp{hiddenApp(runtime, "contoso")}
+	feed.Record(Result{Hidden: hidden, Sources: reports()}, opts)
+
+	// Hidden apps are still installed, so hiding them removes nothing
+	if got := feed.Record(Result{Hidden: hidden, Sources: reports()}, opts); len(got) != 0 {
+		t.Errorf("hidden app reported %q", changeSummary(got))
+	}
+	// and showing them, with the same rules, installs nothing
+	if got := feed.Record(Result{Apps: []InstalledApp{runtime}, Sources: reports()}, opts); len(got) != 0 {
+		t.Errorf("shown app reported %q, want no changes", changeSummary(got))
+	}
+}
+
+func TestChangeFeedChangedSettings(t *testing.T) {
+	useTempConfig(t)
+	feed := LoadChangeFeed()
+	opts := Options{Rules: DefaultFilterRules()}
+
+	editor := testApp("registry", "Editor", "1.0")
+	feed.Record(Result{Apps: []InstalledApp{editor}, Sources: reports()}, opts)
+
+	// A new portable root finds apps that were there all along, while
+	// the registry still reports what was installed meanwhile
+	tool := testApp("portable", "Tool", "2.0")
+	browser := testApp("registry", "Browser", "5.0")
+	opts.PortableRoots = []ScanRoot{{Path: `C:\Tools`}}
+	got := feed.Record(Result{Apps: []InstalledApp{editor, tool, browser}, Sources: reports()}, opts)
+	if want := []string{"added Browser"}; !reflect.DeepEqual(changeSummary(got), want) {
+		t.Errorf("after adding a root: changes = %q, want %q", changeSummary(got), want)
+	}
+
+	// Removing the root again removes nothing
+	opts.PortableRoots = nil
+	if got := feed.Record(Result{Apps: []InstalledApp{editor, browser}, Sources: reports()}, opts); len(got) != 0 {
+		t.Errorf("after removing the root: changes = %q, want none", changeSummary(got))
+	}
+
+	// Other filter rules give every source a new baseline
+	opts.Rules = nil
+	runtime := testApp("registry", "Contoso Runtime", "1.0")
+	if got := feed.Record(Result{Apps: []InstalledApp{editor, browser, runtime}, Sources: reports()}, opts); len(got) != 0 {
+		t.Errorf("after widening the filters: changes = %q, want none", changeSummary(got))
+	}
+
+	// With the settings left alone, changes are reported again
+	got = feed.Record(Result{Apps: []InstalledApp{editor, runtime}, Sources: reports()}, opts)
+	if want := []string{"removed Browser"}; !reflect.DeepEqual(changeSummary(got), want) {
+		t.Errorf("changes = %q, want %q", changeSummary(got), want)
+	}
+}
+
+func TestChangeFeedAbsentSources(t *testing.T) {
+	useTempConfig(t)
+	feed := LoadChangeFeed()
+	opts := Options{}
+
+	game := testApp("steam", "Game", "1")
+	feed.Record(Result{Apps: []InstalledApp{game}, Sources: reports()}, opts)
+
+	// A failed source cannot tell its apps are gone
+	if got := feed.Record(Result{Sources: reports("steam")}, opts); len(got) != 0 {
+		t.Errorf("failed source reported %q, want no changes", changeSummary(got))
+	}
+
+	// Once it runs again, what it finds is the baseline
+	other := testApp("steam", "Other Game", "1")
+	if got := feed.Record(Result{Apps: []InstalledApp{game, other}, Sources: reports()}, opts); len(got) != 0 {
+		t.Errorf("returning source reported %q, want no changes", changeSummary(got))
+	}
+}
@@ -0,0 +1,141 @@
+package apps
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// nuspec is the metadata of an installed Chocolatey package
+type nuspec struct {
+	Metadata struct {
+		ID      string `xml:"id"`
+		Version string `xml:"version"`
+		Title   string `xml:"title"`
+		Authors string `xml:"authors"`
+	} `xml:"metadata"`
+}
+
+// chocolateyRoot returns the Chocolatey installation folder
+func chocolateyRoot() string {
+	if root := os.Getenv("ChocolateyInstall"); root != "" {
+		return root
+	}
+	if programData := os.Getenv("ProgramData"); programData != "" {
+		return filepath.Join(programData, "chocolatey")
+	}
+	return ""
+}
+
+// discoverChocolateyApps reads lib/*/*.nuspec and the executables each
+// package ships in its folder. Packages that run a regular installer have
+// no executables of their own and only describe the matching registry app.
+func discoverChocolateyApps(root string) ([]InstalledApp, error) {
+	if root == "" {
+		return nil, nil
+	}
+	pkgDirs, err := readDirIfExists(filepath.Join(root, "lib"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Chocolatey packages: %w", err)
+	}
+	shims, _ := filepath.Glob(filepath.Join(root, "bin", "*.exe"))
+
+	var apps []InstalledApp
+	for _, d := range pkgDirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, "lib", d.Name())
+		specs, _ := filepath.Glob(filepath.Join(dir, "*.nuspec"))
+		if len(specs) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(specs[0])
+		if err != nil {
+			continue
+		}
+		var spec nuspec
+		if err := xml.Unmarshal(data, &spec); err != nil {
+			log.Printf("[Enodia] Warning: Could not parse nuspec for %s: %v", d.Name(), err)
+			continue
+		}
+
+		id := spec.Metadata.ID
+		if id == "" {
+			id = d.Name()
+		}
+		app := InstalledApp{
+			ID:        appID("chocolatey", id),
+			Name:      chocolateyDisplayName(id, spec.Metadata.Title),
+			Publisher: spec.Metadata.Authors,
+			AppType:   "win32",
+			Source:    "chocolatey",
+			Origins:   []Origin{{Source: "chocolatey", Key: id}},
+			Version:   spec.Metadata.Version,
+		}
+
+		for _, exe := range findExecutables(dir) {
+			// A .ignore file next to an executable tells Chocolatey not to shim it
+			if _, err := os.Stat(exe + ".ignore"); err == nil {
+				continue
+			}
+			app.Executables = append(app.Executables, exe)
+		}
+		if len(app.Executables) > 0 {
+			app.InstallPath = dir
+			linkChocolateyShims(&app, shims)
+			applyVersionInfo(&app, "")
+			applySignature(&app, "")
+			app.IconBase64 = extractWin32IconBase64(&app, "")
+		}
+		apps = append(apps, app)
+	}
+
+	log.Printf("[Enodia] Found %d Chocolatey packages", len(apps))
+	return apps, nil
+}
+
+// chocolateyDisplayName prefers the package title over its ID and drops
+// the "(Install)" and "(Portable)" suffixes of split packages
+func chocolateyDisplayName(id, title string) string {
+	name := strings.TrimSpace(title)
+	if name == "" {
+		name = id
+	}
+	for _, suffix := range []string{" (Install)", " (Portable)"} {
+		if len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+			name = name[:len(name)-len(suffix)]
+		}
+	}
+	return name
+}
+
+// linkChocolateyShims maps the shims in Chocolatey's bin folder to the
+// package executables they launch. Shims are generated with the name of
+// their target, so the base name identifies it.
+func linkChocolateyShims(app *InstalledApp, shims []string) {
+	for _, shim := range shims {
+		for _, exe := range app.Executables {
+			if strings.EqualFold(filepath.Base(shim), filepath.Base(exe)) {
+				if app.Shims == nil {
+					app.Shims = make(map[string]string)
+				}
+				app.Shims[shim] = exe
+				break
+			}
+		}
+	}
+}
+
+// chocolateyFingerprint covers every package's nuspec and the shims
+func chocolateyFingerprint(root string) string {
+	fp := newFingerprint()
+	if root != "" {
+		fp.glob(filepath.Join(root, "lib", "*", "*.nuspec"))
+		fp.file(filepath.Join(root, "bin"))
+	}
+	return fp.String()
+}
@@ -0,0 +1,70 @@
+package apps
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverChocolateyApps(t *testing.T) {
+	root := copyFixture(t, "chocolatey")
+
+	apps, err := discoverChocolateyApps(root)
+	if err != nil {
+		t.Fatalf("discoverChocolateyApps: %v", err)
+	}
+	byName := appsByName(t, apps)
+	// Broken nuspecs and folders without one are not listed
+	if got, want := sortedNames(byName), []string{"Contoso Editor", "Contoso Suite"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("apps = %q, want %q", got, want)
+	}
+
+	portable := byName["Contoso Editor"]
+	dir := filepath.Join(root, "lib", "contoso.portable")
+	if portable.InstallPath != dir || portable.Version != "2.0.1" || portable.Publisher != "Contoso Ltd." {
+		t.Errorf("portable = %q %q %q", portable.InstallPath, portable.Version, portable.Publisher)
+	}
+	// helper.exe is excluded by its .ignore file, so its shim stays unlinked
+	exe := filepath.Join(dir, "tools", "contoso.exe")
+	if !reflect.DeepEqual(portable.Executables, []string{exe}) {
+		t.Errorf("portable executables = %q, want %q", portable.Executables, exe)
+	}
+	wantShims := map[string]string{filepath.Join(root, "bin", "contoso.exe"): exe}
+	if !reflect.DeepEqual(portable.Shims, wantShims) {
+		t.Errorf("portable shims = %q, want %q", portable.Shims, wantShims)
+	}
+
+	// Installer packages only describe the registry app
+	install := byName["Contoso Suite"]
+	if install.InstallPath != "" || len(install.Executables) != 0 || len(install.Shims) != 0 {
+		t.Errorf("install package = %q %q %q, want no files", install.InstallPath, install.Executables, install.Shims)
+	}
+	if install.ID != appID("chocolatey", "contoso.install") {
+		t.Errorf("install package ID = %q", install.ID)
+	}
+}
+
+func TestDiscoverChocolateyAppsMissing(t *testing.T) {
+	apps, err := discoverChocolateyApps(filepath.Join(t.TempDir(), "chocolatey"))
+	if err != nil || len(apps) != 0 {
+		t.Errorf("discoverChocolateyApps = %v, %v; want nothing", apps, err)
+	}
+}
+
+func TestChocolateyDisplayName(t *testing.T) {
+	tests := []struct {
+		id, title string
+		want      string
+	}{
+		{"git", "Git", "Git"},
+		{"git.install", "Git (Install)", "Git"},
+		{"7zip.portable", "7-Zip (portable)", "7-Zip"},
+		{"vlc", "  ", "vlc"},
+		{"odd", "(Install)", "(Install)"},
+	}
+	for _, tt := range tests {
+		if got := chocolateyDisplayName(tt.id, tt.title); got != tt.want {
+			t.Errorf("chocolateyDisplayName(%q, %q) = %q, want %q", tt.id, tt.title, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package apps
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Executable kinds
+const (
+	KindMain          = "main"
+	KindHelper        = "helper"
+	KindUpdater       = "updater"
+	KindUninstaller   = "uninstaller"
+	KindCrashReporter = "crashreporter"
+)
+
+// IsKind reports whether kind is one of the executable kinds
+func IsKind(kind string) bool {
+	switch kind {
+	case KindMain, KindHelper, KindUpdater, KindUninstaller, KindCrashReporter:
+		return true
+	}
+	return false
+}
+
+// Name fragments that mark updaters and crash reporters. They are matched
+// against the file name and the OriginalFilename from the version
+// resource, lowercased and without the extension. Uninstallers are
+// recognized by isUninstallerName, as for Start Menu shortcuts.
+var (
+	updaterNames = []string{"update", "upgrade", "maintenanceservice", "squirrel"}
+	crashNames   = []string{"crashpad", "crashreporter", "crash_reporter", "crashhandler", "crash_handler", "crashsender", "bugreport", "breakpad", "werfault", "sentry"}
+)
+
+// Description fragments with the same meaning, matched against the
+// FileDescription of the version resource
+var (
+	uninstallerDescriptions = []string{"uninstall"}
+	updaterDescriptions     = []string{"updater", "update service", "update helper", "auto update", "software update"}
+	crashDescriptions       = []string{"crash report", "crash handler", "crashpad", "error report"}
+)
+
+// heuristicKind classifies an executable from its names and description.
+// It returns "" for executables that are neither updaters, uninstallers
+// nor crash reporters.
+func heuristicKind(exe Executable) string {
+	names := []string{baseName(exe.Path)}
+	if exe.OriginalFilename != "" {
+		names = append(names, baseName(exe.OriginalFilename))
+	}
+	desc := strings.ToLower(exe.Description)
+
+	// Uninstallers first, so "Uninstall Update" tools are not updaters
+	for _, name := range names {
+		if isUninstallerName(name) {
+			return KindUninstaller
+		}
+	}
+	switch {
+	case containsAny(desc, uninstallerDescriptions):
+		return KindUninstaller
+	case namesContain(names, crashNames) || containsAny(desc, crashDescriptions):
+		return KindCrashReporter
+	case namesContain(names, updaterNames) || containsAny(desc, updaterDescriptions):
+		return KindUpdater
+	}
+	return ""
+}
+
+// classifyBinaries sets the kind of every binary of app. The main
+// executable is the one mainExecutable picks, unless that turns out to be
+// a special-purpose executable.
+func classifyBinaries(app *InstalledApp, iconPath string) {
+	main := mainExecutable(app, iconPath)
+	for i := range app.Binaries {
+		app.Binaries[i].Kind = heuristicKind(app.Binaries[i])
+	}
+
+	mainIdx := -1
+	for i, b := range app.Binaries {
+		if b.Kind == "" && strings.EqualFold(b.Path, main) {
+			mainIdx = i
+			break
+		}
+	}
+	if mainIdx < 0 {
+		for i, b := range app.Binaries {
+			if b.Kind == "" {
+				mainIdx = i
+				break
+			}
+		}
+	}
+
+	for i := range app.Binaries {
+		switch {
+		case i == mainIdx:
+			app.Binaries[i].Kind = KindMain
+		case app.Binaries[i].Kind == "":
+			app.Binaries[i].Kind = KindHelper
+		}
+	}
+}
+
+// ExecutablesOfKind returns the app's executables of the given kinds
+func (app InstalledApp) ExecutablesOfKind(kinds ...string) []string {
+	return app.selectExecutables(kinds, true)
+}
+
+// ExecutablesExcept returns the app's executables that are not of the given kinds
+func (app InstalledApp) ExecutablesExcept(kinds ...string) []string {
+	return app.selectExecutables(kinds, false)
+}
+
+func (app InstalledApp) selectExecutables(kinds []string, include bool) []string {
+	var result []string
+	for _, exe := range app.Executables {
+		kind := app.KindOf(exe)
+		matched := false
+		for _, k := range kinds {
+			if kind == k {
+				matched = true
+				break
+			}
+		}
+		if matched == include {
+			result = append(result, exe)
+		}
+	}
+	return result
+}
+
+// KindOf returns the kind of one of the app's executables, preferring
+// the user's override and treating unclassified executables as helpers
+func (app InstalledApp) KindOf(path string) string {
+	for _, b := range app.Binaries {
+		if !strings.EqualFold(b.Path, path) {
+			continue
+		}
+		if b.KindOverride != "" {
+			return b.KindOverride
+		}
+		if b.Kind != "" {
+			return b.Kind
+		}
+	}
+	return KindHelper
+}
+
+// baseName lowercases a file name and drops its folder and extension
+func baseName(path string) string {
+	// Version resources store plain names; paths use either separator
+	path = path[strings.LastIndexAny(path, `\/`)+1:]
+	return strings.ToLower(strings.TrimSuffix(path, filepath.Ext(path)))
+}
+
+// namesContain reports whether any name contains one of the fragments
+func namesContain(names, fragments []string) bool {
+	for _, name := range names {
+		if containsAny(name, fragments) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny reports whether s contains one of the fragments
+func containsAny(s string, fragments []string) bool {
+	for _, f := range fragments {
+		if strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,205 @@
 package apps
 
-import "log"
+import (
+	"context"
+	"enodia/internal/winreg"
+	"log"
+	"sync"
+	"time"
+)
 
-// DiscoverApps finds all installed applications (Win32 + Store)
-func DiscoverApps() []InstalledApp {
+// Progress reports how many discovery jobs have finished
+type Progress struct {
+	Source string `json:"source"`
+	Done   int    `json:"done"`
+	Total  int    `json:"total"`
+}
+
+// Observer receives discovery events as they happen. Callbacks are
+// called from worker goroutines, one at a time; either may be nil.
+type Observer struct {
+	// OnProgress is called after each source or registry entry
+	OnProgress func(Progress)
+	// OnApp is called for each app a source finds, before apps of
+	// different sources are merged
+	OnApp func(InstalledApp)
+}
+
+// Options are the user's discovery settings
+type Options struct {
+	// Rules decide which apps are hidden
+	Rules []FilterRule
+	// PortableRoots are the folders scanned for portable apps; the scan
+	// is skipped when there are none
+	PortableRoots []ScanRoot
+	// Sources turns sources on or off and orders them; nil runs every
+	// source in its default order
+	Sources []SourceSetting
+}
+
+// Result is the outcome of a discovery
+type Result struct {
+	Apps []InstalledApp `json:"apps"`
+	// Hidden lists the apps filter rules kept out of Apps
+	Hidden []HiddenApp `json:"hidden"`
+	// Sources reports how each source that ran did, in merge order
+	Sources []SourceReport `json:"sources"`
+}
+
+// DiscoverApps finds all installed applications from the registry, the
+// Start Menu and the enabled sources (package managers, game launchers,
+// portable apps + Store), and hides those the filter rules exclude.
+// Sources run in parallel, and those whose fingerprint is unchanged since
+// the last run are served from the discovery cache. A source that fails
+// is reported in the result; cancelling ctx stops discovery and returns
+// ctx.Err().
+func DiscoverApps(ctx context.Context, opts Options, obs Observer) (Result, error) {
+	cache := loadCache()
+	result, err := discoverFrom(ctx, winreg.System(), cache, opts, obs)
+	if err != nil {
+		return Result{}, err
+	}
+	cache.save()
+	return result, nil
+}
+
+// discoverFrom runs discovery against the given registry
+func discoverFrom(ctx context.Context, reg winreg.Reader, cache *discoveryCache, opts Options, obs Observer) (Result, error) {
 	log.Println("[Enodia] Starting app discovery...")
+	rules := opts.Rules
+
+	profiles := userProfiles(reg)
+	sources := buildSources(sourceEnv{reg: reg, profiles: profiles, opts: opts}, opts.Sources)
+
+	// Every registry entry is a job of its own, so install folders are
+	// walked in parallel. Hidden entries are dropped up front, which
+	// spares walking the folders of system components.
+	var entries []win32Entry
+	var hidden []HiddenApp
+	useRegistry := sourceEnabled(opts.Sources, SourceRegistry)
+	if useRegistry {
+		for _, e := range readWin32Entries(reg, profiles) {
+			if rule := hiddenBy(rules, filterTarget{e.name, e.publisher, e.installPath, ""}); rule != "" {
+				hidden = append(hidden, HiddenApp{
+					ID:          appID("registry", e.identity),
+					Name:        e.name,
+					Publisher:   e.publisher,
+					InstallPath: e.installPath,
+					Source:      SourceRegistry,
+					Rule:        rule,
+				})
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	win32 := make([][]InstalledApp, len(entries))
+	found := make([][]InstalledApp, len(sources))
+	reports := make([]SourceReport, len(sources))
+	var registryReport SourceReport
+
+	// Sources go first: the Store query alone can take seconds
+	var jobs []job
+	for i, src := range sources {
+		i, src := i, src
+		jobs = append(jobs, job{name: src.Name(), run: func() []InstalledApp {
+			found[i], reports[i] = runSource(ctx, cache, src)
+			return found[i]
+		}})
+	}
+	// The registry's duration is the wall time from its first job's start
+	// to its last job's end, as its jobs overlap
+	var mu sync.Mutex
+	var registryStart, registryEnd time.Time
+	for i, e := range entries {
+		i, e := i, e
+		jobs = append(jobs, job{name: e.name, run: func() []InstalledApp {
+			start := time.Now()
+			apps, hit, _ := cache.apps(e.source, e.fingerprint, func() ([]InstalledApp, error) {
+				return []InstalledApp{newWin32App(e)}, nil
+			})
+			win32[i] = apps
+
+			mu.Lock()
+			if registryStart.IsZero() || start.Before(registryStart) {
+				registryStart = start
+			}
+			if end := time.Now(); end.After(registryEnd) {
+				registryEnd = end
+			}
+			if !hit {
+				registryReport.Cached = false
+			}
+			mu.Unlock()
+			return apps
+		}})
+	}
+
+	done := 0
+	registryReport = SourceReport{Name: SourceRegistry, Cached: true}
+	err := runJobs(ctx, workerCount(), jobs, func(j job, result []InstalledApp) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if obs.OnApp != nil {
+			for _, app := range result {
+				if hiddenBy(rules, appTarget(app)) == "" {
+					obs.OnApp(app)
+				}
+			}
+		}
+		if obs.OnProgress != nil {
+			obs.OnProgress(Progress{Source: j.name, Done: done, Total: len(jobs)})
+		}
+	})
+	if err != nil {
+		log.Printf("[Enodia] App discovery cancelled")
+		return Result{}, err
+	}
+
+	var apps []InstalledApp
+	var results []SourceReport
+	fp := newFingerprint()
+	for i, e := range entries {
+		apps = append(apps, win32[i]...)
+		fp.add(e.fingerprint)
+	}
+	if useRegistry {
+		log.Printf("[Enodia] Found %d Win32 apps", len(apps))
+		registryReport.Apps = len(apps)
+		if !registryStart.IsZero() {
+			registryReport.DurationMs = registryEnd.Sub(registryStart).Milliseconds()
+		}
+		results = append(results, registryReport)
+	}
+
+	// Merging shortcuts reads executables again, so the merged list is
+	// cached under the fingerprints of all entries and shortcuts
+	if sourceEnabled(opts.Sources, SourceStartMenu) {
+		start := time.Now()
+		menuDirs := startMenuDirs()
+		fp.add(shortcutsFingerprint(menuDirs))
+		before := len(apps)
+		merged, hit, _ := cache.apps(SourceStartMenu, fp.String(), func() ([]InstalledApp, error) {
+			return mergeShortcuts(apps, discoverShortcuts(menuDirs)), nil
+		})
+		apps = merged
+		results = append(results, SourceReport{
+			Name:       SourceStartMenu,
+			Apps:       len(apps) - before,
+			Cached:     hit,
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+	}
+
+	for i, src := range sources {
+		apps = src.Capabilities().merge(apps, found[i])
+		results = append(results, reports[i])
+	}
 
-	apps := discoverWin32Apps()
-	apps = append(apps, discoverStoreApps()...)
+	apps, filtered := filterApps(rules, apps)
+	hidden = append(hidden, filtered...)
 
-	log.Printf("[Enodia] Discovered %d applications total", len(apps))
-	return apps
+	log.Printf("[Enodia] Discovered %d applications total, %d hidden by filter rules", len(apps), len(hidden))
+	return Result{Apps: apps, Hidden: hidden, Sources: results}, nil
 }
@@ -0,0 +1,226 @@
+package apps
+
+import (
+	"enodia/internal/winreg"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// copyFixture copies a testdata folder to a temporary one and returns it.
+// "{root}" in the files becomes the temporary folder, escaped for the
+// JSON and KeyValues files that need backslashes doubled.
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+	root := t.TempDir()
+	src := filepath.Join("testdata", name)
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		dest := filepath.Join(root, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dest, 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		value := root
+		if filepath.Ext(path) != ".shim" {
+			value = strings.ReplaceAll(root, `\`, `\\`)
+		}
+		return os.WriteFile(dest, []byte(strings.ReplaceAll(string(data), "{root}", value)), 0o644)
+	})
+	if err != nil {
+		t.Fatalf("copy %s: %v", name, err)
+	}
+	return root
+}
+
+// symlink links name to target, skipping the test where links need
+// privileges the test lacks
+func symlink(t *testing.T, target, name string) {
+	t.Helper()
+	if err := os.Symlink(target, name); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+}
+
+// appsByName indexes apps by name, failing the test when two share a name
+func appsByName(t *testing.T, apps []InstalledApp) map[string]InstalledApp {
+	t.Helper()
+	byName := make(map[string]InstalledApp, len(apps))
+	for _, app := range apps {
+		if _, dup := byName[app.Name]; dup {
+			t.Fatalf("app %q listed twice", app.Name)
+		}
+		byName[app.Name] = app
+	}
+	return byName
+}
+
+// sortedNames lists the names of apps in order
+func sortedNames(apps map[string]InstalledApp) []string {
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// entriesByName indexes uninstall entries by display name, failing the
+// test when two share a name
+func entriesByName(t *testing.T, entries []win32Entry) map[string]win32Entry {
+	t.Helper()
+	byName := make(map[string]win32Entry, len(entries))
+	for _, e := range entries {
+		if _, dup := byName[e.name]; dup {
+			t.Fatalf("entry %q listed twice", e.name)
+		}
+		byName[e.name] = e
+	}
+	return byName
+}
+
+// originKeys lists the registry keys an entry stands for
+func originKeys(e win32Entry) []string {
+	keys := make([]string, 0, len(e.origins))
+	for _, o := range e.origins {
+		keys = append(keys, o.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestReadWin32Entries(t *testing.T) {
+	reg, err := winreg.LoadRegFile("testdata/uninstall.reg")
+	if err != nil {
+		t.Fatalf("LoadRegFile: %v", err)
+	}
+	t.Setenv("USERNAME", "alice")
+
+	entries := readWin32Entries(reg, nil)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+	sort.Strings(names)
+	// System components, keys without a display name or uninstall
+	// command, updates and the deleted key are not listed
+	want := []string{"Contoso Suite", "Discord", "Notepad++ (64-bit x64)"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("entries = %q, want %q", names, want)
+	}
+	byName := entriesByName(t, entries)
+
+	contoso := byName["Contoso Suite"]
+	wantKeys := []string{
+		`HKEY_LOCAL_MACHINE\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\{6F5E2A1C-1B2D-4C3E-9F00-0A1B2C3D4E5F}`,
+		`HKEY_LOCAL_MACHINE\SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall\{6F5E2A1C-1B2D-4C3E-9F00-0A1B2C3D4E5F}`,
+	}
+	if got := originKeys(contoso); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("Contoso origins = %q, want %q", got, wantKeys)
+	}
+	if contoso.productCode != "{6F5E2A1C-1B2D-4C3E-9F00-0A1B2C3D4E5F}" {
+		t.Errorf("Contoso product code = %q", contoso.productCode)
+	}
+	if contoso.publisher != "Contoso Ltd." || contoso.version != "2.1.0" || contoso.installDate != "2024-05-01" || contoso.sizeKB != 1024 {
+		t.Errorf("Contoso values = %q %q %q %d", contoso.publisher, contoso.version, contoso.installDate, contoso.sizeKB)
+	}
+	// Values missing from the first key come from the duplicate
+	if contoso.urlInfoAbout != "https://contoso.example" {
+		t.Errorf("Contoso URLInfoAbout = %q, want the WOW6432Node value", contoso.urlInfoAbout)
+	}
+	if !contoso.machine || len(contoso.users) != 0 {
+		t.Errorf("Contoso machine = %v, users = %q; want machine-wide only", contoso.machine, contoso.users)
+	}
+	if len(contoso.updates) != 1 || contoso.updates[0].Name != "Security Update for Contoso Suite (KB5005565)" {
+		t.Errorf("Contoso updates = %+v, want KB5005565", contoso.updates)
+	}
+
+	// Different key names with the same display name and install folder
+	// are one app, installed for the machine and for alice
+	npp := byName["Notepad++ (64-bit x64)"]
+	wantKeys = []string{
+		`HKEY_CURRENT_USER\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\npp`,
+		`HKEY_LOCAL_MACHINE\SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall\Notepad++`,
+	}
+	if got := originKeys(npp); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("Notepad++ origins = %q, want %q", got, wantKeys)
+	}
+	if !npp.machine || !reflect.DeepEqual(npp.users, []string{"alice"}) {
+		t.Errorf("Notepad++ machine = %v, users = %q; want machine-wide and alice", npp.machine, npp.users)
+	}
+	if npp.uninstallString != `"C:\Program Files\Notepad++\uninstall.exe"` {
+		t.Errorf("Notepad++ uninstall string = %q", npp.uninstallString)
+	}
+
+	discord := byName["Discord"]
+	if discord.machine || !reflect.DeepEqual(discord.users, []string{"alice"}) {
+		t.Errorf("Discord machine = %v, users = %q; want alice only", discord.machine, discord.users)
+	}
+}
+
+func TestReadWin32EntriesPerUser(t *testing.T) {
+	reg, err := winreg.LoadJSON("testdata/uninstall_users.json")
+	if err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	profiles := []userProfile{
+		{sid: "S-1-5-21-1001", name: "alice", loaded: true},
+		{sid: "S-1-5-21-1002", name: "bob", loaded: true},
+		{sid: "S-1-5-21-1003", name: "carol"},
+	}
+
+	entries := readWin32Entries(reg, profiles)
+	if len(entries) != 3 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.name+" "+e.identity)
+		}
+		t.Fatalf("entries = %q, want 7-Zip and two Discord installs", names)
+	}
+
+	var discord []win32Entry
+	var sevenZip win32Entry
+	for _, e := range entries {
+		switch e.name {
+		case "Discord":
+			discord = append(discord, e)
+		case "7-Zip 23.01 (x64)":
+			sevenZip = e
+		default:
+			t.Errorf("unexpected entry %q", e.name)
+		}
+	}
+
+	// The same key name in the machine and a user hive, with one install
+	// folder, is one app
+	if len(sevenZip.origins) != 2 || !sevenZip.machine || !reflect.DeepEqual(sevenZip.users, []string{"alice"}) {
+		t.Errorf("7-Zip origins = %q, machine = %v, users = %q", originKeys(sevenZip), sevenZip.machine, sevenZip.users)
+	}
+
+	// Per-user installs of the same app in different folders stay apart,
+	// and the second is identified by its full key path
+	if len(discord) != 2 {
+		t.Fatalf("got %d Discord entries, want 2", len(discord))
+	}
+	if !reflect.DeepEqual(discord[0].users, []string{"alice"}) || discord[0].identity != "Discord" {
+		t.Errorf("first Discord users = %q, identity = %q", discord[0].users, discord[0].identity)
+	}
+	bobKey := `HKEY_USERS\S-1-5-21-1002\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\Discord`
+	if !reflect.DeepEqual(discord[1].users, []string{"bob"}) || discord[1].identity != bobKey {
+		t.Errorf("second Discord users = %q, identity = %q; want bob, %q", discord[1].users, discord[1].identity, bobKey)
+	}
+	if discord[0].source == discord[1].source {
+		t.Errorf("both Discord installs share the cache slot %q", discord[0].source)
+	}
+}
@@ -0,0 +1,87 @@
+package apps
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// epicManifest is the subset of an Epic Games Launcher .item file that
+// discovery needs
+type epicManifest struct {
+	DisplayName         string `json:"DisplayName"`
+	AppName             string `json:"AppName"`
+	InstallLocation     string `json:"InstallLocation"`
+	LaunchExecutable    string `json:"LaunchExecutable"`
+	AppVersionString    string `json:"AppVersionString"`
+	IsIncompleteInstall bool   `json:"bIsIncompleteInstall"`
+	MainGameAppName     string `json:"MainGameAppName"`
+}
+
+// epicManifestDir returns the folder the launcher keeps its .item manifests in
+func epicManifestDir() string {
+	if programData := os.Getenv("ProgramData"); programData != "" {
+		return filepath.Join(programData, `Epic\EpicGamesLauncher\Data\Manifests`)
+	}
+	return ""
+}
+
+// discoverEpicGames reads the launcher's .item manifests
+func discoverEpicGames(dir string) ([]InstalledApp, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := readDirIfExists(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Epic manifests: %w", err)
+	}
+
+	var games []InstalledApp
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".item") {
+			continue
+		}
+		item := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(item)
+		if err != nil {
+			log.Printf("[Enodia] Warning: Could not read %s: %v", item, err)
+			continue
+		}
+		var m epicManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			log.Printf("[Enodia] Warning: Could not parse %s: %v", item, err)
+			continue
+		}
+		// DLC manifests point at the game they extend
+		if m.IsIncompleteInstall || m.InstallLocation == "" ||
+			(m.MainGameAppName != "" && m.MainGameAppName != m.AppName) {
+			continue
+		}
+
+		name := m.DisplayName
+		if name == "" {
+			name = m.AppName
+		}
+		installPath := filepath.Clean(m.InstallLocation)
+		launchExe := ""
+		if m.LaunchExecutable != "" {
+			launchExe = filepath.Join(installPath, m.LaunchExecutable)
+		}
+		games = append(games, newGame("epic", m.AppName, name, installPath, m.AppVersionString, launchExe))
+	}
+
+	log.Printf("[Enodia] Found %d Epic games", len(games))
+	return games, nil
+}
+
+// epicFingerprint covers every launcher manifest
+func epicFingerprint(dir string) string {
+	fp := newFingerprint()
+	if dir != "" {
+		fp.glob(filepath.Join(dir, "*.item"))
+	}
+	return fp.String()
+}
@@ -0,0 +1,266 @@
+package apps
+
+import (
+	"enodia/internal/config"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+const filtersFile = "filters.json"
+
+// Filter rule actions
+const (
+	FilterInclude = "include"
+	FilterExclude = "exclude"
+)
+
+// FilterRule shows or hides the apps it matches. Each field lists
+// case-insensitive wildcard patterns ("*" and "?"); a field matches when
+// any of its patterns matches the whole value, and a rule matches when
+// every field it sets matches. Rules are tried in order and the first
+// match decides; apps no rule matches are shown.
+type FilterRule struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	// Name, Publisher and Path match the app's name, publisher and
+	// install folder; Package matches its package family name
+	Name      []string `json:"name,omitempty"`
+	Publisher []string `json:"publisher,omitempty"`
+	Path      []string `json:"path,omitempty"`
+	Package   []string `json:"package,omitempty"`
+}
+
+// HiddenApp is an app a filter rule kept out of the app list
+type HiddenApp struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Publisher   string `json:"publisher"`
+	InstallPath string `json:"installPath"`
+	Source      string `json:"source"`
+	// PackageSID is set for Store apps, so their rules are not taken
+	// for orphans
+	PackageSID string `json:"packageSid,omitempty"`
+	// Rule is the ID of the rule that hid the app
+	Rule string `json:"rule"`
+}
+
+// DefaultFilterRules hide Windows components, Microsoft runtimes and
+// SDKs, and framework Store packages, but keep Microsoft's user apps
+func DefaultFilterRules() []FilterRule {
+	return []FilterRule{
+		{
+			ID:        "microsoft-apps",
+			Action:    FilterInclude,
+			Publisher: []string{"*microsoft*"},
+			Name:      []string{"*office*", "*visual studio*", "*vscode*", "*edge*", "*teams*", "*onedrive*"},
+		},
+		{
+			ID:        "microsoft-components",
+			Action:    FilterExclude,
+			Publisher: []string{"*microsoft*"},
+			Name:      []string{"*update*", "*redistributable*", "*runtime*", "*.net*", "*sdk*", "*tool*"},
+		},
+		{
+			ID:     "windows-folder",
+			Action: FilterExclude,
+			Path:   []string{`*\windows\*`},
+		},
+		{
+			ID:     "system-packages",
+			Action: FilterExclude,
+			Package: []string{
+				"microsoft.net*", "microsoft.vclibs*", "microsoft.ui*",
+				"microsoft.windows*", "microsoft.services*", "microsoft.advertising*",
+				"microsoft.directx*", "microsoft.desktop*",
+			},
+		},
+	}
+}
+
+// Filters holds the discovery filter rules the user has configured
+type Filters struct {
+	mu    sync.Mutex
+	rules []FilterRule
+}
+
+// LoadFilters reads the saved filter rules, or the defaults if none were saved
+func LoadFilters() *Filters {
+	var saved []FilterRule
+	if err := config.Load(filtersFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load filter rules: %v", err)
+	}
+	// An empty list means the user removed every rule
+	if saved == nil {
+		saved = DefaultFilterRules()
+	}
+	return &Filters{rules: saved}
+}
+
+// Rules returns a copy of the filter rules
+func (f *Filters) Rules() []FilterRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FilterRule(nil), f.rules...)
+}
+
+// Set replaces the filter rules
+func (f *Filters) Set(rules []FilterRule) error {
+	if err := validateFilterRules(rules); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append([]FilterRule{}, rules...)
+	return config.Save(filtersFile, f.rules)
+}
+
+// Reset restores the default filter rules
+func (f *Filters) Reset() error {
+	return f.Set(DefaultFilterRules())
+}
+
+// validateFilterRules checks that rules have unique IDs, a known action
+// and at least one non-empty pattern
+func validateFilterRules(rules []FilterRule) error {
+	ids := make(map[string]bool)
+	for _, r := range rules {
+		if r.ID == "" {
+			return fmt.Errorf("filter rule has no ID")
+		}
+		if ids[strings.ToLower(r.ID)] {
+			return fmt.Errorf("duplicate filter rule %q", r.ID)
+		}
+		ids[strings.ToLower(r.ID)] = true
+		if r.Action != FilterInclude && r.Action != FilterExclude {
+			return fmt.Errorf("filter rule %q: unknown action %q", r.ID, r.Action)
+		}
+		fields := [][]string{r.Name, r.Publisher, r.Path, r.Package}
+		empty := true
+		for _, patterns := range fields {
+			for _, p := range patterns {
+				if p == "" {
+					return fmt.Errorf("filter rule %q has an empty pattern", r.ID)
+				}
+				empty = false
+			}
+		}
+		if empty {
+			return fmt.Errorf("filter rule %q has no patterns", r.ID)
+		}
+	}
+	return nil
+}
+
+// filterTarget is what filter rules look at
+type filterTarget struct {
+	name, publisher, path, pkg string
+}
+
+// hiddenBy returns the ID of the rule that hides target, or ""
+func hiddenBy(rules []FilterRule, t filterTarget) string {
+	for _, r := range rules {
+		if !r.matches(t) {
+			continue
+		}
+		if r.Action == FilterExclude {
+			return r.ID
+		}
+		return ""
+	}
+	return ""
+}
+
+// matches reports whether every field the rule sets matches target
+func (r FilterRule) matches(t filterTarget) bool {
+	fields := []struct {
+		patterns []string
+		value    string
+	}{
+		{r.Name, t.name},
+		{r.Publisher, t.publisher},
+		{r.Path, t.path},
+		{r.Package, t.pkg},
+	}
+	for _, f := range fields {
+		if len(f.patterns) > 0 && !matchAny(f.patterns, f.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterApps splits apps into those the rules show and those they hide
+func filterApps(rules []FilterRule, apps []InstalledApp) ([]InstalledApp, []HiddenApp) {
+	var shown []InstalledApp
+	var hidden []HiddenApp
+	for _, app := range apps {
+		if rule := hiddenBy(rules, appTarget(app)); rule != "" {
+			hidden = append(hidden, hiddenApp(app, rule))
+			continue
+		}
+		shown = append(shown, app)
+	}
+	return shown, hidden
+}
+
+// appTarget returns what filter rules match for app
+func appTarget(app InstalledApp) filterTarget {
+	return filterTarget{app.Name, app.Publisher, app.InstallPath, app.PackageFamilyName}
+}
+
+// hiddenApp describes an app hidden by rule
+func hiddenApp(app InstalledApp, rule string) HiddenApp {
+	return HiddenApp{
+		ID:          app.ID,
+		Name:        app.Name,
+		Publisher:   app.Publisher,
+		InstallPath: app.InstallPath,
+		Source:      app.Source,
+		PackageSID:  app.PackageSID,
+		Rule:        rule,
+	}
+}
+
+// matchAny reports whether any pattern matches s
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if matchWildcard(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard matches s against a pattern where "*" stands for any run
+// of characters and "?" for one, ignoring case. Unlike filepath.Match,
+// backslashes are literal, so patterns can hold Windows paths.
+func matchWildcard(pattern, s string) bool {
+	p := []rune(strings.ToLower(pattern))
+	r := []rune(strings.ToLower(s))
+
+	pi, ri := 0, 0
+	star, mark := -1, 0
+	for ri < len(r) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == r[ri]):
+			pi++
+			ri++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, ri
+			pi++
+		case star >= 0:
+			// Let the last star swallow one more character
+			pi = star + 1
+			mark++
+			ri = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
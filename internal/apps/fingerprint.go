@@ -0,0 +1,90 @@
+package apps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"enodia/internal/winreg"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fingerprint summarizes the on-disk and registry state a discovery
+// source depends on. Two equal fingerprints mean the source would find
+// the same apps again.
+type fingerprint struct {
+	h hash.Hash
+}
+
+func newFingerprint() *fingerprint {
+	return &fingerprint{h: sha256.New()}
+}
+
+// add mixes plain values such as names and versions into the fingerprint
+func (f *fingerprint) add(values ...string) {
+	for _, v := range values {
+		fmt.Fprintf(f.h, "%s\x00", v)
+	}
+}
+
+// file adds the size and modification time of a file or folder
+func (f *fingerprint) file(path string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		f.add(strings.ToLower(path), "missing")
+		return
+	}
+	f.add(strings.ToLower(path), fmt.Sprint(info.Size(), info.ModTime().UnixNano()))
+}
+
+// glob adds every file matching pattern
+func (f *fingerprint) glob(pattern string) {
+	matches, _ := filepath.Glob(pattern)
+	for _, m := range matches {
+		f.file(m)
+	}
+}
+
+// tree adds every file with the given extension below dir
+func (f *fingerprint) tree(dir, ext string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.EqualFold(filepath.Ext(path), ext) {
+			f.file(path)
+		}
+		return nil
+	})
+}
+
+// key adds the last write time of a registry key and of its subkeys
+func (f *fingerprint) key(reg winreg.Reader, root winreg.Root, path string) {
+	key, err := reg.OpenKey(root, path)
+	if err != nil {
+		f.add(root.String(), path, "missing")
+		return
+	}
+	defer key.Close()
+
+	modified, _ := key.LastWriteTime()
+	f.add(root.String(), path, fmt.Sprint(modified.UnixNano()))
+	names, _ := key.SubKeyNames()
+	for _, name := range names {
+		sub, err := key.OpenSubKey(name)
+		if err != nil {
+			continue
+		}
+		modified, _ := sub.LastWriteTime()
+		sub.Close()
+		f.add(name, fmt.Sprint(modified.UnixNano()))
+	}
+}
+
+// String returns the fingerprint as a hex digest
+func (f *fingerprint) String() string {
+	return hex.EncodeToString(f.h.Sum(nil))
+}
@@ -0,0 +1,107 @@
+package apps
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// gameSearchDepth is how many folders deep game executables are looked
+// for; engines like Unreal keep them in Game\Binaries\Win64
+const gameSearchDepth = 4
+
+// redistDirs are folders games ship runtime installers in
+var redistDirs = []string{
+	"_commonredist", "commonredist", "redist", "redistributables",
+	"directx", "vcredist", "dotnet", "__installer", "easyanticheat_installer",
+}
+
+// gameExecutables finds the executables of a game, skipping the runtime
+// installers bundled with it
+func gameExecutables(installPath string) []string {
+	var exes []string
+	filepath.WalkDir(installPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			rel, _ := filepath.Rel(installPath, path)
+			if rel != "." && (strings.Count(rel, string(filepath.Separator)) >= gameSearchDepth || isRedistDir(d.Name())) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".exe") {
+			exes = append(exes, path)
+		}
+		return nil
+	})
+	return exes
+}
+
+// isRedistDir reports whether a folder name is a bundled runtime folder
+func isRedistDir(name string) bool {
+	lower := strings.ToLower(name)
+	for _, dir := range redistDirs {
+		if lower == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// newGame builds the app for an installed game and describes its executables
+func newGame(source, id, name, installPath, version, launchExe string) InstalledApp {
+	app := InstalledApp{
+		ID:          appID(source, id),
+		Name:        name,
+		InstallPath: installPath,
+		AppType:     "win32",
+		Source:      source,
+		Origins:     []Origin{{Source: source, Key: id}},
+		Version:     version,
+		Executables: gameExecutables(installPath),
+	}
+	if launchExe != "" && !containsFold(app.Executables, launchExe) && isUnder(launchExe, installPath) {
+		app.Executables = append([]string{launchExe}, app.Executables...)
+	}
+	if len(app.Executables) > 0 {
+		applyVersionInfo(&app, launchExe)
+		applySignature(&app, launchExe)
+		app.IconBase64 = extractWin32IconBase64(&app, launchExe)
+	}
+	return app
+}
+
+// mergeGames adds games as apps of their own. Executables inside a game's
+// folder are taken away from other apps, so a launcher whose install
+// folder holds its library no longer lists every game as its own.
+func mergeGames(apps, games []InstalledApp) []InstalledApp {
+	for i := range apps {
+		app := &apps[i]
+		if app.AppType != "win32" {
+			continue
+		}
+		for _, game := range games {
+			if game.InstallPath == "" || !isUnder(game.InstallPath, app.InstallPath) {
+				continue
+			}
+			kept := app.Executables[:0]
+			for _, exe := range app.Executables {
+				if !isUnder(exe, game.InstallPath) {
+					kept = append(kept, exe)
+				}
+			}
+			app.Executables = kept
+
+			binaries := app.Binaries[:0]
+			for _, b := range app.Binaries {
+				if !isUnder(b.Path, game.InstallPath) {
+					binaries = append(binaries, b)
+				}
+			}
+			app.Binaries = binaries
+		}
+	}
+	return mergeApps(apps, games)
+}
@@ -0,0 +1,120 @@
+package apps
+
+import (
+	"enodia/internal/winreg"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverSteamGames(t *testing.T) {
+	root := copyFixture(t, "games")
+	reg := winreg.NewFake()
+	reg.SetString(winreg.CurrentUser, `Software\Valve\Steam`, "SteamPath", filepath.Join(root, "Steam"))
+
+	games, err := discoverSteamGames(reg)
+	if err != nil {
+		t.Fatalf("discoverSteamGames: %v", err)
+	}
+	byName := appsByName(t, games)
+	// Redistributables, partial installs and broken manifests are not listed
+	if got, want := sortedNames(byName), []string{"Portal 2", "Team Fortress 2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("games = %q, want %q", got, want)
+	}
+
+	tf2 := byName["Team Fortress 2"]
+	dir := filepath.Join(root, "Steam", "steamapps", "common", "Team Fortress 2")
+	if tf2.ID != appID("steam", "440") || tf2.InstallPath != dir || tf2.Version != "8835751" {
+		t.Errorf("TF2 = %q %q %q", tf2.ID, tf2.InstallPath, tf2.Version)
+	}
+	// Bundled runtime installers are left out
+	wantExes := []string{filepath.Join(dir, "bin", "x64", "vrad.exe"), filepath.Join(dir, "tf_win64.exe")}
+	if !reflect.DeepEqual(tf2.Executables, wantExes) {
+		t.Errorf("TF2 executables = %q, want %q", tf2.Executables, wantExes)
+	}
+
+	if portal := byName["Portal 2"]; portal.InstallPath != filepath.Join(root, "Library", "steamapps", "common", "Portal 2") {
+		t.Errorf("Portal 2 install path = %q, want the second library", portal.InstallPath)
+	}
+}
+
+func TestSteamLibraries(t *testing.T) {
+	root := copyFixture(t, "games")
+
+	libraries, err := steamLibraries(filepath.Join(root, "Steam"))
+	if err != nil {
+		t.Fatalf("steamLibraries: %v", err)
+	}
+	// The Steam folder is listed once, however often the file names it
+	want := []string{filepath.Join(root, "Steam"), filepath.Join(root, "Library")}
+	if !reflect.DeepEqual(libraries, want) {
+		t.Errorf("libraries = %q, want %q", libraries, want)
+	}
+
+	// Without the file, the Steam folder is the only library
+	libraries, err = steamLibraries(filepath.Join(root, "Library"))
+	if err != nil || !reflect.DeepEqual(libraries, []string{filepath.Join(root, "Library")}) {
+		t.Errorf("libraries without the file = %q, %v", libraries, err)
+	}
+}
+
+func TestDiscoverSteamGamesNotInstalled(t *testing.T) {
+	games, err := discoverSteamGames(winreg.NewFake())
+	if err != nil || len(games) != 0 {
+		t.Errorf("discoverSteamGames = %v, %v; want nothing", games, err)
+	}
+}
+
+func TestDiscoverEpicGames(t *testing.T) {
+	root := copyFixture(t, "games")
+
+	games, err := discoverEpicGames(filepath.Join(root, "Epic", "Manifests"))
+	if err != nil {
+		t.Fatalf("discoverEpicGames: %v", err)
+	}
+	// DLC, incomplete installs and broken manifests are not listed
+	if len(games) != 1 {
+		t.Fatalf("games = %+v, want Contoso Quest only", games)
+	}
+	quest := games[0]
+	dir := filepath.Join(root, "Epic Games", "ContosoQuest")
+	if quest.Name != "Contoso Quest" || quest.ID != appID("epic", "Heron") || quest.InstallPath != dir || quest.Version != "1.0.4-CL-123" {
+		t.Errorf("Contoso Quest = %q %q %q %q", quest.Name, quest.ID, quest.InstallPath, quest.Version)
+	}
+	wantExes := []string{filepath.Join(dir, "Quest.exe"), filepath.Join(dir, "QuestGame", "Binaries", "Win64", "Quest.exe")}
+	if !reflect.DeepEqual(quest.Executables, wantExes) {
+		t.Errorf("Contoso Quest executables = %q, want %q", quest.Executables, wantExes)
+	}
+}
+
+func TestDiscoverGOGGames(t *testing.T) {
+	root := copyFixture(t, "games")
+	dir := filepath.Join(root, "GOG Games", "Witcher 3")
+	reg := winreg.NewFake()
+	for _, path := range gogGameKeys {
+		reg.SetString(winreg.LocalMachine, path+`\1207664643`, "gameName", "The Witcher 3")
+		reg.SetString(winreg.LocalMachine, path+`\1207664643`, "path", dir)
+		reg.SetString(winreg.LocalMachine, path+`\1207664643`, "exe", filepath.Join("bin", "x64", "witcher3.exe"))
+		reg.SetString(winreg.LocalMachine, path+`\1207664643`, "ver", "4.04")
+	}
+	reg.SetString(winreg.LocalMachine, gogGameKeys[0]+`\1640424747`, "gameName", "Hearts of Stone")
+	reg.SetString(winreg.LocalMachine, gogGameKeys[0]+`\1640424747`, "path", dir)
+	reg.SetString(winreg.LocalMachine, gogGameKeys[0]+`\1640424747`, "dependsOn", "1207664643")
+
+	games, err := discoverGOGGames(reg)
+	if err != nil {
+		t.Fatalf("discoverGOGGames: %v", err)
+	}
+	// The game registered under both views is listed once, without its DLC
+	if len(games) != 1 {
+		t.Fatalf("games = %+v, want The Witcher 3 only", games)
+	}
+	witcher := games[0]
+	if witcher.Name != "The Witcher 3" || witcher.ID != appID("gog", "1207664643") || witcher.InstallPath != dir || witcher.Version != "4.04" {
+		t.Errorf("Witcher = %q %q %q %q", witcher.Name, witcher.ID, witcher.InstallPath, witcher.Version)
+	}
+	wantExes := []string{filepath.Join(dir, "bin", "x64", "witcher3.exe"), filepath.Join(dir, "unins000.exe")}
+	if !reflect.DeepEqual(witcher.Executables, wantExes) {
+		t.Errorf("Witcher executables = %q, want %q", witcher.Executables, wantExes)
+	}
+}
@@ -0,0 +1,71 @@
+package apps
+
+import (
+	"enodia/internal/winreg"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// gogGameKeys are where GOG Galaxy and the offline installers register games
+var gogGameKeys = []string{
+	`SOFTWARE\WOW6432Node\GOG.com\Games`,
+	`SOFTWARE\GOG.com\Games`,
+}
+
+// discoverGOGGames reads the game entries GOG installers write to the registry
+func discoverGOGGames(reg winreg.Reader) ([]InstalledApp, error) {
+	var games []InstalledApp
+	seen := make(map[string]bool)
+
+	for _, path := range gogGameKeys {
+		key, err := openKeyIfExists(reg, winreg.LocalMachine, path)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to open %s\%s: %w`, winreg.LocalMachine, path, err)
+		}
+		if key == nil {
+			continue
+		}
+		ids, err := key.SubKeyNames()
+		if err != nil {
+			key.Close()
+			return nil, fmt.Errorf(`failed to list %s\%s: %w`, winreg.LocalMachine, path, err)
+		}
+		for _, id := range ids {
+			sub, err := key.OpenSubKey(id)
+			if err != nil {
+				continue
+			}
+			name, _ := sub.GetString("gameName")
+			installPath, _ := sub.GetString("path")
+			exe, _ := sub.GetString("exe")
+			version, _ := sub.GetString("ver")
+			dependsOn, _ := sub.GetString("dependsOn")
+			sub.Close()
+
+			// DLC entries depend on the base game and share its folder
+			if name == "" || installPath == "" || dependsOn != "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			if exe != "" && !filepath.IsAbs(exe) {
+				exe = filepath.Join(installPath, exe)
+			}
+			games = append(games, newGame("gog", id, name, filepath.Clean(installPath), version, exe))
+		}
+		key.Close()
+	}
+
+	log.Printf("[Enodia] Found %d GOG games", len(games))
+	return games, nil
+}
+
+// gogFingerprint covers the GOG game registry keys
+func gogFingerprint(reg winreg.Reader) string {
+	fp := newFingerprint()
+	for _, path := range gogGameKeys {
+		fp.key(reg, winreg.LocalMachine, path)
+	}
+	return fp.String()
+}
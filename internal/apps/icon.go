@@ -0,0 +1,64 @@
+package apps
+
+import (
+	"encoding/base64"
+	"enodia/internal/pe"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var envVarPattern = regexp.MustCompile(`%([^%]+)%`)
+
+// parseIconLocation splits a registry icon location such as
+// `"C:\App\app.exe",-101` or `%ProgramFiles%\App\app.ico` into a path
+// and an icon index
+func parseIconLocation(location string) (string, int) {
+	location = strings.TrimSpace(location)
+	index := 0
+
+	if strings.HasPrefix(location, `"`) {
+		if end := strings.Index(location[1:], `"`); end >= 0 {
+			rest := strings.TrimSpace(location[end+2:])
+			location = location[1 : end+1]
+			if n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(rest, ","))); err == nil {
+				index = n
+			}
+		}
+	} else if comma := strings.LastIndex(location, ","); comma >= 0 {
+		if n, err := strconv.Atoi(strings.TrimSpace(location[comma+1:])); err == nil {
+			index = n
+			location = location[:comma]
+		}
+	}
+
+	return expandEnv(strings.Trim(location, `" `)), index
+}
+
+// expandEnv expands Windows-style %VAR% references
+func expandEnv(s string) string {
+	return envVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := os.LookupEnv(m[1 : len(m)-1]); ok {
+			return v
+		}
+		return m
+	})
+}
+
+// extractWin32IconBase64 extracts the registry icon of a Win32 app, falling
+// back to the first icon of its main executable
+func extractWin32IconBase64(app *InstalledApp, iconLocation string) string {
+	if iconLocation != "" {
+		path, index := parseIconLocation(iconLocation)
+		if data, err := pe.ExtractIconPNG(path, index); err == nil {
+			return base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	if exe := mainExecutable(app, iconLocation); exe != "" {
+		if data, err := pe.ExtractIconPNG(exe, 0); err == nil {
+			return base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package apps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Origin is one place an app was found
+type Origin struct {
+	// Source is one of the values of InstalledApp.Source
+	Source string `json:"source"`
+	// Key identifies the app within its source: a registry key path,
+	// package family name, package or game ID, shortcut target or the
+	// folder of a portable app
+	Key string `json:"key"`
+}
+
+// appID derives an app ID from the app's identity within a source, such
+// as the name of its uninstall key, its package family name or its
+// package ID. Identities ignore case and never include install folders,
+// so IDs survive reinstalls and moves; the truncated SHA-256 keeps
+// collisions out of reach.
+func appID(source, identity string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + strings.ToLower(identity)))
+	return "app_" + hex.EncodeToString(sum[:12])
+}
+
+// addOrigins appends the origins app does not have yet
+func addOrigins(app *InstalledApp, origins []Origin) {
+	for _, o := range origins {
+		if !hasOrigin(app.Origins, o) {
+			app.Origins = append(app.Origins, o)
+		}
+	}
+}
+
+// hasOrigin reports whether origins contain o, ignoring case
+func hasOrigin(origins []Origin, o Origin) bool {
+	for _, existing := range origins {
+		if existing.Source == o.Source && strings.EqualFold(existing.Key, o.Key) {
+			return true
+		}
+	}
+	return false
+}
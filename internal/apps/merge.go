@@ -0,0 +1,103 @@
+package apps
+
+import "strings"
+
+// mergeApps folds apps found by an additional source into the existing
+// list. An incoming app that shares an executable or a name with an
+// existing Win32 app enriches it and adds its origins. The rest is
+// appended unless it has no executables to block.
+func mergeApps(existing, incoming []InstalledApp) []InstalledApp {
+	for _, in := range incoming {
+		idx := findMatch(existing, in)
+		if idx < 0 {
+			if len(in.Executables) > 0 {
+				existing = append(existing, in)
+			}
+			continue
+		}
+
+		absorb(&existing[idx], in)
+	}
+	return existing
+}
+
+// absorb enriches app with what another origin of the same software found
+func absorb(app *InstalledApp, in InstalledApp) {
+	addOrigins(app, in.Origins)
+	// An app without users is there for everyone already
+	if len(app.Users) > 0 {
+		addUsers(app, in.Users)
+	}
+	for _, exe := range in.Executables {
+		if !containsFold(app.Executables, exe) {
+			app.Executables = append(app.Executables, exe)
+		}
+	}
+	for _, b := range in.Binaries {
+		if !hasBinary(app.Binaries, b.Path) {
+			app.Binaries = append(app.Binaries, b)
+		}
+	}
+	for shim, target := range in.Shims {
+		if app.Shims == nil {
+			app.Shims = make(map[string]string)
+		}
+		app.Shims[shim] = target
+	}
+	if app.Version == "" {
+		app.Version = in.Version
+	}
+	if app.Publisher == "" {
+		app.Publisher = in.Publisher
+	}
+	if app.InstallPath == "" {
+		app.InstallPath = in.InstallPath
+	}
+	if app.IconBase64 == "" {
+		app.IconBase64 = in.IconBase64
+	}
+}
+
+// findMatch returns the index of the existing Win32 app that is the same
+// software as app, or -1
+func findMatch(existing []InstalledApp, app InstalledApp) int {
+	for i, e := range existing {
+		if e.AppType != "win32" {
+			continue
+		}
+		for _, exe := range app.Executables {
+			if containsFold(e.Executables, exe) {
+				return i
+			}
+		}
+	}
+
+	name := normalizeName(app.Name)
+	if len(name) < 3 {
+		return -1
+	}
+	for i, e := range existing {
+		if e.AppType == "win32" && normalizeName(e.Name) == name {
+			return i
+		}
+	}
+	// Registry names often append the architecture or locale,
+	// as in "Mozilla Firefox (x64 en-US)"
+	prefix := strings.ToLower(strings.TrimSpace(app.Name)) + " ("
+	for i, e := range existing {
+		if e.AppType == "win32" && strings.HasPrefix(strings.ToLower(e.Name), prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasBinary reports whether binaries describe path
+func hasBinary(binaries []Executable, path string) bool {
+	for _, b := range binaries {
+		if strings.EqualFold(b.Path, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package apps
+
+import (
+	"enodia/internal/config"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const kindsFile = "kinds.json"
+
+// KindOverride is an executable kind set by the user
+type KindOverride struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+}
+
+// KindOverrides holds the user's corrections to executable classification
+type KindOverrides struct {
+	mu    sync.Mutex
+	kinds map[string]KindOverride
+}
+
+// LoadKindOverrides reads the saved kind overrides
+func LoadKindOverrides() *KindOverrides {
+	o := &KindOverrides{kinds: make(map[string]KindOverride)}
+
+	var saved []KindOverride
+	if err := config.Load(kindsFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load executable kinds: %v", err)
+	}
+	for _, k := range saved {
+		o.kinds[strings.ToLower(k.Path)] = k
+	}
+	return o
+}
+
+// Set overrides the kind of an executable
+func (o *KindOverrides) Set(path, kind string) error {
+	if !IsKind(kind) {
+		return fmt.Errorf("unknown executable kind %q", kind)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.kinds[strings.ToLower(path)] = KindOverride{Path: path, Kind: kind}
+	return o.saveLocked()
+}
+
+// Clear returns an executable to its detected kind
+func (o *KindOverrides) Clear(path string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.kinds, strings.ToLower(path))
+	return o.saveLocked()
+}
+
+// Apply sets the KindOverride of every binary of the given apps
+func (o *KindOverrides) Apply(apps []InstalledApp) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range apps {
+		for j := range apps[i].Binaries {
+			b := &apps[i].Binaries[j]
+			b.KindOverride = o.kinds[strings.ToLower(b.Path)].Kind
+		}
+	}
+}
+
+func (o *KindOverrides) saveLocked() error {
+	list := make([]KindOverride, 0, len(o.kinds))
+	for _, k := range o.kinds {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return config.Save(kindsFile, list)
+}
@@ -0,0 +1,58 @@
+package apps
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// maxWorkers caps the discovery pool; most jobs wait on the disk, so
+// more goroutines than this only add contention
+const maxWorkers = 8
+
+// job is one unit of discovery work, such as a source or a registry entry
+type job struct {
+	name string
+	run  func() []InstalledApp
+}
+
+// workerCount returns the size of the discovery pool
+func workerCount() int {
+	n := runtime.NumCPU()
+	if n > maxWorkers {
+		n = maxWorkers
+	}
+	if n < 2 {
+		n = 2
+	}
+	return n
+}
+
+// runJobs runs jobs on at most workers goroutines and calls done with the
+// apps of each job as it finishes. Once ctx is cancelled no further jobs start;
+// runJobs waits for the running ones and returns ctx.Err().
+func runJobs(ctx context.Context, workers int, jobs []job, done func(job, []InstalledApp)) error {
+	queue := make(chan job)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				done(j, j.run())
+			}
+		}()
+	}
+
+feed:
+	for _, j := range jobs {
+		select {
+		case queue <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,269 @@
+package apps
+
+import (
+	"enodia/internal/config"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const portableFile = "portable.json"
+
+// defaultScanDepth is how many folder levels of a scan root are searched
+// unless the root sets its own depth
+const defaultScanDepth = 3
+
+// ScanRoot is a folder the portable app scan searches for executables
+type ScanRoot struct {
+	// Path may hold %VAR% references, as in %USERPROFILE%\Downloads
+	Path string `json:"path"`
+	// Depth is how many folder levels are searched, counting the root
+	// itself; 0 means defaultScanDepth
+	Depth int `json:"depth,omitempty"`
+	// Include, when set, limits the scan to executables matching one of
+	// its patterns; Exclude skips matching executables and folders.
+	// Patterns use the wildcards of filter rules and match full paths,
+	// with a trailing backslash for folders.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// PortableRoots holds the folders the user opted in to scanning for
+// portable and unzipped apps. There are none by default.
+type PortableRoots struct {
+	mu    sync.Mutex
+	roots []ScanRoot
+}
+
+// LoadPortableRoots reads the saved scan roots
+func LoadPortableRoots() *PortableRoots {
+	var saved []ScanRoot
+	if err := config.Load(portableFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load portable scan roots: %v", err)
+	}
+	return &PortableRoots{roots: saved}
+}
+
+// Roots returns a copy of the scan roots
+func (p *PortableRoots) Roots() []ScanRoot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ScanRoot(nil), p.roots...)
+}
+
+// Set replaces the scan roots
+func (p *PortableRoots) Set(roots []ScanRoot) error {
+	for _, r := range roots {
+		if strings.TrimSpace(r.Path) == "" {
+			return fmt.Errorf("scan root has no path")
+		}
+		if r.Depth < 0 {
+			return fmt.Errorf("scan root %s: depth must not be negative", r.Path)
+		}
+		for _, pattern := range append(append([]string(nil), r.Include...), r.Exclude...) {
+			if pattern == "" {
+				return fmt.Errorf("scan root %s has an empty pattern", r.Path)
+			}
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roots = append([]ScanRoot{}, roots...)
+	return config.Save(portableFile, p.roots)
+}
+
+// dir returns the root folder with environment variables expanded
+func (r ScanRoot) dir() string {
+	return filepath.Clean(expandEnv(r.Path))
+}
+
+// executables lists the executables below the root that its depth and
+// patterns allow. Bundled runtime folders are skipped, as for games.
+// Only a root that cannot be read is an error.
+func (r ScanRoot) executables() ([]string, error) {
+	root := r.dir()
+	depth := r.Depth
+	if depth == 0 {
+		depth = defaultScanDepth
+	}
+	sep := string(filepath.Separator)
+
+	var exes []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			if strings.Count(rel, sep)+1 >= depth || isRedistDir(d.Name()) || matchAny(r.Exclude, path+sep) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".exe") || matchAny(r.Exclude, path) {
+			return nil
+		}
+		if len(r.Include) > 0 && !matchAny(r.Include, path) {
+			return nil
+		}
+		exes = append(exes, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return exes, nil
+}
+
+// portableGroup is a set of executables that make up one portable app
+type portableGroup struct {
+	key      string
+	dir      string
+	product  string
+	binaries []Executable
+}
+
+// discoverPortableApps scans the roots and groups the executables into
+// apps by folder and product name: executables in the same top-level
+// folder of a root that share a product name are one app, and those
+// without a product name join the folder's only product. Executables
+// right in a root are grouped by product name alone, or stand on their own.
+func discoverPortableApps(roots []ScanRoot) ([]InstalledApp, error) {
+	var groups []*portableGroup
+	byKey := make(map[string]*portableGroup)
+	seen := make(map[string]bool)
+	var errs []error
+
+	for _, r := range roots {
+		root := r.dir()
+		exes, err := r.executables()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var paths []string
+		for _, exe := range exes {
+			// Roots may overlap
+			if !seen[strings.ToLower(exe)] {
+				seen[strings.ToLower(exe)] = true
+				paths = append(paths, exe)
+			}
+		}
+
+		for _, exe := range describeExecutables(paths) {
+			product := strings.TrimSpace(exe.ProductName)
+			g := &portableGroup{product: product}
+			rel, _ := filepath.Rel(root, exe.Path)
+			if top, _, nested := strings.Cut(rel, string(filepath.Separator)); nested {
+				g.dir = filepath.Join(root, top)
+				g.key = g.dir + "|" + product
+			} else if product != "" {
+				g.key = root + "|" + product
+			} else {
+				g.key = exe.Path
+			}
+
+			if existing, ok := byKey[strings.ToLower(g.key)]; ok {
+				g = existing
+			} else {
+				byKey[strings.ToLower(g.key)] = g
+				groups = append(groups, g)
+			}
+			g.binaries = append(g.binaries, exe)
+		}
+	}
+
+	var apps []InstalledApp
+	for _, g := range foldUnnamedGroups(groups) {
+		apps = append(apps, newPortableApp(g))
+	}
+	log.Printf("[Enodia] Found %d portable apps", len(apps))
+	return apps, errors.Join(errs...)
+}
+
+// foldUnnamedGroups moves the executables of a folder that have no product
+// name into the folder's product group, if it has exactly one
+func foldUnnamedGroups(groups []*portableGroup) []*portableGroup {
+	named := make(map[string][]*portableGroup)
+	for _, g := range groups {
+		if g.dir != "" && g.product != "" {
+			named[strings.ToLower(g.dir)] = append(named[strings.ToLower(g.dir)], g)
+		}
+	}
+
+	var kept []*portableGroup
+	for _, g := range groups {
+		if g.dir != "" && g.product == "" {
+			if owners := named[strings.ToLower(g.dir)]; len(owners) == 1 {
+				owners[0].binaries = append(owners[0].binaries, g.binaries...)
+				continue
+			}
+		}
+		kept = append(kept, g)
+	}
+	return kept
+}
+
+// newPortableApp builds the app for a group of executables. Executables
+// right in a scan root get no install folder, so the root itself, such
+// as Downloads, is never mistaken for the app's folder.
+func newPortableApp(g *portableGroup) InstalledApp {
+	app := InstalledApp{
+		ID:          appID("portable", g.key),
+		Name:        g.product,
+		InstallPath: g.dir,
+		AppType:     "win32",
+		Source:      "portable",
+		Binaries:    g.binaries,
+	}
+	origin := g.dir
+	if origin == "" {
+		origin = g.binaries[0].Path
+	}
+	app.Origins = []Origin{{Source: "portable", Key: origin}}
+	for _, b := range g.binaries {
+		app.Executables = append(app.Executables, b.Path)
+	}
+
+	applyBinaries(&app, "")
+	applySignature(&app, "")
+	main := mainExecutable(&app, "")
+	for _, b := range app.Binaries {
+		if strings.EqualFold(b.Path, main) {
+			app.Version = b.Version
+		}
+	}
+	if app.Name == "" {
+		if g.dir != "" {
+			app.Name = filepath.Base(g.dir)
+		} else {
+			app.Name = strings.TrimSuffix(filepath.Base(main), filepath.Ext(main))
+		}
+	}
+	app.IconBase64 = extractWin32IconBase64(&app, "")
+	return app
+}
+
+// portableFingerprint covers the scan settings and every executable the
+// scan would look at, so adding, removing or updating one rescans
+func portableFingerprint(roots []ScanRoot) string {
+	fp := newFingerprint()
+	for _, r := range roots {
+		fp.add(r.Path, fmt.Sprint(r.Depth), strings.Join(r.Include, "|"), strings.Join(r.Exclude, "|"))
+		exes, _ := r.executables()
+		for _, exe := range exes {
+			fp.file(exe)
+		}
+	}
+	return fp.String()
+}
@@ -0,0 +1,198 @@
+package apps
+
+import (
+	"enodia/internal/winreg"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// profileListPath lists the user profiles on this machine with their folders
+const profileListPath = `SOFTWARE\Microsoft\Windows NT\CurrentVersion\ProfileList`
+
+// userProfile is a user account with a profile on this machine
+type userProfile struct {
+	sid string
+	// name is the profile folder name, which Windows takes from the
+	// account name when it creates the profile
+	name string
+	dir  string
+	// loaded reports whether the user's hive is loaded under HKEY_USERS,
+	// which it is while the user is signed in or has processes running
+	loaded bool
+}
+
+// userProfiles lists the profiles of local, domain and Azure AD accounts.
+// Service accounts such as LocalSystem are left out.
+func userProfiles(reg winreg.Reader) []userProfile {
+	var profiles []userProfile
+	seen := make(map[string]bool)
+
+	if key, err := reg.OpenKey(winreg.LocalMachine, profileListPath); err == nil {
+		sids, _ := key.SubKeyNames()
+		for _, sid := range sids {
+			if !isUserSID(sid) {
+				continue
+			}
+			sub, err := key.OpenSubKey(sid)
+			if err != nil {
+				continue
+			}
+			dir, _ := sub.GetString("ProfileImagePath")
+			sub.Close()
+			if dir == "" {
+				continue
+			}
+			dir = expandEnv(dir)
+			profiles = append(profiles, userProfile{sid: sid, name: filepath.Base(dir), dir: dir})
+			seen[strings.ToUpper(sid)] = true
+		}
+		key.Close()
+	}
+
+	if key, err := reg.OpenKey(winreg.Users, ""); err == nil {
+		sids, _ := key.SubKeyNames()
+		key.Close()
+		for _, sid := range sids {
+			if !isUserSID(sid) {
+				continue
+			}
+			if !seen[strings.ToUpper(sid)] {
+				profiles = append(profiles, userProfile{sid: sid, name: sid})
+			}
+			for i := range profiles {
+				if strings.EqualFold(profiles[i].sid, sid) {
+					profiles[i].loaded = true
+				}
+			}
+		}
+	}
+
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].sid < profiles[j].sid })
+	return profiles
+}
+
+// isUserSID reports whether sid names a local, domain or Azure AD account.
+// The _Classes hives loaded next to each user's hive are not accounts.
+func isUserSID(sid string) bool {
+	upper := strings.ToUpper(sid)
+	if strings.HasSuffix(upper, "_CLASSES") {
+		return false
+	}
+	return strings.HasPrefix(upper, "S-1-5-21-") || strings.HasPrefix(upper, "S-1-12-1-")
+}
+
+// userKeyRoot is the root of one user's keys: HKEY_USERS\<SID>, or
+// HKEY_CURRENT_USER
+type userKeyRoot struct {
+	root   winreg.Root
+	prefix string
+	user   string
+}
+
+// path returns the full path of a key below the user's root
+func (r userKeyRoot) path(path string) string {
+	return r.prefix + path
+}
+
+// userKeyRoots returns where the registry keys of each signed-in user
+// live, by user name. Without any loaded hive, as when profiles cannot be
+// listed, it falls back to the current user's.
+func userKeyRoots(profiles []userProfile) []userKeyRoot {
+	var roots []userKeyRoot
+	for _, p := range profiles {
+		if p.loaded {
+			roots = append(roots, userKeyRoot{winreg.Users, p.sid + `\`, p.name})
+		}
+	}
+	if len(roots) == 0 {
+		roots = append(roots, userKeyRoot{winreg.CurrentUser, "", os.Getenv("USERNAME")})
+	}
+	return roots
+}
+
+// profileProgramsDirs returns the per-user Programs folder of every
+// profile, where per-user installers put apps, keyed by user name
+func profileProgramsDirs(profiles []userProfile) map[string]string {
+	dirs := make(map[string]string)
+	for _, p := range profiles {
+		if p.dir != "" {
+			dirs[p.name] = filepath.Join(p.dir, `AppData\Local\Programs`)
+		}
+	}
+	if len(dirs) == 0 {
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			dirs[os.Getenv("USERNAME")] = filepath.Join(local, "Programs")
+		}
+	}
+	return dirs
+}
+
+// discoverProfileApps finds the apps in each user's Programs folder, one
+// app per subfolder. Most are also registered in that user's uninstall
+// key; for users whose hive is not loaded, these folders are all there is.
+func discoverProfileApps(dirs map[string]string) []InstalledApp {
+	var apps []InstalledApp
+	for _, user := range sortedKeys(dirs) {
+		entries, err := os.ReadDir(dirs[user])
+		if err != nil {
+			continue
+		}
+		for _, d := range entries {
+			if !d.IsDir() {
+				continue
+			}
+			installPath := filepath.Join(dirs[user], d.Name())
+			app := InstalledApp{
+				ID:          appID("profile", user+`\`+d.Name()),
+				Name:        d.Name(),
+				InstallPath: installPath,
+				AppType:     "win32",
+				Source:      "profile",
+				Origins:     []Origin{{Source: "profile", Key: installPath}},
+				Users:       []string{user},
+				Executables: findExecutables(installPath),
+			}
+			if len(app.Executables) == 0 {
+				continue
+			}
+			applyVersionInfo(&app, "")
+			applySignature(&app, "")
+			app.IconBase64 = extractWin32IconBase64(&app, "")
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// profileFingerprint covers the app folders in each user's Programs folder
+func profileFingerprint(dirs map[string]string) string {
+	fp := newFingerprint()
+	for _, user := range sortedKeys(dirs) {
+		fp.add(user)
+		fp.file(dirs[user])
+		fp.glob(filepath.Join(dirs[user], "*"))
+	}
+	return fp.String()
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// addUsers adds users to the app's list, keeping it sorted
+func addUsers(app *InstalledApp, users []string) {
+	for _, u := range users {
+		if !containsFold(app.Users, u) {
+			app.Users = append(app.Users, u)
+		}
+	}
+	sort.Strings(app.Users)
+}
@@ -0,0 +1,200 @@
+package apps
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// scoopManifest is the subset of a Scoop manifest.json that discovery needs
+type scoopManifest struct {
+	Version   string          `json:"version"`
+	Bin       json.RawMessage `json:"bin"`
+	Shortcuts [][]string      `json:"shortcuts"`
+}
+
+// scoopRoots returns the user and global Scoop installation folders
+func scoopRoots() []string {
+	var roots []string
+	if root := os.Getenv("SCOOP"); root != "" {
+		roots = append(roots, root)
+	} else if home := os.Getenv("USERPROFILE"); home != "" {
+		roots = append(roots, filepath.Join(home, "scoop"))
+	}
+	if root := os.Getenv("SCOOP_GLOBAL"); root != "" {
+		roots = append(roots, root)
+	} else if programData := os.Getenv("ProgramData"); programData != "" {
+		roots = append(roots, filepath.Join(programData, "scoop"))
+	}
+	return roots
+}
+
+// discoverScoopApps reads apps/*/current/manifest.json of each Scoop root
+// and links the shims in the shims folder back to the real binaries
+func discoverScoopApps(roots []string) ([]InstalledApp, error) {
+	var apps []InstalledApp
+
+	for _, root := range roots {
+		appDirs, err := readDirIfExists(filepath.Join(root, "apps"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Scoop apps: %w", err)
+		}
+		shims := readScoopShims(filepath.Join(root, "shims"))
+
+		for _, d := range appDirs {
+			if !d.IsDir() || strings.EqualFold(d.Name(), "scoop") {
+				continue
+			}
+
+			current := filepath.Join(root, "apps", d.Name(), "current")
+			data, err := os.ReadFile(filepath.Join(current, "manifest.json"))
+			if err != nil {
+				continue
+			}
+			var m scoopManifest
+			if err := json.Unmarshal(data, &m); err != nil {
+				log.Printf("[Enodia] Warning: Could not parse Scoop manifest for %s: %v", d.Name(), err)
+				continue
+			}
+
+			// "current" is a junction; rules must name the real path
+			installPath := current
+			if resolved, err := filepath.EvalSymlinks(current); err == nil {
+				installPath = resolved
+			}
+
+			app := InstalledApp{
+				ID:          appID("scoop", d.Name()),
+				Name:        scoopDisplayName(d.Name(), m),
+				InstallPath: installPath,
+				AppType:     "win32",
+				Source:      "scoop",
+				Origins:     []Origin{{Source: "scoop", Key: d.Name()}},
+				Version:     m.Version,
+			}
+
+			for _, bin := range scoopBinaries(m) {
+				exe := filepath.Join(installPath, bin)
+				if _, err := os.Stat(exe); err == nil {
+					app.Executables = append(app.Executables, exe)
+				}
+			}
+			for _, exe := range findExecutables(installPath) {
+				if !containsFold(app.Executables, exe) {
+					app.Executables = append(app.Executables, exe)
+				}
+			}
+			for shim, target := range shims {
+				if isUnder(target, installPath) || isUnder(target, current) {
+					if app.Shims == nil {
+						app.Shims = make(map[string]string)
+					}
+					app.Shims[shim] = swapDir(target, current, installPath)
+				}
+			}
+			if len(app.Executables) == 0 {
+				continue
+			}
+
+			applyVersionInfo(&app, "")
+			applySignature(&app, "")
+			app.IconBase64 = extractWin32IconBase64(&app, "")
+			apps = append(apps, app)
+		}
+	}
+
+	log.Printf("[Enodia] Found %d Scoop apps", len(apps))
+	return apps, nil
+}
+
+// scoopDisplayName prefers the Start Menu name the manifest declares
+func scoopDisplayName(name string, m scoopManifest) string {
+	for _, s := range m.Shortcuts {
+		if len(s) >= 2 && s[1] != "" {
+			return filepath.Base(s[1])
+		}
+	}
+	return name
+}
+
+// scoopBinaries lists the .exe files a manifest exposes through "bin" and
+// "shortcuts". "bin" is a string, a list of strings, or a list of
+// [path, alias, args] entries.
+func scoopBinaries(m scoopManifest) []string {
+	var bins []string
+	add := func(p string) {
+		if strings.EqualFold(filepath.Ext(p), ".exe") && !containsFold(bins, p) {
+			bins = append(bins, p)
+		}
+	}
+
+	var single string
+	var list []json.RawMessage
+	if err := json.Unmarshal(m.Bin, &single); err == nil {
+		add(single)
+	} else if err := json.Unmarshal(m.Bin, &list); err == nil {
+		for _, item := range list {
+			var entry []string
+			if err := json.Unmarshal(item, &single); err == nil {
+				add(single)
+			} else if err := json.Unmarshal(item, &entry); err == nil && len(entry) > 0 {
+				add(entry[0])
+			}
+		}
+	}
+
+	for _, s := range m.Shortcuts {
+		if len(s) > 0 {
+			add(s[0])
+		}
+	}
+	return bins
+}
+
+// swapDir moves a path under dir, matched ignoring case, to the same place
+// under to. Paths outside dir are returned unchanged.
+func swapDir(path, dir, to string) string {
+	p := filepath.Clean(path)
+	d := strings.TrimSuffix(filepath.Clean(dir), string(filepath.Separator))
+	if len(p) <= len(d) || p[len(d)] != filepath.Separator || !strings.EqualFold(p[:len(d)], d) {
+		return path
+	}
+	return filepath.Join(to, p[len(d)+1:])
+}
+
+// readScoopShims maps each shim executable to the path in its .shim file
+func readScoopShims(dir string) map[string]string {
+	shims := make(map[string]string)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.shim"))
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), "=")
+			if ok && strings.TrimSpace(key) == "path" {
+				target := strings.Trim(strings.TrimSpace(value), `"`)
+				shims[strings.TrimSuffix(file, ".shim")+".exe"] = target
+				break
+			}
+		}
+		f.Close()
+	}
+	return shims
+}
+
+// scoopFingerprint covers the current manifest of every app and the shims
+func scoopFingerprint(roots []string) string {
+	fp := newFingerprint()
+	for _, root := range roots {
+		fp.glob(filepath.Join(root, "apps", "*", "current", "manifest.json"))
+		fp.file(filepath.Join(root, "shims"))
+	}
+	return fp.String()
+}
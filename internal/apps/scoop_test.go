@@ -0,0 +1,104 @@
+package apps
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverScoopApps(t *testing.T) {
+	root := copyFixture(t, "scoop")
+	symlink(t, filepath.Join(root, "apps", "contoso", "1.2.3"), filepath.Join(root, "apps", "contoso", "current"))
+
+	apps, err := discoverScoopApps([]string{root, filepath.Join(root, "missing")})
+	if err != nil {
+		t.Fatalf("discoverScoopApps: %v", err)
+	}
+	byName := appsByName(t, apps)
+	// Scoop itself, apps without executables and broken manifests are
+	// not listed
+	if got, want := sortedNames(byName), []string{"Contoso Editor", "jq"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("apps = %q, want %q", got, want)
+	}
+
+	// Rules name the version folder behind the "current" junction
+	contoso := byName["Contoso Editor"]
+	installPath, err := filepath.EvalSymlinks(filepath.Join(root, "apps", "contoso", "current"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contoso.InstallPath != installPath || contoso.Version != "1.2.3" || contoso.Source != "scoop" {
+		t.Errorf("Contoso = %q %q %q", contoso.InstallPath, contoso.Version, contoso.Source)
+	}
+	wantExes := []string{
+		filepath.Join(installPath, "bin", "contoso-cli.exe"),
+		filepath.Join(installPath, "Contoso.exe"),
+		filepath.Join(installPath, "updater.exe"),
+	}
+	if !reflect.DeepEqual(contoso.Executables, wantExes) {
+		t.Errorf("Contoso executables = %q, want %q", contoso.Executables, wantExes)
+	}
+	// Shims pointing through the junction, in any case, name the real path
+	wantShims := map[string]string{
+		filepath.Join(root, "shims", "contoso.exe"): filepath.Join(installPath, "Contoso.exe"),
+		filepath.Join(root, "shims", "ct.exe"):      filepath.Join(installPath, "bin", "contoso-cli.exe"),
+	}
+	if !reflect.DeepEqual(contoso.Shims, wantShims) {
+		t.Errorf("Contoso shims = %q, want %q", contoso.Shims, wantShims)
+	}
+
+	jq := byName["jq"]
+	current := filepath.Join(root, "apps", "jq", "current")
+	if jq.Version != "1.7.1" || !reflect.DeepEqual(jq.Executables, []string{filepath.Join(current, "jq.exe")}) {
+		t.Errorf("jq = %q %q", jq.Version, jq.Executables)
+	}
+	if got := jq.Shims[filepath.Join(root, "shims", "jq.exe")]; got != filepath.Join(current, "jq.exe") {
+		t.Errorf("jq shim target = %q", got)
+	}
+}
+
+func TestScoopBinaries(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{"string", `{"bin": "app.exe"}`, []string{"app.exe"}},
+		{"list", `{"bin": ["app.exe", "tool.EXE", "script.ps1"]}`, []string{"app.exe", "tool.EXE"}},
+		{"aliases", `{"bin": [["bin/app.exe", "app", "--flag"], ["APP.exe"]]}`, []string{"bin/app.exe", "APP.exe"}},
+		{"shortcuts", `{"bin": "app.exe", "shortcuts": [["App.exe", "App"], ["gui.exe", "GUI"]]}`, []string{"app.exe", "gui.exe"}},
+		{"none", `{}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m scoopManifest
+			if err := json.Unmarshal([]byte(tt.manifest), &m); err != nil {
+				t.Fatal(err)
+			}
+			if got := scoopBinaries(m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scoopBinaries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSwapDir(t *testing.T) {
+	dir := filepath.Join("scoop", "apps", "app", "current")
+	to := filepath.Join("scoop", "apps", "app", "1.0")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(dir, "app.exe"), filepath.Join(to, "app.exe")},
+		{filepath.Join("scoop", "Apps", "APP", "Current", "bin", "app.exe"), filepath.Join(to, "bin", "app.exe")},
+		{filepath.Join("scoop", "apps", "app", "current2", "app.exe"), filepath.Join("scoop", "apps", "app", "current2", "app.exe")},
+		{dir, dir},
+	}
+	for _, tt := range tests {
+		if got := swapDir(tt.path, dir, to); got != tt.want {
+			t.Errorf("swapDir(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package apps
+
+import (
+	"enodia/internal/lnk"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// shortcut is a Start Menu entry that points to an executable
+type shortcut struct {
+	Name         string
+	Target       string
+	IconLocation string
+}
+
+// startMenuDirs returns the all-users and per-user Start Menu program folders
+func startMenuDirs() []string {
+	var dirs []string
+	if programData := os.Getenv("ProgramData"); programData != "" {
+		dirs = append(dirs, filepath.Join(programData, `Microsoft\Windows\Start Menu\Programs`))
+	}
+	if appData := os.Getenv("APPDATA"); appData != "" {
+		dirs = append(dirs, filepath.Join(appData, `Microsoft\Windows\Start Menu\Programs`))
+	}
+	return dirs
+}
+
+// discoverShortcuts collects the Start Menu shortcuts that point to executables
+func discoverShortcuts(dirs []string) []shortcut {
+	var shortcuts []shortcut
+	seen := make(map[string]bool)
+
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".lnk") {
+				return nil
+			}
+
+			link, err := lnk.Open(path)
+			if err != nil {
+				return nil
+			}
+			target := expandEnv(link.Target)
+			if target == "" && link.RelativePath != "" {
+				target = filepath.Join(filepath.Dir(path), link.RelativePath)
+			}
+			if !strings.EqualFold(filepath.Ext(target), ".exe") || seen[strings.ToLower(target)] {
+				return nil
+			}
+			if _, err := os.Stat(target); err != nil {
+				return nil
+			}
+
+			name := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+			if isUninstallerName(name) || isUninstallerName(filepath.Base(target)) {
+				return nil
+			}
+
+			s := shortcut{Name: name, Target: target}
+			if link.IconLocation != "" {
+				s.IconLocation = fmt.Sprintf("%s,%d", link.IconLocation, link.IconIndex)
+			}
+			shortcuts = append(shortcuts, s)
+			seen[strings.ToLower(target)] = true
+			return nil
+		})
+	}
+	return shortcuts
+}
+
+// mergeShortcuts attaches shortcut targets to the registry apps they belong
+// to and turns the remaining shortcuts into apps of their own, except those
+// pointing into the Windows folder
+func mergeShortcuts(apps []InstalledApp, shortcuts []shortcut) []InstalledApp {
+	changed := make(map[int]string)
+	added := make(map[string]int)
+
+	for _, s := range shortcuts {
+		idx := ownerOf(apps, s)
+		if idx >= 0 {
+			app := &apps[idx]
+			addOrigins(app, []Origin{{Source: "startmenu", Key: s.Target}})
+			if containsFold(app.Executables, s.Target) {
+				continue
+			}
+			if app.InstallPath == "" {
+				app.InstallPath = filepath.Dir(s.Target)
+				app.Executables = findExecutables(app.InstallPath)
+			}
+			if !containsFold(app.Executables, s.Target) {
+				app.Executables = append(app.Executables, s.Target)
+			}
+			changed[idx] = s.IconLocation
+			continue
+		}
+		// Shortcuts to Windows tools, such as Command Prompt, would turn
+		// the whole system folder into an app
+		if isSystemPath(s.Target) {
+			continue
+		}
+
+		dir := filepath.Dir(s.Target)
+		if i, ok := added[strings.ToLower(dir)]; ok {
+			addOrigins(&apps[i], []Origin{{Source: "startmenu", Key: s.Target}})
+			if !containsFold(apps[i].Executables, s.Target) {
+				apps[i].Executables = append(apps[i].Executables, s.Target)
+			}
+			continue
+		}
+
+		app := InstalledApp{
+			ID:          appID("startmenu", s.Target),
+			Name:        s.Name,
+			InstallPath: dir,
+			Executables: findExecutables(dir),
+			AppType:     "win32",
+			Source:      "startmenu",
+			Origins:     []Origin{{Source: "startmenu", Key: s.Target}},
+		}
+		if !containsFold(app.Executables, s.Target) {
+			app.Executables = append(app.Executables, s.Target)
+		}
+		apps = append(apps, app)
+		added[strings.ToLower(dir)] = len(apps) - 1
+		changed[len(apps)-1] = s.IconLocation
+	}
+
+	for i, icon := range changed {
+		app := &apps[i]
+		applyVersionInfo(app, icon)
+		if app.IconBase64 == "" {
+			app.IconBase64 = extractWin32IconBase64(app, icon)
+		}
+	}
+	return apps
+}
+
+// ownerOf finds the app a shortcut belongs to: one that already lists the
+// target, one whose install folder contains it, or one with the same name
+func ownerOf(apps []InstalledApp, s shortcut) int {
+	for i, app := range apps {
+		if app.AppType == "win32" && containsFold(app.Executables, s.Target) {
+			return i
+		}
+	}
+	for i, app := range apps {
+		if app.AppType == "win32" && app.InstallPath != "" && isUnder(s.Target, app.InstallPath) {
+			return i
+		}
+	}
+	name := normalizeName(s.Name)
+	if name == "" {
+		return -1
+	}
+	for i, app := range apps {
+		if app.AppType == "win32" && app.InstallPath == "" && normalizeName(app.Name) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// isSystemPath reports whether path lies inside the Windows folder
+func isSystemPath(path string) bool {
+	for _, name := range []string{"SystemRoot", "windir"} {
+		if dir := os.Getenv(name); dir != "" && isUnder(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUninstallerName reports whether a shortcut or file name looks like an uninstaller
+func isUninstallerName(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.Contains(lower, "uninstall") || strings.HasPrefix(lower, "unins")
+}
+
+// isUnder reports whether path lies inside dir, ignoring case
+func isUnder(path, dir string) bool {
+	sep := string(filepath.Separator)
+	p := strings.ToLower(filepath.Clean(path))
+	d := strings.ToLower(filepath.Clean(dir))
+	return strings.HasPrefix(p, strings.TrimSuffix(d, sep)+sep)
+}
+
+// containsFold reports whether list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeName lowercases a name and keeps only its letters and digits
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// shortcutsFingerprint covers every shortcut file in the Start Menu folders
+func shortcutsFingerprint(dirs []string) string {
+	fp := newFingerprint()
+	for _, dir := range dirs {
+		fp.tree(dir, ".lnk")
+	}
+	return fp.String()
+}
@@ -0,0 +1,54 @@
+package apps
+
+import (
+	"enodia/internal/pe"
+	"path/filepath"
+	"strings"
+)
+
+// applySignature fills the signer fields from the app's main executable
+func applySignature(app *InstalledApp, iconPath string) {
+	exe := mainExecutable(app, iconPath)
+	if exe == "" {
+		return
+	}
+	sig, err := pe.ReadSignature(exe)
+	if err != nil {
+		return
+	}
+	app.SignerSubject = sig.Subject
+	app.SignerIssuer = sig.Issuer
+	app.SignerNotBefore = sig.NotBefore
+	app.SignerNotAfter = sig.NotAfter
+	app.SignatureValid = sig.Valid
+}
+
+// mainExecutable guesses which executable represents the app: the one the
+// registry uses as icon, then one named like the app, then the first
+// that is not an updater, uninstaller or crash reporter
+func mainExecutable(app *InstalledApp, iconPath string) string {
+	if len(app.Executables) == 0 {
+		return ""
+	}
+
+	iconExe, _ := parseIconLocation(iconPath)
+	for _, exe := range app.Executables {
+		if strings.EqualFold(exe, iconExe) {
+			return exe
+		}
+	}
+
+	appName := strings.ToLower(strings.ReplaceAll(app.Name, " ", ""))
+	for _, exe := range app.Executables {
+		base := strings.ToLower(strings.TrimSuffix(filepath.Base(exe), filepath.Ext(exe)))
+		if base != "" && strings.Contains(appName, base) {
+			return exe
+		}
+	}
+	for _, exe := range app.Executables {
+		if heuristicKind(Executable{Path: exe}) == "" {
+			return exe
+		}
+	}
+	return app.Executables[0]
+}
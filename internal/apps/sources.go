@@ -0,0 +1,325 @@
+package apps
+
+import (
+	"context"
+	"enodia/internal/config"
+	"enodia/internal/winreg"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const sourcesFile = "sources.json"
+
+// Base stages: the registry and Start Menu apps every other source is
+// merged into. They can be turned off but always run first.
+const (
+	SourceRegistry  = "registry"
+	SourceStartMenu = "startmenu"
+)
+
+// Source is a place apps are discovered from, such as a package manager
+// or a game launcher
+type Source interface {
+	// Name identifies the source in settings, reports and the cache
+	Name() string
+	// Capabilities describe what the source finds
+	Capabilities() Capabilities
+	// Discover finds the source's apps. Apps several sources find are
+	// merged afterwards.
+	Discover(ctx context.Context) ([]InstalledApp, error)
+}
+
+// fingerprinter is implemented by sources whose results are cached:
+// Discover only runs again once the fingerprint changes
+type fingerprinter interface {
+	Fingerprint() string
+}
+
+// Capabilities describe what a source finds and how its apps are merged
+type Capabilities struct {
+	// Games are merged as apps of their own, and other apps give up the
+	// executables inside their folders
+	Games bool `json:"games"`
+	// Packaged apps are Store packages, which never overlap other apps
+	Packaged bool `json:"packaged"`
+	// Users reports that the source tags apps with the users who have them
+	Users bool `json:"users"`
+	// OptIn sources find nothing until they are configured
+	OptIn bool `json:"optIn"`
+}
+
+// merge folds the apps of a source with these capabilities into apps
+func (c Capabilities) merge(apps, found []InstalledApp) []InstalledApp {
+	switch {
+	case c.Games:
+		return mergeGames(apps, found)
+	case c.Packaged:
+		return append(apps, found...)
+	}
+	return mergeApps(apps, found)
+}
+
+// SourceReport tells how a source did in a discovery
+type SourceReport struct {
+	Name string `json:"name"`
+	// Apps counts the apps the source found, before merging and filtering
+	Apps int `json:"apps"`
+	// Cached is set when the results were reused from an earlier run
+	Cached     bool  `json:"cached"`
+	DurationMs int64 `json:"durationMs"`
+	// Error is why the source failed; a source that fails keeps the
+	// results of its last successful run, if any
+	Error string `json:"error,omitempty"`
+}
+
+// SourceSetting turns a source on or off. The order of the settings is
+// the order sources are merged in.
+type SourceSetting struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// SourceInfo describes an available source with its setting
+type SourceInfo struct {
+	Name         string       `json:"name"`
+	Capabilities Capabilities `json:"capabilities"`
+	Enabled      bool         `json:"enabled"`
+}
+
+// sourceEnv is what the built-in sources are made from
+type sourceEnv struct {
+	reg      winreg.Reader
+	profiles []userProfile
+	opts     Options
+}
+
+// sourceDef is a source in the registry of sources
+type sourceDef struct {
+	name string
+	caps Capabilities
+	// build makes the source for one discovery; base stages have none
+	build func(env sourceEnv) *sourceFuncs
+}
+
+// sourceRegistry lists every source in its default order
+var sourceRegistry = []sourceDef{
+	{SourceRegistry, Capabilities{Users: true}, nil},
+	{SourceStartMenu, Capabilities{}, nil},
+	{"scoop", Capabilities{}, func(env sourceEnv) *sourceFuncs {
+		roots := scoopRoots()
+		return &sourceFuncs{
+			fingerprint: func() string { return scoopFingerprint(roots) },
+			discover:    func(context.Context) ([]InstalledApp, error) { return discoverScoopApps(roots) },
+		}
+	}},
+	{"chocolatey", Capabilities{}, func(env sourceEnv) *sourceFuncs {
+		root := chocolateyRoot()
+		return &sourceFuncs{
+			fingerprint: func() string { return chocolateyFingerprint(root) },
+			discover:    func(context.Context) ([]InstalledApp, error) { return discoverChocolateyApps(root) },
+		}
+	}},
+	{"winget", Capabilities{}, func(env sourceEnv) *sourceFuncs {
+		packages, links := wingetDirs()
+		return &sourceFuncs{
+			fingerprint: func() string { return wingetFingerprint(env.reg, env.profiles, packages, links) },
+			discover: func(context.Context) ([]InstalledApp, error) {
+				return discoverWingetApps(env.reg, env.profiles, packages, links)
+			},
+		}
+	}},
+	{"profiles", Capabilities{Users: true}, func(env sourceEnv) *sourceFuncs {
+		dirs := profileProgramsDirs(env.profiles)
+		return &sourceFuncs{
+			fingerprint: func() string { return profileFingerprint(dirs) },
+			discover:    func(context.Context) ([]InstalledApp, error) { return discoverProfileApps(dirs), nil },
+		}
+	}},
+	{"steam", Capabilities{Games: true}, func(env sourceEnv) *sourceFuncs {
+		return &sourceFuncs{
+			fingerprint: func() string { return steamFingerprint(env.reg) },
+			discover:    func(context.Context) ([]InstalledApp, error) { return discoverSteamGames(env.reg) },
+		}
+	}},
+	{"epic", Capabilities{Games: true}, func(env sourceEnv) *sourceFuncs {
+		dir := epicManifestDir()
+		return &sourceFuncs{
+			fingerprint: func() string { return epicFingerprint(dir) },
+			discover:    func(context.Context) ([]InstalledApp, error) { return discoverEpicGames(dir) },
+		}
+	}},
+	{"gog", Capabilities{Games: true}, func(env sourceEnv) *sourceFuncs {
+		return &sourceFuncs{
+			fingerprint: func() string { return gogFingerprint(env.reg) },
+			discover:    func(context.Context) ([]InstalledApp, error) { return discoverGOGGames(env.reg) },
+		}
+	}},
+	{"portable", Capabilities{OptIn: true}, func(env sourceEnv) *sourceFuncs {
+		roots := env.opts.PortableRoots
+		return &sourceFuncs{
+			fingerprint: func() string { return portableFingerprint(roots) },
+			discover:    func(context.Context) ([]InstalledApp, error) { return discoverPortableApps(roots) },
+		}
+	}},
+	{"store", Capabilities{Packaged: true, Users: true}, func(env sourceEnv) *sourceFuncs {
+		return &sourceFuncs{
+			fingerprint: func() string { return storeFingerprint(env.reg, env.profiles) },
+			discover: func(ctx context.Context) ([]InstalledApp, error) {
+				return discoverStoreApps(ctx, env.reg, env.profiles)
+			},
+		}
+	}},
+}
+
+// sourceFuncs implements Source with functions
+type sourceFuncs struct {
+	name        string
+	caps        Capabilities
+	fingerprint func() string
+	discover    func(ctx context.Context) ([]InstalledApp, error)
+}
+
+func (s *sourceFuncs) Name() string               { return s.name }
+func (s *sourceFuncs) Capabilities() Capabilities { return s.caps }
+func (s *sourceFuncs) Fingerprint() string        { return s.fingerprint() }
+func (s *sourceFuncs) Discover(ctx context.Context) ([]InstalledApp, error) {
+	return s.discover(ctx)
+}
+
+// findSourceDef returns the registered source with the given name
+func findSourceDef(name string) (sourceDef, bool) {
+	for _, def := range sourceRegistry {
+		if def.name == name {
+			return def, true
+		}
+	}
+	return sourceDef{}, false
+}
+
+// buildSources makes the enabled pluggable sources in the given order
+func buildSources(env sourceEnv, settings []SourceSetting) []Source {
+	var sources []Source
+	for _, s := range resolveSourceSettings(settings) {
+		def, _ := findSourceDef(s.Name)
+		if !s.Enabled || def.build == nil {
+			continue
+		}
+		src := def.build(env)
+		src.name, src.caps = def.name, def.caps
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// sourceEnabled reports whether the settings leave a source on
+func sourceEnabled(settings []SourceSetting, name string) bool {
+	for _, s := range resolveSourceSettings(settings) {
+		if s.Name == name {
+			return s.Enabled
+		}
+	}
+	return false
+}
+
+// resolveSourceSettings completes saved settings with the registry:
+// unknown names are dropped, and sources added since are enabled and
+// appended in their default order
+func resolveSourceSettings(saved []SourceSetting) []SourceSetting {
+	var settings []SourceSetting
+	seen := make(map[string]bool)
+	for _, s := range saved {
+		if _, ok := findSourceDef(s.Name); ok && !seen[s.Name] {
+			settings = append(settings, s)
+			seen[s.Name] = true
+		}
+	}
+	for _, def := range sourceRegistry {
+		if !seen[def.name] {
+			settings = append(settings, SourceSetting{Name: def.name, Enabled: true})
+		}
+	}
+	return settings
+}
+
+// SourceSettings holds which sources the user enabled, in their order
+type SourceSettings struct {
+	mu       sync.Mutex
+	settings []SourceSetting
+}
+
+// LoadSourceSettings reads the saved source settings
+func LoadSourceSettings() *SourceSettings {
+	var saved []SourceSetting
+	if err := config.Load(sourcesFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load source settings: %v", err)
+	}
+	return &SourceSettings{settings: resolveSourceSettings(saved)}
+}
+
+// Settings returns the setting of every source, in order
+func (s *SourceSettings) Settings() []SourceSetting {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SourceSetting(nil), s.settings...)
+}
+
+// Sources describes every available source with its setting
+func (s *SourceSettings) Sources() []SourceInfo {
+	var infos []SourceInfo
+	for _, setting := range s.Settings() {
+		def, _ := findSourceDef(setting.Name)
+		infos = append(infos, SourceInfo{Name: def.name, Capabilities: def.caps, Enabled: setting.Enabled})
+	}
+	return infos
+}
+
+// Set replaces the source settings. Sources left out keep their place
+// at the end and stay enabled.
+func (s *SourceSettings) Set(settings []SourceSetting) error {
+	seen := make(map[string]bool)
+	for _, setting := range settings {
+		if _, ok := findSourceDef(setting.Name); !ok {
+			return fmt.Errorf("unknown discovery source %q", setting.Name)
+		}
+		if seen[setting.Name] {
+			return fmt.Errorf("discovery source %q is listed twice", setting.Name)
+		}
+		seen[setting.Name] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = resolveSourceSettings(settings)
+	return config.Save(sourcesFile, s.settings)
+}
+
+// runSource runs a source through the cache, if it supports caching,
+// and reports how it went
+func runSource(ctx context.Context, cache *discoveryCache, src Source) ([]InstalledApp, SourceReport) {
+	start := time.Now()
+	var apps []InstalledApp
+	var hit bool
+	var err error
+	if fp, ok := src.(fingerprinter); ok {
+		apps, hit, err = cache.apps(src.Name(), fp.Fingerprint(), func() ([]InstalledApp, error) {
+			return src.Discover(ctx)
+		})
+	} else {
+		apps, err = src.Discover(ctx)
+		if err != nil {
+			log.Printf("[Enodia] Warning: Could not scan %s: %v", src.Name(), err)
+		}
+	}
+
+	report := SourceReport{
+		Name:       src.Name(),
+		Apps:       len(apps),
+		Cached:     hit,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return apps, report
+}
@@ -0,0 +1,142 @@
+package apps
+
+import (
+	"enodia/internal/vdf"
+	"enodia/internal/winreg"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strconv"
+)
+
+// steamStateFullyInstalled is the StateFlags bit of a complete install
+const steamStateFullyInstalled = 4
+
+// steamTools are app IDs that Steam installs alongside games but that are
+// not games: the common redistributables and the Linux runtimes
+var steamTools = map[string]bool{
+	"228980":  true,
+	"1070560": true,
+	"1391110": true,
+	"1628350": true,
+}
+
+// steamRoot finds the Steam client folder from the registry, or "" when
+// Steam is not installed
+func steamRoot(reg winreg.Reader) (string, error) {
+	lookups := []struct {
+		root  winreg.Root
+		path  string
+		value string
+	}{
+		{winreg.CurrentUser, `Software\Valve\Steam`, "SteamPath"},
+		{winreg.LocalMachine, `SOFTWARE\WOW6432Node\Valve\Steam`, "InstallPath"},
+		{winreg.LocalMachine, `SOFTWARE\Valve\Steam`, "InstallPath"},
+	}
+	for _, l := range lookups {
+		key, err := openKeyIfExists(reg, l.root, l.path)
+		if err != nil {
+			return "", fmt.Errorf(`failed to open %s\%s: %w`, l.root, l.path, err)
+		}
+		if key == nil {
+			continue
+		}
+		path, _ := key.GetString(l.value)
+		key.Close()
+		if path != "" {
+			return filepath.Clean(path), nil
+		}
+	}
+	return "", nil
+}
+
+// steamLibraries reads steamapps/libraryfolders.vdf. The Steam folder
+// itself is always a library, and the only one when the file is missing.
+func steamLibraries(root string) ([]string, error) {
+	libraries := []string{root}
+	doc, err := vdf.Open(filepath.Join(root, "steamapps", "libraryfolders.vdf"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return libraries, nil
+	}
+	if err != nil {
+		return libraries, fmt.Errorf("failed to read Steam libraries: %w", err)
+	}
+
+	folders := doc.Child("libraryfolders")
+	if folders == nil {
+		return libraries, nil
+	}
+	for _, c := range folders.Children {
+		// Entries are numbered; older files store the path as the value,
+		// newer ones as a "path" key next to the library's app list
+		if _, err := strconv.Atoi(c.Key); err != nil {
+			continue
+		}
+		path := c.Value
+		if path == "" {
+			path = c.Get("path")
+		}
+		if path != "" && !containsFold(libraries, filepath.Clean(path)) {
+			libraries = append(libraries, filepath.Clean(path))
+		}
+	}
+	return libraries, nil
+}
+
+// discoverSteamGames reads the appmanifest_*.acf files of every library
+func discoverSteamGames(reg winreg.Reader) ([]InstalledApp, error) {
+	root, err := steamRoot(reg)
+	if err != nil || root == "" {
+		return nil, err
+	}
+	libraries, err := steamLibraries(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var games []InstalledApp
+	for _, library := range libraries {
+		manifests, _ := filepath.Glob(filepath.Join(library, "steamapps", "appmanifest_*.acf"))
+		for _, manifest := range manifests {
+			doc, err := vdf.Open(manifest)
+			if err != nil {
+				log.Printf("[Enodia] Warning: Could not parse %s: %v", manifest, err)
+				continue
+			}
+			state := doc.Child("AppState")
+			appID := state.Get("appid")
+			installDir := state.Get("installdir")
+			if appID == "" || installDir == "" || steamTools[appID] {
+				continue
+			}
+			if flags, err := strconv.Atoi(state.Get("StateFlags")); err == nil && flags&steamStateFullyInstalled == 0 {
+				continue
+			}
+
+			name := state.Get("name")
+			if name == "" {
+				name = installDir
+			}
+			installPath := filepath.Join(library, "steamapps", "common", installDir)
+			games = append(games, newGame("steam", appID, name, installPath, state.Get("buildid"), ""))
+		}
+	}
+
+	log.Printf("[Enodia] Found %d Steam games", len(games))
+	return games, nil
+}
+
+// steamFingerprint covers the library list and every app manifest
+func steamFingerprint(reg winreg.Reader) string {
+	fp := newFingerprint()
+	if root, _ := steamRoot(reg); root != "" {
+		fp.file(filepath.Join(root, "steamapps", "libraryfolders.vdf"))
+		libraries, _ := steamLibraries(root)
+		for _, library := range libraries {
+			fp.glob(filepath.Join(library, "steamapps", "appmanifest_*.acf"))
+		}
+	}
+	return fp.String()
+}
@@ -1,25 +1,262 @@
 package apps
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"enodia/internal/appx"
+	"enodia/internal/winreg"
+	"fmt"
 	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+)
+
+// appContainerMappingsPath maps AppContainer SIDs to package family names
+const appContainerMappingsPath = `Software\Classes\Local Settings\Software\Microsoft\Windows\CurrentVersion\AppContainer\Mappings`
 
-	"golang.org/x/sys/windows/registry"
+// Package repositories: the per-user one lists the packages registered for
+// a user with their folders, the all-users store the manifest paths of
+// provisioned packages and, under each user's SID, the full names of the
+// packages registered for that user
+const (
+	userPackagesPath    = `Software\Classes\Local Settings\Software\Microsoft\Windows\CurrentVersion\AppModel\Repository\Packages`
+	allUserStorePath    = `SOFTWARE\Microsoft\Windows\CurrentVersion\Appx\AppxAllUserStore`
+	allUserPackagesPath = allUserStorePath + `\Applications`
 )
 
-// discoverStoreApps finds Microsoft Store / MSIX apps using PowerShell
-func discoverStoreApps() []InstalledApp {
+// powershellTimeout bounds the Get-AppxPackage fallback
+const powershellTimeout = 30 * time.Second
+
+// discoverStoreApps finds Microsoft Store / MSIX apps by reading the
+// AppxManifest.xml of every registered package, and tags each with the
+// users who have it. PowerShell's Get-AppxPackage is only used when no
+// package could be found that way.
+func discoverStoreApps(ctx context.Context, reg winreg.Reader, profiles []userProfile) ([]InstalledApp, error) {
+	packages, users := storePackageDirs(reg, profiles)
+	if len(packages) == 0 {
+		log.Println("[Enodia] No package repository found, falling back to Get-AppxPackage")
+		return discoverStoreAppsPowerShell(ctx, reg, profiles)
+	}
+
 	var apps []InstalledApp
+	for _, dir := range packages {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		m, err := appx.Open(dir)
+		if err != nil {
+			log.Printf("[Enodia] Warning: Could not read the package manifest in %s: %v", dir, err)
+			continue
+		}
+		if m.Framework || m.Resource || len(m.Applications) == 0 {
+			continue
+		}
+		app := newStoreApp(reg, profiles, dir, m)
+		addUsers(&app, users[strings.ToLower(app.PackageFamilyName)])
+		apps = append(apps, app)
+	}
+
+	log.Printf("[Enodia] Found %d Store apps", len(apps))
+	return apps, nil
+}
+
+// storePackageDirs returns the folder of every installed package and the
+// users each is registered for, both keyed by lowercased family name.
+// Where several versions are present the newest wins.
+func storePackageDirs(reg winreg.Reader, profiles []userProfile) (map[string]string, map[string][]string) {
+	dirs := make(map[string]string)
+	versions := make(map[string]string)
+	users := make(map[string][]string)
+	add := func(fullName, dir string) {
+		id, err := appx.ParseFullName(fullName)
+		if err != nil || dir == "" {
+			return
+		}
+		family := strings.ToLower(id.FamilyName())
+		if v, ok := versions[family]; ok && CompareVersions(v, id.Version) >= 0 {
+			return
+		}
+		dirs[family] = dir
+		versions[family] = id.Version
+	}
+	addUser := func(fullName, user string) {
+		id, err := appx.ParseFullName(fullName)
+		if err != nil || user == "" {
+			return
+		}
+		family := strings.ToLower(id.FamilyName())
+		if !containsFold(users[family], user) {
+			users[family] = append(users[family], user)
+		}
+	}
 
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
-		`Get-AppxPackage | Where-Object { $_.IsFramework -eq $false } | Select-Object Name, Publisher, InstallLocation, PackageFamilyName | ConvertTo-Json`)
+	for _, r := range userKeyRoots(profiles) {
+		key, err := reg.OpenKey(r.root, r.path(userPackagesPath))
+		if err != nil {
+			continue
+		}
+		names, _ := key.SubKeyNames()
+		for _, name := range names {
+			if sub, err := key.OpenSubKey(name); err == nil {
+				dir, _ := sub.GetString("PackageRootFolder")
+				sub.Close()
+				add(name, dir)
+				addUser(name, r.user)
+			}
+		}
+		key.Close()
+	}
+
+	// Users who are signed out only show up in the all-users store
+	for _, p := range profiles {
+		if key, err := reg.OpenKey(winreg.LocalMachine, allUserStorePath+`\`+p.sid); err == nil {
+			names, _ := key.SubKeyNames()
+			for _, name := range names {
+				addUser(name, p.name)
+			}
+			key.Close()
+		}
+	}
+
+	if key, err := reg.OpenKey(winreg.LocalMachine, allUserPackagesPath); err == nil {
+		names, _ := key.SubKeyNames()
+		for _, name := range names {
+			if sub, err := key.OpenSubKey(name); err == nil {
+				manifest, _ := sub.GetString("Path")
+				sub.Close()
+				if manifest != "" {
+					add(name, filepath.Dir(expandEnv(manifest)))
+				}
+			}
+		}
+		key.Close()
+	}
+
+	// Package folders are named after the full name; the WindowsApps
+	// folder is often unreadable without elevation
+	if programFiles := os.Getenv("ProgramFiles"); programFiles != "" {
+		root := filepath.Join(programFiles, "WindowsApps")
+		entries, _ := os.ReadDir(root)
+		for _, e := range entries {
+			if e.IsDir() {
+				add(e.Name(), filepath.Join(root, e.Name()))
+			}
+		}
+	}
+	return dirs, users
+}
+
+// newStoreApp builds the app for a package from its manifest
+func newStoreApp(reg winreg.Reader, profiles []userProfile, dir string, m *appx.Manifest) InstalledApp {
+	family := m.FamilyName()
+	app := InstalledApp{
+		ID:                  appID("store", family),
+		Name:                storeDisplayName(m),
+		Publisher:           m.PublisherDisplayName,
+		InstallPath:         dir,
+		AppType:             "store",
+		Source:              "store",
+		Origins:             []Origin{{Source: "store", Key: family}},
+		Version:             m.Version,
+		PackageFamilyName:   family,
+		PackageSID:          getPackageSID(reg, profiles, family),
+		NetworkCapabilities: m.NetworkCapabilities(),
+	}
+	if app.Publisher == "" || appx.IsResourceString(app.Publisher) {
+		app.Publisher = cleanPublisher(m.Publisher)
+	}
+
+	// Declared executables first, so the main one is picked as main
+	for _, a := range m.Applications {
+		if a.Executable == "" {
+			continue
+		}
+		exe := filepath.Join(dir, a.Executable)
+		if _, err := os.Stat(exe); err == nil && !containsFold(app.Executables, exe) {
+			app.Executables = append(app.Executables, exe)
+		}
+	}
+	for _, exe := range findExecutables(dir) {
+		if !containsFold(app.Executables, exe) {
+			app.Executables = append(app.Executables, exe)
+		}
+	}
+	app.Binaries = describeExecutables(app.Executables)
+	classifyBinaries(&app, "")
+
+	logos := []string{m.Logo}
+	if len(m.Applications) > 0 {
+		logos = append([]string{m.Applications[0].Logo}, logos...)
+	}
+	for _, logo := range logos {
+		if path := appx.ResolveAsset(dir, logo); path != "" {
+			if data, err := os.ReadFile(path); err == nil {
+				app.IconBase64 = base64.StdEncoding.EncodeToString(data)
+				break
+			}
+		}
+	}
+	if app.IconBase64 == "" {
+		app.IconBase64 = extractIconBase64(dir)
+	}
+	return app
+}
+
+// storeDisplayName picks a readable name. Names that are ms-resource
+// references would need resources.pri, so the package name is used instead.
+func storeDisplayName(m *appx.Manifest) string {
+	candidates := []string{m.DisplayName}
+	for _, a := range m.Applications {
+		candidates = append(candidates, a.DisplayName)
+	}
+	for _, name := range candidates {
+		if name != "" && !appx.IsResourceString(name) {
+			return name
+		}
+	}
+	return cleanStoreName(m.Name)
+}
+
+// CompareVersions compares dotted numeric versions
+func CompareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// discoverStoreAppsPowerShell finds Store apps with Get-AppxPackage
+func discoverStoreAppsPowerShell(ctx context.Context, reg winreg.Reader, profiles []userProfile) ([]InstalledApp, error) {
+	var apps []InstalledApp
+
+	ctx, cancel := context.WithTimeout(ctx, powershellTimeout)
+	defer cancel()
+
+	// -InputObject keeps the output an array even for a single package
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
+		`ConvertTo-Json -InputObject @(Get-AppxPackage | Where-Object { $_.IsFramework -eq $false } | Select-Object Name, Publisher, InstallLocation, PackageFamilyName, Version)`)
 
 	output, err := cmd.Output()
 	if err != nil {
-		log.Printf("[Enodia] Warning: Could not get Store apps: %v", err)
-		return apps
+		return nil, fmt.Errorf("could not get Store apps: %w", err)
 	}
 
 	var storeApps []struct {
@@ -27,39 +264,38 @@ func discoverStoreApps() []InstalledApp {
 		Publisher         string `json:"Publisher"`
 		InstallLocation   string `json:"InstallLocation"`
 		PackageFamilyName string `json:"PackageFamilyName"`
+		Version           string `json:"Version"`
 	}
-
 	if err := json.Unmarshal(output, &storeApps); err != nil {
-		var single struct {
-			Name              string `json:"Name"`
-			Publisher         string `json:"Publisher"`
-			InstallLocation   string `json:"InstallLocation"`
-			PackageFamilyName string `json:"PackageFamilyName"`
-		}
-		if err := json.Unmarshal(output, &single); err != nil {
-			log.Printf("[Enodia] Warning: Could not parse Store apps: %v", err)
-			return apps
-		}
-		storeApps = append(storeApps, single)
+		return nil, fmt.Errorf("could not parse Store apps: %w", err)
 	}
 
 	for _, sa := range storeApps {
-		if isSystemStoreApp(sa.Name, sa.Publisher) {
-			continue
+		// The manifest has the details Get-AppxPackage leaves out
+		if sa.InstallLocation != "" {
+			if m, err := appx.Open(sa.InstallLocation); err == nil {
+				apps = append(apps, newStoreApp(reg, profiles, sa.InstallLocation, m))
+				continue
+			}
 		}
 
 		app := InstalledApp{
-			ID:                generateID(sa.Name + sa.InstallLocation),
+			ID:                appID("store", sa.PackageFamilyName),
 			Name:              cleanStoreName(sa.Name),
 			Publisher:         cleanPublisher(sa.Publisher),
 			InstallPath:       sa.InstallLocation,
 			AppType:           "store",
+			Source:            "store",
+			Origins:           []Origin{{Source: "store", Key: sa.PackageFamilyName}},
+			Version:           sa.Version,
 			PackageFamilyName: sa.PackageFamilyName,
-			PackageSID:        getPackageSID(sa.PackageFamilyName),
+			PackageSID:        getPackageSID(reg, profiles, sa.PackageFamilyName),
 		}
 
 		if sa.InstallLocation != "" {
 			app.Executables = findExecutables(sa.InstallLocation)
+			app.Binaries = describeExecutables(app.Executables)
+			classifyBinaries(&app, "")
 			app.IconBase64 = extractIconBase64(sa.InstallLocation)
 		}
 
@@ -67,44 +303,45 @@ func discoverStoreApps() []InstalledApp {
 	}
 
 	log.Printf("[Enodia] Found %d Store apps", len(apps))
-	return apps
+	return apps, nil
 }
 
-// getPackageSID gets the App Container SID for a UWP app from the registry
-func getPackageSID(packageFamilyName string) string {
+// getPackageSID returns the AppContainer SID of a package, derived from
+// its family name. The registry mapping of the first signed-in user who
+// has one, when present, only cross-checks the derivation.
+func getPackageSID(reg winreg.Reader, profiles []userProfile, packageFamilyName string) string {
 	if packageFamilyName == "" {
 		return ""
 	}
+	sid := appx.ContainerSID(packageFamilyName)
 
-	mappingsPath := `Software\Classes\Local Settings\Software\Microsoft\Windows\CurrentVersion\AppContainer\Mappings`
-	key, err := registry.OpenKey(registry.CURRENT_USER, mappingsPath, registry.READ)
-	if err != nil {
-		return ""
-	}
-	defer key.Close()
-
-	subkeys, err := key.ReadSubKeyNames(-1)
-	if err != nil {
-		return ""
-	}
-
-	for _, sid := range subkeys {
-		if !strings.HasPrefix(sid, "S-1-15-2-") {
-			continue
-		}
-
-		subkey, err := registry.OpenKey(key, sid, registry.READ)
+	for _, r := range userKeyRoots(profiles) {
+		key, err := reg.OpenKey(r.root, r.path(appContainerMappingsPath+`\`+sid))
 		if err != nil {
+			// Packages get a mapping once they first run for a user
 			continue
 		}
-
-		moniker, _, err := subkey.GetStringValue("Moniker")
-		subkey.Close()
-
-		if err == nil && (strings.EqualFold(moniker, packageFamilyName) ||
-			strings.Contains(strings.ToLower(moniker), strings.ToLower(strings.Split(packageFamilyName, "_")[0]))) {
-			return sid
+		moniker, err := key.GetString("Moniker")
+		key.Close()
+		if err == nil && !strings.EqualFold(moniker, packageFamilyName) {
+			log.Printf("[Enodia] Warning: AppContainer mapping %s names %s, expected %s", sid, moniker, packageFamilyName)
 		}
+		break
+	}
+	return sid
+}
+
+// storeFingerprint covers the package repositories, which gain a key for
+// every installed or updated package, and the AppContainer mappings
+func storeFingerprint(reg winreg.Reader, profiles []userProfile) string {
+	fp := newFingerprint()
+	for _, r := range userKeyRoots(profiles) {
+		fp.key(reg, r.root, r.path(userPackagesPath))
+		fp.key(reg, r.root, r.path(appContainerMappingsPath))
+	}
+	fp.key(reg, winreg.LocalMachine, allUserPackagesPath)
+	for _, p := range profiles {
+		fp.key(reg, winreg.LocalMachine, allUserStorePath+`\`+p.sid)
 	}
-	return ""
+	return fp.String()
 }
@@ -0,0 +1,235 @@
+package apps
+
+import (
+	"enodia/internal/config"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const tagsFile = "tags.json"
+
+// Prefixes of the automatic categories derived from an app's publisher
+// and source, as in "publisher:Valve" or "source:steam". User tags cannot
+// use them.
+const (
+	PublisherCategoryPrefix = "publisher:"
+	SourceCategoryPrefix    = "source:"
+)
+
+// wellKnownCategories group well-known apps by name patterns, in the
+// wildcard syntax of filter rules. Games are recognized by their source.
+var wellKnownCategories = []struct {
+	name  string
+	names []string
+}{
+	{"browsers", []string{"google chrome*", "mozilla firefox*", "microsoft edge", "brave*", "opera*", "vivaldi*", "tor browser*", "chromium*"}},
+	{"chat", []string{"discord*", "slack*", "microsoft teams*", "zoom*", "telegram*", "whatsapp*", "signal*", "skype*", "element*"}},
+	{"dev tools", []string{"microsoft visual studio*", "visual studio code*", "git", "git version *", "github desktop*", "node.js*", "python *", "docker desktop*", "jetbrains *", "intellij idea*", "pycharm*", "goland*", "webstorm*", "postman*", "sublime text*", "notepad++*"}},
+	{"media", []string{"vlc media player*", "spotify*", "itunes*", "obs studio*", "audacity*"}},
+}
+
+// gameSources are the sources whose apps are games
+var gameSources = []string{"steam", "epic", "gog"}
+
+// TaggedApp holds the user tags of one app
+type TaggedApp struct {
+	AppID string   `json:"appId"`
+	Tags  []string `json:"tags"`
+}
+
+// TagSummary describes a tag or category with the number of apps that
+// carry it
+type TagSummary struct {
+	Tag  string `json:"tag"`
+	Apps int    `json:"apps"`
+	// Automatic is set for categories Enodia derives itself
+	Automatic bool `json:"automatic"`
+}
+
+// Tags holds the tags users put on apps, by app ID. Tags outlive the
+// apps, so an app that is reinstalled gets its tags back.
+type Tags struct {
+	mu   sync.Mutex
+	tags map[string][]string
+}
+
+// LoadTags reads the saved user tags
+func LoadTags() *Tags {
+	t := &Tags{tags: make(map[string][]string)}
+
+	var saved []TaggedApp
+	if err := config.Load(tagsFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load tags: %v", err)
+	}
+	for _, s := range saved {
+		t.tags[s.AppID] = s.Tags
+	}
+	return t
+}
+
+// NormalizeTag trims and lowercases a user tag and checks that it is
+// not empty and does not look like an automatic category
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return "", fmt.Errorf("tag is empty")
+	}
+	if strings.HasPrefix(tag, PublisherCategoryPrefix) || strings.HasPrefix(tag, SourceCategoryPrefix) {
+		return "", fmt.Errorf("tag %q uses a reserved prefix", tag)
+	}
+	return tag, nil
+}
+
+// Set replaces the user tags of an app
+func (t *Tags) Set(appID string, tags []string) error {
+	var normalized []string
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		normalized = addTag(normalized, tag)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(normalized) == 0 {
+		delete(t.tags, appID)
+	} else {
+		t.tags[appID] = normalized
+	}
+	return t.saveLocked()
+}
+
+// Add puts a tag on several apps
+func (t *Tags) Add(tag string, appIDs []string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range appIDs {
+		t.tags[id] = addTag(t.tags[id], tag)
+	}
+	return t.saveLocked()
+}
+
+// Remove takes a tag off several apps
+func (t *Tags) Remove(tag string, appIDs []string) error {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range appIDs {
+		var kept []string
+		for _, existing := range t.tags[id] {
+			if existing != tag {
+				kept = append(kept, existing)
+			}
+		}
+		if len(kept) == 0 {
+			delete(t.tags, id)
+		} else {
+			t.tags[id] = kept
+		}
+	}
+	return t.saveLocked()
+}
+
+// Apply sets the user tags and automatic categories of the given apps
+func (t *Tags) Apply(apps []InstalledApp) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range apps {
+		apps[i].Tags = append([]string(nil), t.tags[apps[i].ID]...)
+		apps[i].Categories = Categories(apps[i])
+	}
+}
+
+func (t *Tags) saveLocked() error {
+	list := make([]TaggedApp, 0, len(t.tags))
+	for id, tags := range t.tags {
+		list = append(list, TaggedApp{AppID: id, Tags: tags})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AppID < list[j].AppID })
+	return config.Save(tagsFile, list)
+}
+
+// Categories derives the automatic categories of an app: its publisher,
+// its source and the well-known categories it belongs to
+func Categories(app InstalledApp) []string {
+	var categories []string
+	for _, source := range gameSources {
+		if app.Source == source {
+			categories = append(categories, "games")
+		}
+	}
+	for _, c := range wellKnownCategories {
+		if matchAny(c.names, app.Name) {
+			categories = append(categories, c.name)
+		}
+	}
+	if app.Publisher != "" {
+		categories = append(categories, PublisherCategoryPrefix+strings.TrimSpace(app.Publisher))
+	}
+	if app.Source != "" {
+		categories = append(categories, SourceCategoryPrefix+app.Source)
+	}
+	return categories
+}
+
+// HasTag reports whether the app carries a user tag or automatic
+// category, ignoring case
+func (app InstalledApp) HasTag(tag string) bool {
+	tag = strings.TrimSpace(tag)
+	return containsFold(app.Tags, tag) || containsFold(app.Categories, tag)
+}
+
+// SummarizeTags lists the tags and categories of the given apps with how
+// many apps carry each, sorted by tag
+func SummarizeTags(apps []InstalledApp) []TagSummary {
+	byTag := make(map[string]*TagSummary)
+	count := func(tag string, automatic bool) {
+		key := strings.ToLower(tag)
+		s, ok := byTag[key]
+		if !ok {
+			s = &TagSummary{Tag: tag, Automatic: automatic}
+			byTag[key] = s
+		}
+		// A user tag that matches a category is the user's
+		s.Automatic = s.Automatic && automatic
+		s.Apps++
+	}
+	for _, app := range apps {
+		seen := make(map[string]bool)
+		for _, tag := range app.Tags {
+			seen[strings.ToLower(tag)] = true
+			count(tag, false)
+		}
+		for _, c := range app.Categories {
+			if !seen[strings.ToLower(c)] {
+				count(c, true)
+			}
+		}
+	}
+
+	summaries := make([]TagSummary, 0, len(byTag))
+	for _, s := range byTag {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Tag < summaries[j].Tag })
+	return summaries
+}
+
+// addTag appends tag unless the list has it already
+func addTag(tags []string, tag string) []string {
+	for _, existing := range tags {
+		if existing == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
@@ -1,5 +1,7 @@
 package apps
 
+import "time"
+
 // InstalledApp represents a discovered application
 type InstalledApp struct {
 	ID                string   `json:"id"`
@@ -11,4 +13,70 @@ type InstalledApp struct {
 	AppType           string   `json:"appType"`
 	PackageFamilyName string   `json:"packageFamilyName"`
 	PackageSID        string   `json:"packageSID"`
+	// NetworkCapabilities lists the network capabilities a Store package
+	// declares: internetClient, internetClientServer, privateNetworkClientServer
+	NetworkCapabilities []string `json:"networkCapabilities,omitempty"`
+	Version             string   `json:"version"`
+	// Source names where the app was found: registry, startmenu, profile,
+	// store, scoop, chocolatey, winget, steam, epic, gog or portable, for
+	// unregistered executables found in the folders the user scans
+	Source string `json:"source"`
+	// Origins lists every place the app was found. Duplicates across
+	// registry views, hives and sources are merged into one app.
+	Origins []Origin `json:"origins"`
+	// Users lists the users who have a per-user install of the app or, for
+	// Store apps, have the package registered. It is empty for apps
+	// installed for all users.
+	Users []string `json:"users,omitempty"`
+	// Tags are the user's tags on the app; Categories are derived from its
+	// publisher, source and well-known category lists
+	Tags       []string `json:"tags,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	// Shims maps package-manager shim executables to the binaries they launch
+	Shims map[string]string `json:"shims,omitempty"`
+
+	// Details from the uninstall entry of registry apps. EstimatedSize is
+	// in bytes; ProductCode is set for Windows Installer packages, and
+	// NoRemove for apps Windows offers no uninstall for.
+	InstallDate     string   `json:"installDate,omitempty"`
+	EstimatedSize   int64    `json:"estimatedSize,omitempty"`
+	UninstallString string   `json:"uninstallString,omitempty"`
+	URLInfoAbout    string   `json:"urlInfoAbout,omitempty"`
+	ProductCode     string   `json:"productCode,omitempty"`
+	NoRemove        bool     `json:"noRemove,omitempty"`
+	Updates         []Update `json:"updates,omitempty"`
+
+	// Version resource details for each entry in Executables
+	Binaries []Executable `json:"binaries"`
+
+	// Authenticode signer of the app's main executable
+	SignerSubject   string    `json:"signerSubject"`
+	SignerIssuer    string    `json:"signerIssuer"`
+	SignerNotBefore time.Time `json:"signerNotBefore"`
+	SignerNotAfter  time.Time `json:"signerNotAfter"`
+	SignatureValid  bool      `json:"signatureValid"`
+}
+
+// Update is an update or patch registered for an app, which Windows lists
+// under Installed Updates
+type Update struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	InstallDate string `json:"installDate"`
+	ReleaseType string `json:"releaseType"`
+}
+
+// Executable describes one executable of an app from its version resource
+type Executable struct {
+	Path             string `json:"path"`
+	Description      string `json:"description"`
+	ProductName      string `json:"productName"`
+	CompanyName      string `json:"companyName"`
+	Version          string `json:"version"`
+	OriginalFilename string `json:"originalFilename"`
+	Architecture     string `json:"architecture"`
+	// Kind is the detected role: main, helper, updater, uninstaller or
+	// crashreporter. KindOverride is the role the user set instead.
+	Kind         string `json:"kind"`
+	KindOverride string `json:"kindOverride,omitempty"`
 }
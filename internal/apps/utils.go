@@ -2,21 +2,14 @@ package apps
 
 import (
 	"encoding/base64"
-	"fmt"
+	"enodia/internal/winreg"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// generateID creates a simple hash for identifying apps
-func generateID(input string) string {
-	hash := 0
-	for _, c := range input {
-		hash = hash*31 + int(c)
-	}
-	return fmt.Sprintf("app_%d", uint32(hash))
-}
-
 // findExecutables finds all .exe files in a directory (max 2 levels deep)
 func findExecutables(installPath string) []string {
 	var exes []string
@@ -79,45 +72,22 @@ func cleanPublisher(publisher string) string {
 	return publisher
 }
 
-// isSystemApp filters out Windows system components
-func isSystemApp(name, publisher, installPath string) bool {
-	lowerName := strings.ToLower(name)
-	lowerPublisher := strings.ToLower(publisher)
-	lowerPath := strings.ToLower(installPath)
-
-	if strings.Contains(lowerPublisher, "microsoft") {
-		allowed := []string{"office", "visual studio", "vscode", "edge", "teams", "onedrive"}
-		for _, a := range allowed {
-			if strings.Contains(lowerName, a) {
-				return false
-			}
-		}
-		system := []string{"update", "redistributable", "runtime", ".net", "sdk", "tool"}
-		for _, s := range system {
-			if strings.Contains(lowerName, s) {
-				return true
-			}
-		}
-	}
-
-	if strings.Contains(lowerPath, "\\windows\\") {
-		return true
+// readDirIfExists lists a folder. A missing folder is empty, since it
+// only means the tool that owns it is not installed.
+func readDirIfExists(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
 	}
-	return false
+	return entries, err
 }
 
-// isSystemStoreApp filters out Windows system Store apps
-func isSystemStoreApp(name, publisher string) bool {
-	lowerName := strings.ToLower(name)
-	prefixes := []string{
-		"microsoft.net", "microsoft.vclibs", "microsoft.ui",
-		"microsoft.windows", "microsoft.services", "microsoft.advertising",
-		"microsoft.directx", "microsoft.desktop",
-	}
-	for _, prefix := range prefixes {
-		if strings.HasPrefix(lowerName, prefix) {
-			return true
-		}
+// openKeyIfExists opens a registry key, returning a nil key without an
+// error when it does not exist
+func openKeyIfExists(reg winreg.Reader, root winreg.Root, path string) (winreg.Key, error) {
+	key, err := reg.OpenKey(root, path)
+	if errors.Is(err, winreg.ErrNotExist) {
+		return nil, nil
 	}
-	return false
+	return key, err
 }
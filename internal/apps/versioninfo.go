@@ -0,0 +1,46 @@
+package apps
+
+import (
+	"enodia/internal/pe"
+	"strings"
+)
+
+// describeExecutables reads the version resource of every executable
+func describeExecutables(paths []string) []Executable {
+	result := make([]Executable, 0, len(paths))
+	for _, path := range paths {
+		exe := Executable{Path: path}
+		if info, err := pe.ReadVersionInfo(path); err == nil {
+			exe.Description = info.FileDescription
+			exe.ProductName = info.ProductName
+			exe.CompanyName = info.CompanyName
+			exe.Version = info.FileVersion
+			exe.OriginalFilename = info.OriginalFilename
+			exe.Architecture = info.Architecture
+		}
+		result = append(result, exe)
+	}
+	return result
+}
+
+// applyVersionInfo describes and classifies the app's executables and
+// fills in a missing publisher from the main executable's company name
+func applyVersionInfo(app *InstalledApp, iconPath string) {
+	app.Binaries = describeExecutables(app.Executables)
+	applyBinaries(app, iconPath)
+}
+
+// applyBinaries does the same for an app whose Binaries are already described
+func applyBinaries(app *InstalledApp, iconPath string) {
+	classifyBinaries(app, iconPath)
+	if app.Publisher != "" {
+		return
+	}
+	main := mainExecutable(app, iconPath)
+	for _, exe := range app.Binaries {
+		if strings.EqualFold(exe.Path, main) {
+			app.Publisher = exe.CompanyName
+			return
+		}
+	}
+}
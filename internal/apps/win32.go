@@ -1,72 +1,346 @@
 package apps
 
 import (
-	"log"
-
-	"golang.org/x/sys/windows/registry"
+	"enodia/internal/winreg"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
-// discoverWin32Apps reads installed apps from the Windows Registry
-func discoverWin32Apps() []InstalledApp {
-	var apps []InstalledApp
+// uninstallPath is the Add/Remove Programs registry key
+const uninstallPath = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`
+
+// uninstallKey is an Add/Remove Programs registry location. user names
+// the owner of a per-user location and is empty for machine-wide ones.
+type uninstallKey struct {
+	root winreg.Root
+	path string
+	user string
+}
 
-	regPaths := []struct {
-		root registry.Key
-		path string
-	}{
-		{registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`},
-		{registry.LOCAL_MACHINE, `SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`},
-		{registry.CURRENT_USER, `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`},
+// uninstallKeys returns the machine-wide Add/Remove Programs locations of
+// both registry views and the per-user location of every signed-in user
+func uninstallKeys(profiles []userProfile) []uninstallKey {
+	keys := []uninstallKey{
+		{winreg.LocalMachine, uninstallPath, ""},
+		{winreg.LocalMachine, `SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`, ""},
 	}
+	for _, r := range userKeyRoots(profiles) {
+		keys = append(keys, uninstallKey{r.root, r.path(uninstallPath), r.user})
+	}
+	return keys
+}
+
+// updateReleaseTypes are the ReleaseType values of entries that Windows
+// lists under Installed Updates rather than as programs
+var updateReleaseTypes = []string{"Security Update", "Update", "Hotfix", "Update Rollup", "Service Pack"}
+
+// win32Entry is an uninstall entry that describes an app. One entry may
+// stand for several uninstall keys of the same software.
+type win32Entry struct {
+	// key is the name of the uninstall key, which for Windows Installer
+	// packages is the product code. identity is the key name too, unless
+	// another install registered the same name.
+	key      string
+	identity string
+	// source names the entry's cache slot, from its identity
+	source      string
+	fingerprint string
+	origins     []Origin
+	// users lists the users with a per-user install; machine is set for
+	// entries registered for all users
+	users   []string
+	machine bool
+
+	name            string
+	publisher       string
+	installPath     string
+	iconPath        string
+	version         string
+	installDate     string
+	sizeKB          uint32
+	uninstallString string
+	urlInfoAbout    string
+	productCode     string
+	noRemove        bool
+	updates         []Update
+
+	// Values that decide how Windows lists the entry
+	systemComponent bool
+	parentKey       string
+	releaseType     string
+}
+
+// readWin32Entries lists the uninstall entries of installed apps the way
+// Apps & Features does: system components and entries without an uninstall
+// command are left out, and updates and
+// patches are attached to the app their ParentKeyName names. Reading the
+// values is cheap; the expensive scan of each app's folder is left to
+// newWin32App, which only runs for entries whose fingerprint changed.
+//
+// Installers often register the same software more than once: under both
+// registry views, or for the machine and each user. Keys with the same name
+// (the product code of MSI packages), or with the same display name and
+// install folder, become one entry that lists every key as an origin.
+// Keys with the same name but different install folders, such as
+// per-user installs of several users, stay separate entries.
+func readWin32Entries(reg winreg.Reader, profiles []userProfile) []win32Entry {
+	var entries, updates []win32Entry
+	byKey := make(map[string]int)
+	byInstall := make(map[string]int)
 
-	for _, regPath := range regPaths {
-		key, err := registry.OpenKey(regPath.root, regPath.path, registry.READ)
+	for _, regPath := range uninstallKeys(profiles) {
+		key, err := reg.OpenKey(regPath.root, regPath.path)
 		if err != nil {
 			continue
 		}
 
-		subkeys, err := key.ReadSubKeyNames(-1)
+		subkeys, err := key.SubKeyNames()
 		if err != nil {
 			key.Close()
 			continue
 		}
 
 		for _, subkeyName := range subkeys {
-			subkey, err := registry.OpenKey(key, subkeyName, registry.READ)
+			subkey, err := key.OpenSubKey(subkeyName)
 			if err != nil {
 				continue
 			}
-
-			name, _, _ := subkey.GetStringValue("DisplayName")
-			publisher, _, _ := subkey.GetStringValue("Publisher")
-			installPath, _, _ := subkey.GetStringValue("InstallLocation")
-			iconPath, _, _ := subkey.GetStringValue("DisplayIcon")
+			e := readUninstallEntry(subkey, subkeyName)
 			subkey.Close()
+			e.origins = []Origin{{Source: "registry", Key: fmt.Sprintf(`%s\%s\%s`, regPath.root, regPath.path, subkeyName)}}
+			if regPath.user != "" {
+				e.users = []string{regPath.user}
+			} else {
+				e.machine = true
+			}
 
-			if name == "" || isSystemApp(name, publisher, installPath) {
+			if e.name == "" || e.systemComponent {
 				continue
 			}
-
-			app := InstalledApp{
-				ID:          generateID(name + installPath),
-				Name:        name,
-				Publisher:   publisher,
-				InstallPath: installPath,
-				AppType:     "win32",
+			if e.isUpdate() {
+				updates = append(updates, e)
+				continue
+			}
+			if e.uninstallString == "" {
+				continue
 			}
 
-			if installPath != "" {
-				app.Executables = findExecutables(installPath)
+			idx, ok := byKey[strings.ToLower(e.key)]
+			if ok && !sameInstall(entries[idx], e) {
+				// A second install under the same key name is told
+				// apart by its full key path
+				e.identity = e.origins[0].Key
+				e.source = "registry:" + strings.ToLower(e.identity)
+				ok = false
 			}
-			if iconPath != "" {
-				app.IconBase64 = extractIconBase64(iconPath)
+			if !ok && e.installPath != "" {
+				idx, ok = byInstall[installIdentity(e)]
+			}
+			if !ok {
+				entries = append(entries, e)
+				idx = len(entries) - 1
+			} else {
+				entries[idx].absorb(e)
+			}
+			if _, seen := byKey[strings.ToLower(e.key)]; !seen {
+				byKey[strings.ToLower(e.key)] = idx
+			}
+			if entries[idx].installPath != "" {
+				byInstall[installIdentity(entries[idx])] = idx
 			}
-
-			apps = append(apps, app)
 		}
 		key.Close()
 	}
 
-	log.Printf("[Enodia] Found %d Win32 apps", len(apps))
-	return apps
+	// Updates whose parent is not listed, such as those of Windows
+	// itself, are left out with it
+	for _, u := range updates {
+		if idx, ok := byKey[strings.ToLower(u.parentKey)]; ok {
+			entries[idx].addUpdate(u)
+		}
+	}
+	return entries
+}
+
+// readUninstallEntry reads the values of an uninstall key
+func readUninstallEntry(subkey winreg.Key, name string) win32Entry {
+	e := win32Entry{key: name, identity: name, source: "registry:" + strings.ToLower(name)}
+	e.name, _ = subkey.GetString("DisplayName")
+	e.publisher, _ = subkey.GetString("Publisher")
+	e.installPath, _ = subkey.GetString("InstallLocation")
+	e.iconPath, _ = subkey.GetString("DisplayIcon")
+	e.version, _ = subkey.GetString("DisplayVersion")
+	installDate, _ := subkey.GetString("InstallDate")
+	e.installDate = formatInstallDate(installDate)
+	e.sizeKB, _ = subkey.GetDWORD("EstimatedSize")
+	e.uninstallString, _ = subkey.GetString("UninstallString")
+	e.urlInfoAbout, _ = subkey.GetString("URLInfoAbout")
+	e.parentKey, _ = subkey.GetString("ParentKeyName")
+	e.releaseType, _ = subkey.GetString("ReleaseType")
+	systemComponent, _ := subkey.GetDWORD("SystemComponent")
+	e.systemComponent = systemComponent == 1
+	noRemove, _ := subkey.GetDWORD("NoRemove")
+	e.noRemove = noRemove == 1
+	// Windows Installer names the key after the product code
+	if msi, _ := subkey.GetDWORD("WindowsInstaller"); msi == 1 && isProductCode(name) {
+		e.productCode = strings.ToUpper(name)
+	}
+	modified, _ := subkey.LastWriteTime()
+
+	fp := newFingerprint()
+	fp.add(e.name, e.publisher, e.installPath, e.iconPath, e.version, installDate,
+		fmt.Sprint(e.sizeKB), e.uninstallString, e.urlInfoAbout, e.productCode,
+		fmt.Sprint(e.noRemove), fmt.Sprint(modified.UnixNano()))
+	fp.file(e.installPath)
+	e.fingerprint = fp.String()
+	return e
+}
+
+// isUpdate reports whether Windows lists the entry as an update of
+// another app rather than as a program
+func (e win32Entry) isUpdate() bool {
+	if e.parentKey != "" {
+		return true
+	}
+	for _, t := range updateReleaseTypes {
+		if strings.EqualFold(e.releaseType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// addUpdate lists an update under the entry
+func (e *win32Entry) addUpdate(u win32Entry) {
+	e.updates = append(e.updates, Update{
+		Name:        u.name,
+		Version:     u.version,
+		InstallDate: u.installDate,
+		ReleaseType: u.releaseType,
+	})
+	fp := newFingerprint()
+	fp.add(e.fingerprint, u.fingerprint)
+	e.fingerprint = fp.String()
+}
+
+// absorb folds a duplicate uninstall key into the entry. Values the entry
+// lacks are taken from the duplicate, and the fingerprint covers both.
+func (e *win32Entry) absorb(dup win32Entry) {
+	e.origins = append(e.origins, dup.origins...)
+	e.machine = e.machine || dup.machine
+	for _, u := range dup.users {
+		if !containsFold(e.users, u) {
+			e.users = append(e.users, u)
+		}
+	}
+	if e.publisher == "" {
+		e.publisher = dup.publisher
+	}
+	if e.installPath == "" {
+		e.installPath = dup.installPath
+	}
+	if e.iconPath == "" {
+		e.iconPath = dup.iconPath
+	}
+	if e.version == "" {
+		e.version = dup.version
+	}
+	if e.installDate == "" {
+		e.installDate = dup.installDate
+	}
+	if e.sizeKB == 0 {
+		e.sizeKB = dup.sizeKB
+	}
+	if e.uninstallString == "" {
+		e.uninstallString = dup.uninstallString
+	}
+	if e.urlInfoAbout == "" {
+		e.urlInfoAbout = dup.urlInfoAbout
+	}
+	if e.productCode == "" {
+		e.productCode = dup.productCode
+	}
+	fp := newFingerprint()
+	fp.add(e.fingerprint, dup.fingerprint)
+	e.fingerprint = fp.String()
+}
+
+// formatInstallDate turns the YYYYMMDD of InstallDate into YYYY-MM-DD.
+// Installers write other formats too; those are kept as they are.
+func formatInstallDate(s string) string {
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return s
+}
+
+// isProductCode reports whether s is a braced GUID, as Windows Installer
+// product codes are
+func isProductCode(s string) bool {
+	if len(s) != 38 || s[0] != '{' || s[37] != '}' {
+		return false
+	}
+	for i, c := range s[1:37] {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameInstall reports whether two keys with the same name describe the
+// same install, which they do unless both name different folders
+func sameInstall(a, b win32Entry) bool {
+	if a.installPath == "" || b.installPath == "" {
+		return true
+	}
+	return strings.EqualFold(filepath.Clean(a.installPath), filepath.Clean(b.installPath))
+}
+
+// installIdentity identifies an entry by its display name and install folder
+func installIdentity(e win32Entry) string {
+	return normalizeName(e.name) + "|" + strings.ToLower(filepath.Clean(e.installPath))
+}
+
+// newWin32App builds the app for an uninstall entry
+func newWin32App(e win32Entry) InstalledApp {
+	app := InstalledApp{
+		ID:          appID("registry", e.identity),
+		Name:        e.name,
+		Publisher:   e.publisher,
+		InstallPath: e.installPath,
+		AppType:     "win32",
+		Source:      "registry",
+		Origins:     e.origins,
+		Version:     e.version,
+
+		InstallDate:     e.installDate,
+		EstimatedSize:   int64(e.sizeKB) * 1024,
+		UninstallString: e.uninstallString,
+		URLInfoAbout:    e.urlInfoAbout,
+		ProductCode:     e.productCode,
+		NoRemove:        e.noRemove,
+		Updates:         e.updates,
+	}
+	// Machine-wide installs are there for everyone
+	if !e.machine {
+		addUsers(&app, e.users)
+	}
+
+	if e.installPath != "" {
+		app.Executables = findExecutables(e.installPath)
+		applyVersionInfo(&app, e.iconPath)
+		applySignature(&app, e.iconPath)
+	}
+	app.IconBase64 = extractWin32IconBase64(&app, e.iconPath)
+	return app
 }
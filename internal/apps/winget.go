@@ -0,0 +1,207 @@
+package apps
+
+import (
+	"enodia/internal/winreg"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// wingetPackage is the Add/Remove Programs entry winget writes for a package
+type wingetPackage struct {
+	ID          string
+	Name        string
+	Publisher   string
+	Version     string
+	InstallPath string
+}
+
+// wingetDirs returns the user and machine folders that hold portable
+// winget packages and the links winget puts on the PATH
+func wingetDirs() (packages, links []string) {
+	if local := os.Getenv("LOCALAPPDATA"); local != "" {
+		packages = append(packages, filepath.Join(local, `Microsoft\WinGet\Packages`))
+		links = append(links, filepath.Join(local, `Microsoft\WinGet\Links`))
+	}
+	if programFiles := os.Getenv("ProgramFiles"); programFiles != "" {
+		packages = append(packages, filepath.Join(programFiles, `WinGet\Packages`))
+		links = append(links, filepath.Join(programFiles, `WinGet\Links`))
+	}
+	return packages, links
+}
+
+// discoverWingetApps finds packages installed by winget. Its own package
+// index (installed.db) is SQLite, which we cannot read, so metadata comes
+// from the WinGetPackageIdentifier entries winget adds to Add/Remove
+// Programs and from the portable package folders.
+func discoverWingetApps(reg winreg.Reader, profiles []userProfile, packageDirs, linkDirs []string) ([]InstalledApp, error) {
+	registered, err := readWingetPackages(reg, profiles)
+	if err != nil {
+		return nil, err
+	}
+	links, err := readWingetLinks(linkDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []InstalledApp
+	seen := make(map[string]bool)
+
+	add := func(id string, pkg wingetPackage) {
+		if seen[strings.ToLower(id)] {
+			return
+		}
+		seen[strings.ToLower(id)] = true
+
+		app := InstalledApp{
+			ID:          appID("winget", id),
+			Name:        pkg.Name,
+			Publisher:   pkg.Publisher,
+			InstallPath: pkg.InstallPath,
+			AppType:     "win32",
+			Source:      "winget",
+			Origins:     []Origin{{Source: "winget", Key: id}},
+			Version:     pkg.Version,
+		}
+		if app.Name == "" {
+			app.Name = id
+			if i := strings.LastIndex(id, "."); i >= 0 && i < len(id)-1 {
+				app.Name = id[i+1:]
+			}
+		}
+		if app.InstallPath != "" {
+			app.Executables = findExecutables(app.InstallPath)
+		}
+		for link, target := range links {
+			if app.InstallPath != "" && isUnder(target, app.InstallPath) {
+				if app.Shims == nil {
+					app.Shims = make(map[string]string)
+				}
+				app.Shims[link] = target
+				if !containsFold(app.Executables, target) {
+					app.Executables = append(app.Executables, target)
+				}
+			}
+		}
+		if len(app.Executables) > 0 {
+			applyVersionInfo(&app, "")
+			applySignature(&app, "")
+			app.IconBase64 = extractWin32IconBase64(&app, "")
+		}
+		apps = append(apps, app)
+	}
+
+	// Portable packages live in folders named <PackageId>_<SourceId>
+	for _, root := range packageDirs {
+		entries, err := readDirIfExists(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read winget packages: %w", err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			id, _, _ := strings.Cut(e.Name(), "_")
+			pkg := registered[strings.ToLower(id)]
+			if pkg.InstallPath == "" {
+				pkg.InstallPath = filepath.Join(root, e.Name())
+			}
+			add(id, pkg)
+		}
+	}
+	ids := make([]string, 0, len(registered))
+	for id := range registered {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		add(registered[id].ID, registered[id])
+	}
+
+	log.Printf("[Enodia] Found %d winget packages", len(apps))
+	return apps, nil
+}
+
+// readWingetPackages collects the uninstall entries that carry a
+// WinGetPackageIdentifier, keyed by the lowercased package ID
+func readWingetPackages(reg winreg.Reader, profiles []userProfile) (map[string]wingetPackage, error) {
+	packages := make(map[string]wingetPackage)
+
+	for _, regPath := range uninstallKeys(profiles) {
+		key, err := openKeyIfExists(reg, regPath.root, regPath.path)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to open %s\%s: %w`, regPath.root, regPath.path, err)
+		}
+		if key == nil {
+			continue
+		}
+		subkeys, err := key.SubKeyNames()
+		if err != nil {
+			key.Close()
+			return nil, fmt.Errorf(`failed to list %s\%s: %w`, regPath.root, regPath.path, err)
+		}
+		for _, name := range subkeys {
+			subkey, err := key.OpenSubKey(name)
+			if err != nil {
+				continue
+			}
+			id, _ := subkey.GetString("WinGetPackageIdentifier")
+			if id != "" {
+				pkg := wingetPackage{ID: id}
+				pkg.Name, _ = subkey.GetString("DisplayName")
+				pkg.Publisher, _ = subkey.GetString("Publisher")
+				pkg.Version, _ = subkey.GetString("DisplayVersion")
+				pkg.InstallPath, _ = subkey.GetString("InstallLocation")
+				packages[strings.ToLower(id)] = pkg
+			}
+			subkey.Close()
+		}
+		key.Close()
+	}
+	return packages, nil
+}
+
+// readWingetLinks maps the symlinks winget puts on the PATH to their targets
+func readWingetLinks(dirs []string) (map[string]string, error) {
+	links := make(map[string]string)
+	for _, dir := range dirs {
+		entries, err := readDirIfExists(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read winget links: %w", err)
+		}
+		for _, e := range entries {
+			if !strings.EqualFold(filepath.Ext(e.Name()), ".exe") {
+				continue
+			}
+			link := filepath.Join(dir, e.Name())
+			target, err := os.Readlink(link)
+			if err != nil {
+				continue
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+			links[link] = target
+		}
+	}
+	return links, nil
+}
+
+// wingetFingerprint covers the package and link folders and the
+// uninstall entries winget registers packages under
+func wingetFingerprint(reg winreg.Reader, profiles []userProfile, packageDirs, linkDirs []string) string {
+	fp := newFingerprint()
+	for _, dir := range packageDirs {
+		fp.glob(filepath.Join(dir, "*"))
+	}
+	for _, dir := range linkDirs {
+		fp.file(dir)
+	}
+	for _, k := range uninstallKeys(profiles) {
+		fp.key(reg, k.root, k.path)
+	}
+	return fp.String()
+}
@@ -0,0 +1,60 @@
+package apps
+
+import (
+	"enodia/internal/winreg"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverWingetApps(t *testing.T) {
+	root := copyFixture(t, "winget")
+	packages := filepath.Join(root, "Packages")
+	links := filepath.Join(root, "Links")
+	tool := filepath.Join(packages, "Contoso.Tool_Microsoft.Winget.Source_8wekyb3d8bbwe", "bin", "tool.exe")
+	symlink(t, tool, filepath.Join(links, "tool.exe"))
+	// Links outside every package folder belong to no app
+	symlink(t, filepath.Join(root, "elsewhere.exe"), filepath.Join(links, "elsewhere.exe"))
+
+	const uninstall = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`
+	reg := winreg.NewFake()
+	reg.SetString(winreg.LocalMachine, uninstall+`\Contoso.Tool`, "WinGetPackageIdentifier", "Contoso.Tool")
+	reg.SetString(winreg.LocalMachine, uninstall+`\Contoso.Tool`, "DisplayName", "Contoso Tool")
+	reg.SetString(winreg.LocalMachine, uninstall+`\Contoso.Tool`, "DisplayVersion", "3.0")
+	reg.SetString(winreg.LocalMachine, uninstall+`\Fabrikam`, "WinGetPackageIdentifier", "Fabrikam.App")
+	reg.SetString(winreg.LocalMachine, uninstall+`\Fabrikam`, "DisplayName", "Fabrikam")
+	reg.SetString(winreg.LocalMachine, uninstall+`\Fabrikam`, "Publisher", "Fabrikam Inc.")
+	reg.SetString(winreg.LocalMachine, uninstall+`\Fabrikam`, "InstallLocation", filepath.Join(root, "Fabrikam"))
+	reg.SetString(winreg.CurrentUser, uninstall+`\Litware`, "WinGetPackageIdentifier", "Litware.Notes")
+	reg.SetString(winreg.CurrentUser, uninstall+`\Other`, "DisplayName", "Not from winget")
+
+	apps, err := discoverWingetApps(reg, nil, []string{packages, filepath.Join(root, "missing")}, []string{links})
+	if err != nil {
+		t.Fatalf("discoverWingetApps: %v", err)
+	}
+	byName := appsByName(t, apps)
+	// Packages without a display name are named after the last part of their ID
+	if got, want := sortedNames(byName), []string{"Contoso Tool", "Fabrikam", "Notes"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("apps = %q, want %q", got, want)
+	}
+
+	// The portable package folder fills in the registered install folder
+	contoso := byName["Contoso Tool"]
+	if contoso.ID != appID("winget", "Contoso.Tool") || contoso.Version != "3.0" || contoso.InstallPath != filepath.Dir(filepath.Dir(tool)) {
+		t.Errorf("Contoso = %q %q %q", contoso.ID, contoso.Version, contoso.InstallPath)
+	}
+	if !reflect.DeepEqual(contoso.Executables, []string{tool}) {
+		t.Errorf("Contoso executables = %q, want %q", contoso.Executables, tool)
+	}
+	if want := map[string]string{filepath.Join(links, "tool.exe"): tool}; !reflect.DeepEqual(contoso.Shims, want) {
+		t.Errorf("Contoso shims = %q, want %q", contoso.Shims, want)
+	}
+
+	fabrikam := byName["Fabrikam"]
+	if fabrikam.Publisher != "Fabrikam Inc." || !reflect.DeepEqual(fabrikam.Executables, []string{filepath.Join(root, "Fabrikam", "fabrikam.exe")}) {
+		t.Errorf("Fabrikam = %q %q", fabrikam.Publisher, fabrikam.Executables)
+	}
+	if notes := byName["Notes"]; notes.InstallPath != "" || len(notes.Executables) != 0 {
+		t.Errorf("Notes = %q %q, want no files", notes.InstallPath, notes.Executables)
+	}
+}
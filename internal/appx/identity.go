@@ -0,0 +1,75 @@
+package appx
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// publisherIDAlphabet is Crockford's Base32 in lower case
+const publisherIDAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// PublisherID computes the 13-character publisher ID of a package
+// publisher, the suffix of package family names: the first 64 bits of
+// the SHA-256 of the UTF-16LE publisher string, padded to 65 bits and
+// encoded with Crockford's Base32.
+func PublisherID(publisher string) string {
+	units := utf16.Encode([]rune(publisher))
+	data := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(data[i*2:], u)
+	}
+	sum := sha256.Sum256(data)
+	v := binary.BigEndian.Uint64(sum[:8])
+
+	id := make([]byte, 13)
+	for i := range id {
+		shift := 59 - 5*i
+		var group uint64
+		if shift >= 0 {
+			group = v >> uint(shift) & 31
+		} else {
+			// The last group holds the final 4 bits and the padding bit
+			group = v << uint(-shift) & 31
+		}
+		id[i] = publisherIDAlphabet[group]
+	}
+	return string(id)
+}
+
+// FamilyName returns the package family name for a package name and publisher
+func FamilyName(name, publisher string) string {
+	return name + "_" + PublisherID(publisher)
+}
+
+// FullName is a parsed package full name:
+// Name_Version_Architecture_ResourceID_PublisherID
+type FullName struct {
+	Name         string
+	Version      string
+	Architecture string
+	ResourceID   string
+	PublisherID  string
+}
+
+// ParseFullName splits a package full name into its parts
+func ParseFullName(s string) (FullName, error) {
+	parts := strings.Split(s, "_")
+	if len(parts) != 5 || parts[0] == "" || parts[4] == "" {
+		return FullName{}, fmt.Errorf("invalid package full name %q", s)
+	}
+	return FullName{
+		Name:         parts[0],
+		Version:      parts[1],
+		Architecture: parts[2],
+		ResourceID:   parts[3],
+		PublisherID:  parts[4],
+	}, nil
+}
+
+// FamilyName returns the family name of the package
+func (f FullName) FamilyName() string {
+	return f.Name + "_" + f.PublisherID
+}
@@ -0,0 +1,96 @@
+package appx
+
+import "testing"
+
+const (
+	microsoftCorporation = "CN=Microsoft Corporation, O=Microsoft Corporation, L=Redmond, S=Washington, C=US"
+	microsoftWindows     = "CN=Microsoft Windows, O=Microsoft Corporation, L=Redmond, S=Washington, C=US"
+)
+
+func TestPublisherID(t *testing.T) {
+	tests := []struct {
+		publisher string
+		want      string
+	}{
+		{microsoftCorporation, "8wekyb3d8bbwe"},
+		{microsoftWindows, "cw5n1h2txyewy"},
+	}
+	for _, tt := range tests {
+		if got := PublisherID(tt.publisher); got != tt.want {
+			t.Errorf("PublisherID(%q) = %s, want %s", tt.publisher, got, tt.want)
+		}
+	}
+
+	// Unlike family names, publishers are hashed as they are
+	if got := PublisherID("cn=microsoft corporation, o=microsoft corporation, l=redmond, s=washington, c=us"); got == "8wekyb3d8bbwe" {
+		t.Errorf("PublisherID ignored the case of the publisher")
+	}
+}
+
+func TestFamilyName(t *testing.T) {
+	got := FamilyName("Microsoft.MicrosoftEdge", microsoftCorporation)
+	if want := "Microsoft.MicrosoftEdge_8wekyb3d8bbwe"; got != want {
+		t.Errorf("FamilyName = %s, want %s", got, want)
+	}
+	if sid := ContainerSID(FamilyName("Microsoft.Windows.Cortana", microsoftWindows)); sid != cortanaSID {
+		t.Errorf("ContainerSID of Cortana's computed family name = %s, want %s", sid, cortanaSID)
+	}
+}
+
+func TestParseFullName(t *testing.T) {
+	tests := []struct {
+		full   string
+		want   FullName
+		family string
+	}{
+		{
+			full:   "Microsoft.MicrosoftEdge_44.19041.1266.0_neutral__8wekyb3d8bbwe",
+			want:   FullName{Name: "Microsoft.MicrosoftEdge", Version: "44.19041.1266.0", Architecture: "neutral", PublisherID: "8wekyb3d8bbwe"},
+			family: "Microsoft.MicrosoftEdge_8wekyb3d8bbwe",
+		},
+		{
+			full:   "Microsoft.Windows.Cortana_1.14.2.19041_neutral_neutral_cw5n1h2txyewy",
+			want:   FullName{Name: "Microsoft.Windows.Cortana", Version: "1.14.2.19041", Architecture: "neutral", ResourceID: "neutral", PublisherID: "cw5n1h2txyewy"},
+			family: "Microsoft.Windows.Cortana_cw5n1h2txyewy",
+		},
+		{
+			full:   "Microsoft.WindowsStore_22301.1401.6.0_x64__8wekyb3d8bbwe",
+			want:   FullName{Name: "Microsoft.WindowsStore", Version: "22301.1401.6.0", Architecture: "x64", PublisherID: "8wekyb3d8bbwe"},
+			family: "Microsoft.WindowsStore_8wekyb3d8bbwe",
+		},
+		{
+			full:   "Microsoft.WindowsStore_22301.1401.6.0_neutral_~_8wekyb3d8bbwe",
+			want:   FullName{Name: "Microsoft.WindowsStore", Version: "22301.1401.6.0", Architecture: "neutral", ResourceID: "~", PublisherID: "8wekyb3d8bbwe"},
+			family: "Microsoft.WindowsStore_8wekyb3d8bbwe",
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseFullName(tt.full)
+		if err != nil {
+			t.Errorf("ParseFullName(%q): %v", tt.full, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFullName(%q) = %+v, want %+v", tt.full, got, tt.want)
+		}
+		if family := got.FamilyName(); family != tt.family {
+			t.Errorf("FamilyName of %q = %s, want %s", tt.full, family, tt.family)
+		}
+	}
+	if got, _ := ParseFullName("Microsoft.MicrosoftEdge_44.19041.1266.0_neutral__8wekyb3d8bbwe"); ContainerSID(got.FamilyName()) != edgeSID {
+		t.Errorf("ContainerSID of a parsed full name does not match Edge")
+	}
+
+	invalid := []string{
+		"",
+		"Microsoft.MicrosoftEdge_8wekyb3d8bbwe",
+		"Microsoft.MicrosoftEdge_44.19041.1266.0_neutral__",
+		"_44.19041.1266.0_neutral__8wekyb3d8bbwe",
+		"Microsoft_Edge_44.19041.1266.0_neutral__8wekyb3d8bbwe",
+	}
+	for _, s := range invalid {
+		if _, err := ParseFullName(s); err == nil {
+			t.Errorf("ParseFullName(%q) succeeded, want an error", s)
+		}
+	}
+}
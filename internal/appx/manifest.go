@@ -0,0 +1,181 @@
+package appx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NetworkCapabilities are the manifest capabilities that grant network access
+var NetworkCapabilities = []string{"internetClient", "internetClientServer", "privateNetworkClientServer"}
+
+// Manifest is the part of an AppxManifest.xml that describes a package
+type Manifest struct {
+	Name                 string
+	Publisher            string
+	Version              string
+	Architecture         string
+	DisplayName          string
+	PublisherDisplayName string
+	// Logo is relative to the package folder
+	Logo         string
+	Framework    bool
+	Resource     bool
+	Applications []Application
+	Capabilities []string
+}
+
+// Application is one <Application> a package declares
+type Application struct {
+	ID          string
+	Executable  string
+	EntryPoint  string
+	DisplayName string
+	// Logo is the best of the tile logos, relative to the package folder
+	Logo string
+}
+
+// xmlManifest mirrors the manifest elements we read. Elements are matched
+// by local name, so the uap/rescap namespaces of newer manifests work too.
+type xmlManifest struct {
+	Identity struct {
+		Name         string `xml:"Name,attr"`
+		Publisher    string `xml:"Publisher,attr"`
+		Version      string `xml:"Version,attr"`
+		Architecture string `xml:"ProcessorArchitecture,attr"`
+	} `xml:"Identity"`
+	Properties struct {
+		DisplayName          string `xml:"DisplayName"`
+		PublisherDisplayName string `xml:"PublisherDisplayName"`
+		Logo                 string `xml:"Logo"`
+		Framework            bool   `xml:"Framework"`
+		ResourcePackage      bool   `xml:"ResourcePackage"`
+	} `xml:"Properties"`
+	Applications []struct {
+		ID             string `xml:"Id,attr"`
+		Executable     string `xml:"Executable,attr"`
+		EntryPoint     string `xml:"EntryPoint,attr"`
+		VisualElements struct {
+			DisplayName       string `xml:"DisplayName,attr"`
+			Square44x44Logo   string `xml:"Square44x44Logo,attr"`
+			Square150x150Logo string `xml:"Square150x150Logo,attr"`
+		} `xml:"VisualElements"`
+	} `xml:"Applications>Application"`
+	Capabilities struct {
+		Items []struct {
+			Name string `xml:"Name,attr"`
+		} `xml:",any"`
+	} `xml:"Capabilities"`
+}
+
+// Open reads the AppxManifest.xml of a package folder
+func Open(packageDir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(packageDir, "AppxManifest.xml"))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes an AppxManifest.xml
+func Parse(data []byte) (*Manifest, error) {
+	var x xmlManifest
+	if err := xml.Unmarshal(data, &x); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if x.Identity.Name == "" {
+		return nil, fmt.Errorf("manifest has no identity")
+	}
+
+	m := &Manifest{
+		Name:                 x.Identity.Name,
+		Publisher:            x.Identity.Publisher,
+		Version:              x.Identity.Version,
+		Architecture:         x.Identity.Architecture,
+		DisplayName:          x.Properties.DisplayName,
+		PublisherDisplayName: x.Properties.PublisherDisplayName,
+		Logo:                 x.Properties.Logo,
+		Framework:            x.Properties.Framework,
+		Resource:             x.Properties.ResourcePackage,
+	}
+	for _, a := range x.Applications {
+		logo := a.VisualElements.Square150x150Logo
+		if logo == "" {
+			logo = a.VisualElements.Square44x44Logo
+		}
+		m.Applications = append(m.Applications, Application{
+			ID:          a.ID,
+			Executable:  a.Executable,
+			EntryPoint:  a.EntryPoint,
+			DisplayName: a.VisualElements.DisplayName,
+			Logo:        logo,
+		})
+	}
+	for _, c := range x.Capabilities.Items {
+		if c.Name != "" {
+			m.Capabilities = append(m.Capabilities, c.Name)
+		}
+	}
+	return m, nil
+}
+
+// FamilyName returns the package family name of the manifest's identity
+func (m *Manifest) FamilyName() string {
+	return FamilyName(m.Name, m.Publisher)
+}
+
+// NetworkCapabilities returns the network capabilities the package declares
+func (m *Manifest) NetworkCapabilities() []string {
+	var caps []string
+	for _, c := range m.Capabilities {
+		for _, n := range NetworkCapabilities {
+			if strings.EqualFold(c, n) {
+				caps = append(caps, n)
+			}
+		}
+	}
+	return caps
+}
+
+// IsResourceString reports whether a manifest string is an ms-resource
+// reference, which needs the package's resources.pri to resolve
+func IsResourceString(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), "ms-resource:")
+}
+
+// ResolveAsset finds the file for an asset path on disk. Packages usually
+// ship only scaled variants, so "Assets\Logo.png" may exist as
+// "Assets\Logo.scale-200.png"; the largest scale is preferred.
+func ResolveAsset(packageDir, asset string) string {
+	if asset == "" {
+		return ""
+	}
+	path := filepath.Join(packageDir, filepath.FromSlash(strings.ReplaceAll(asset, `\`, "/")))
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	matches, _ := filepath.Glob(base + ".*" + ext)
+	best, bestScale := "", -1
+	for _, m := range matches {
+		qualifier := strings.TrimSuffix(strings.TrimPrefix(m, base+"."), ext)
+		scale := 0
+		// Qualifiers look like "scale-200" or "targetsize-48_altform-unplated"
+		for _, q := range strings.Split(qualifier, "_") {
+			var n int
+			if _, err := fmt.Sscanf(q, "scale-%d", &n); err == nil && n > scale {
+				scale = n
+			} else if _, err := fmt.Sscanf(q, "targetsize-%d", &n); err == nil && n > scale {
+				scale = n
+			}
+		}
+		if scale > bestScale {
+			best, bestScale = m, scale
+		}
+	}
+	return best
+}
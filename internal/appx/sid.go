@@ -0,0 +1,34 @@
+package appx
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// ContainerSID derives the AppContainer SID of a package from its family
+// name, the same way Windows does: the SHA-256 of the lowercased family
+// name in UTF-16LE, read as eight little-endian 32-bit values of which the
+// first seven follow the S-1-15-2 authority. For example:
+//
+//	Microsoft.MicrosoftEdge_8wekyb3d8bbwe
+//	  S-1-15-2-3624051433-2125758914-1423191267-1740899205-1073925389-3782572162-737981194
+//	Microsoft.WindowsCalculator_8wekyb3d8bbwe
+//	  S-1-15-2-466767348-3739614953-2700836392-1801644223-4227750657-1087833535-2488631167
+func ContainerSID(familyName string) string {
+	units := utf16.Encode([]rune(strings.ToLower(familyName)))
+	data := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(data[i*2:], u)
+	}
+	sum := sha256.Sum256(data)
+
+	var b strings.Builder
+	b.WriteString("S-1-15-2")
+	for i := 0; i < 7; i++ {
+		fmt.Fprintf(&b, "-%d", binary.LittleEndian.Uint32(sum[i*4:]))
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package appx
+
+import "testing"
+
+// Package SIDs as Windows reports them, for example through
+// CheckNetIsolation LoopbackExempt -s
+const (
+	edgeSID    = "S-1-15-2-3624051433-2125758914-1423191267-1740899205-1073925389-3782572162-737981194"
+	cortanaSID = "S-1-15-2-1861897761-1695161497-2927542615-642690995-327840285-2659745135-2630312742"
+	storeSID   = "S-1-15-2-1609473798-1231923017-684268153-4268514328-882773646-2760585773-1760938157"
+)
+
+func TestContainerSID(t *testing.T) {
+	tests := []struct {
+		family string
+		want   string
+	}{
+		{"Microsoft.MicrosoftEdge_8wekyb3d8bbwe", edgeSID},
+		{"Microsoft.Windows.Cortana_cw5n1h2txyewy", cortanaSID},
+		{"Microsoft.WindowsStore_8wekyb3d8bbwe", storeSID},
+		// Windows lowercases the family name before hashing
+		{"microsoft.microsoftedge_8wekyb3d8bbwe", edgeSID},
+		{"MICROSOFT.MICROSOFTEDGE_8WEKYB3D8BBWE", edgeSID},
+		{"Microsoft.windows.CORTANA_CW5N1H2TXYEWY", cortanaSID},
+	}
+	for _, tt := range tests {
+		if got := ContainerSID(tt.family); got != tt.want {
+			t.Errorf("ContainerSID(%q) = %s, want %s", tt.family, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package firewall
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/go-ole/go-ole"
 	"github.com/go-ole/go-ole/oleutil"
@@ -106,3 +107,52 @@ func (m *Manager) UnblockApps(exePaths []string) map[string]error {
 	}
 	return <-resultChan
 }
+
+// RemoveRules removes Enodia rules by name. Names without the Enodia
+// prefix are refused, so rules of other programs are never touched.
+func (m *Manager) RemoveRules(names []string) map[string]error {
+	resultChan := make(chan map[string]error, 1)
+	m.jobs <- func(rules *ole.IDispatch) {
+		results := make(map[string]error)
+		for _, name := range names {
+			if !strings.HasPrefix(name, RULE_PREFIX) {
+				results[name] = fmt.Errorf("%s is not an Enodia rule", name)
+				continue
+			}
+			if _, err := oleutil.CallMethod(rules, "Remove", name); err != nil {
+				results[name] = fmt.Errorf("failed to remove rule: %w", err)
+				continue
+			}
+			results[name] = nil
+			log.Printf("[Enodia] Removed rule: %s", name)
+		}
+		resultChan <- results
+	}
+	return <-resultChan
+}
+
+// PurgeRules removes every Enodia rule and returns how many were removed
+func (m *Manager) PurgeRules() (int, error) {
+	rules, err := m.ListRules()
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, 0, len(rules))
+	for _, r := range rules {
+		names = append(names, r.Name)
+	}
+
+	removed := 0
+	for name, err := range m.RemoveRules(names) {
+		if err != nil {
+			log.Printf("[Enodia] Warning: Could not remove rule %s: %v", name, err)
+			continue
+		}
+		removed++
+	}
+	log.Printf("[Enodia] Purged %d rules", removed)
+	if removed < len(names) {
+		return removed, fmt.Errorf("%d of %d rules could not be removed", len(names)-removed, len(names))
+	}
+	return removed, nil
+}
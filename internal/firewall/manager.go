@@ -15,13 +15,18 @@ type Manager struct {
 	stop    chan struct{}
 	wg      sync.WaitGroup
 	running bool
+
+	// details caches the version details of blocked executables by path
+	detailsMu sync.Mutex
+	details   map[string]cachedDetails
 }
 
 // NewManager initializes the background COM worker
 func NewManager() *Manager {
 	m := &Manager{
-		jobs: make(chan func(*ole.IDispatch)),
-		stop: make(chan struct{}),
+		jobs:    make(chan func(*ole.IDispatch)),
+		stop:    make(chan struct{}),
+		details: make(map[string]cachedDetails),
 	}
 	m.running = true
 	m.wg.Add(1)
@@ -1,8 +1,11 @@
 package firewall
 
 import (
+	"enodia/internal/pe"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-ole/go-ole"
 	"github.com/go-ole/go-ole/oleutil"
@@ -15,6 +18,7 @@ func (m *Manager) GetBlockedApps() ([]BlockedApp, error) {
 
 	m.jobs <- func(rules *ole.IDispatch) {
 		appMap := make(map[string]*BlockedApp)
+		ruleCount := make(map[string]int)
 
 		err := oleutil.ForEach(rules, func(v *ole.VARIANT) error {
 			ruleDispatch := v.ToIDispatch()
@@ -28,6 +32,7 @@ func (m *Manager) GetBlockedApps() ([]BlockedApp, error) {
 			}
 
 			appPathVar, _ := oleutil.GetProperty(ruleDispatch, "ApplicationName")
+			packageVar, _ := oleutil.GetProperty(ruleDispatch, "LocalAppPackageId")
 			enabledVar, _ := oleutil.GetProperty(ruleDispatch, "Enabled")
 
 			appPath := appPathVar.ToString()
@@ -48,12 +53,13 @@ func (m *Manager) GetBlockedApps() ([]BlockedApp, error) {
 
 			app, exists := appMap[appPath]
 			if !exists {
-				app = &BlockedApp{
-					AppPath:     appPath,
-					DisplayName: extractDisplayName(appPath),
-				}
+				app = &BlockedApp{AppPath: appPath}
 				appMap[appPath] = app
 			}
+			ruleCount[appPath]++
+			if sid := packageVar.ToString(); sid != "" {
+				app.PackageSID = sid
+			}
 
 			if strings.HasPrefix(name, RULE_PREFIX_OUT) {
 				app.OutboundBlocked = enabled
@@ -70,7 +76,8 @@ func (m *Manager) GetBlockedApps() ([]BlockedApp, error) {
 		}
 
 		result := make([]BlockedApp, 0, len(appMap))
-		for _, app := range appMap {
+		for path, app := range appMap {
+			app.Paused = ruleCount[path] > 0 && !app.InboundBlocked && !app.OutboundBlocked
 			result = append(result, *app)
 		}
 		resultChan <- result
@@ -78,12 +85,121 @@ func (m *Manager) GetBlockedApps() ([]BlockedApp, error) {
 
 	select {
 	case res := <-resultChan:
+		// File access stays off the COM thread
+		m.describeBlockedApps(res)
 		return res, nil
 	case err := <-errChan:
 		return nil, err
 	}
 }
 
+// ListRules returns every Enodia rule with the executable or package it applies to
+func (m *Manager) ListRules() ([]Rule, error) {
+	resultChan := make(chan []Rule, 1)
+	errChan := make(chan error, 1)
+
+	m.jobs <- func(rules *ole.IDispatch) {
+		var result []Rule
+		err := oleutil.ForEach(rules, func(v *ole.VARIANT) error {
+			ruleDispatch := v.ToIDispatch()
+			defer ruleDispatch.Release()
+
+			nameVar, _ := oleutil.GetProperty(ruleDispatch, "Name")
+			name := nameVar.ToString()
+			if !strings.HasPrefix(name, RULE_PREFIX) {
+				return nil
+			}
+
+			appPathVar, _ := oleutil.GetProperty(ruleDispatch, "ApplicationName")
+			packageVar, _ := oleutil.GetProperty(ruleDispatch, "LocalAppPackageId")
+			enabledVar, _ := oleutil.GetProperty(ruleDispatch, "Enabled")
+
+			rule := Rule{
+				Name:       name,
+				AppPath:    appPathVar.ToString(),
+				PackageSID: packageVar.ToString(),
+				Enabled:    enabledVar.Val != 0,
+			}
+			if strings.HasPrefix(name, RULE_PREFIX_IN) {
+				rule.Direction = "inbound"
+			} else if strings.HasPrefix(name, RULE_PREFIX_OUT) {
+				rule.Direction = "outbound"
+			}
+			result = append(result, rule)
+			return nil
+		})
+
+		if err != nil {
+			errChan <- err
+			return
+		}
+		resultChan <- result
+	}
+
+	select {
+	case res := <-resultChan:
+		return res, nil
+	case err := <-errChan:
+		return nil, err
+	}
+}
+
+// cachedDetails avoids re-reading executables that did not change
+type cachedDetails struct {
+	size    int64
+	modTime time.Time
+	info    *pe.VersionInfo
+}
+
+// describeBlockedApps fills the display names and version details of
+// blocked apps. Version resources are read again only when a file changed.
+func (m *Manager) describeBlockedApps(blocked []BlockedApp) {
+	m.detailsMu.Lock()
+	defer m.detailsMu.Unlock()
+
+	details := make(map[string]cachedDetails, len(blocked))
+	for i := range blocked {
+		app := &blocked[i]
+		if strings.HasPrefix(app.AppPath, "PKG-") {
+			app.DisplayName = strings.TrimPrefix(app.AppPath, "PKG-")
+			continue
+		}
+
+		var info *pe.VersionInfo
+		if stat, err := os.Stat(app.AppPath); err == nil {
+			key := strings.ToLower(app.AppPath)
+			c, ok := m.details[key]
+			if !ok || c.size != stat.Size() || !c.modTime.Equal(stat.ModTime()) {
+				c = cachedDetails{size: stat.Size(), modTime: stat.ModTime()}
+				c.info, _ = pe.ReadVersionInfo(app.AppPath)
+			}
+			details[key] = c
+			info = c.info
+		}
+		describeBlockedApp(app, info)
+	}
+	m.details = details
+}
+
+// describeBlockedApp fills the display name and version details of a
+// blocked executable from its version resource, which may be nil
+func describeBlockedApp(app *BlockedApp, info *pe.VersionInfo) {
+	if info == nil {
+		app.DisplayName = extractDisplayName(app.AppPath)
+		return
+	}
+	app.Description = info.FileDescription
+	app.ProductName = info.ProductName
+	app.CompanyName = info.CompanyName
+	app.Version = info.FileVersion
+	app.Architecture = info.Architecture
+
+	app.DisplayName = info.DisplayName()
+	if app.DisplayName == "" {
+		app.DisplayName = extractDisplayName(app.AppPath)
+	}
+}
+
 // extractDisplayName gets a user-friendly name from the exe path
 func extractDisplayName(exePath string) string {
 	base := filepath.Base(exePath)
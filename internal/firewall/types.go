@@ -31,6 +31,8 @@ type Rule struct {
 	AppPath   string `json:"appPath"`
 	Direction string `json:"direction"`
 	Enabled   bool   `json:"enabled"`
+	// PackageSID is set on the rules of Store apps, which have no AppPath
+	PackageSID string `json:"packageSid,omitempty"`
 }
 
 // BlockedApp represents an application with its block status
@@ -39,4 +41,13 @@ type BlockedApp struct {
 	DisplayName     string `json:"displayName"`
 	InboundBlocked  bool   `json:"inboundBlocked"`
 	OutboundBlocked bool   `json:"outboundBlocked"`
+	Description     string `json:"description"`
+	ProductName     string `json:"productName"`
+	CompanyName     string `json:"companyName"`
+	Version         string `json:"version"`
+	Architecture    string `json:"architecture"`
+	// Paused is set when the app has Enodia rules but all are disabled
+	Paused bool `json:"paused"`
+	// PackageSID is the package SID the rules of a Store app apply to
+	PackageSID string `json:"packageSid,omitempty"`
 }
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package lnk
+
+// windows1252 maps the bytes 0x80-0x9f of Windows-1252, the ANSI code page
+// of Western Windows installs; the rest match Latin-1
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// decodeANSI converts a string to UTF-8 assuming Windows-1252 on platforms
+// without a system ANSI code page
+func decodeANSI(b []byte) string {
+	if isASCII(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		if c >= 0x80 && c < 0xa0 {
+			runes[i] = windows1252[c-0x80]
+		} else {
+			runes[i] = rune(c)
+		}
+	}
+	return string(runes)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package lnk
+
+import "testing"
+
+func TestDecodeANSI(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"C:\\Tools\\app.exe", "C:\\Tools\\app.exe"},
+		{"Caf\xe9", "Café"},
+		{"\x80 \x99 \x9f", "€ ™ Ÿ"},
+		{"\x81", "\u0081"},
+	}
+	for _, tt := range tests {
+		if got := decodeANSI([]byte(tt.in)); got != tt.want {
+			t.Errorf("decodeANSI(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package lnk
+
+import "golang.org/x/sys/windows"
+
+// cpACP selects the system ANSI code page in MultiByteToWideChar
+const cpACP = 0
+
+// decodeANSI converts a string in the system ANSI code page to UTF-8
+func decodeANSI(b []byte) string {
+	if isASCII(b) {
+		return string(b)
+	}
+	n, err := windows.MultiByteToWideChar(cpACP, 0, &b[0], int32(len(b)), nil, 0)
+	if err != nil || n == 0 {
+		return string(b)
+	}
+	u := make([]uint16, n)
+	if _, err := windows.MultiByteToWideChar(cpACP, 0, &b[0], int32(len(b)), &u[0], n); err != nil {
+		return string(b)
+	}
+	return windows.UTF16ToString(u)
+}
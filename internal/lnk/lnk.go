@@ -0,0 +1,269 @@
+package lnk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unicode/utf16"
+)
+
+// Link flags from [MS-SHLLINK] 2.1.1
+const (
+	hasLinkTargetIDList = 1 << 0
+	hasLinkInfo         = 1 << 1
+	hasName             = 1 << 2
+	hasRelativePath     = 1 << 3
+	hasWorkingDir       = 1 << 4
+	hasArguments        = 1 << 5
+	hasIconLocation     = 1 << 6
+	isUnicode           = 1 << 7
+)
+
+const (
+	headerSize = 0x4c
+
+	linkInfoVolumeIDAndLocalBasePath = 1 << 0
+
+	environmentBlockSignature = 0xa0000001
+	iconEnvBlockSignature     = 0xa0000007
+)
+
+var linkCLSID = []byte{
+	0x01, 0x14, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46,
+}
+
+// Link is the parsed content of a Shell Link (.lnk) file
+type Link struct {
+	// Target is the local path the link points to. It may contain
+	// environment variables such as %ProgramFiles%.
+	Target       string
+	Arguments    string
+	WorkingDir   string
+	RelativePath string
+	Description  string
+	IconLocation string
+	IconIndex    int
+}
+
+// Open reads and parses a .lnk file
+func Open(path string) (*Link, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes a Shell Link as described in [MS-SHLLINK]: a fixed header,
+// an optional item ID list, an optional LinkInfo structure with the target
+// path, optional strings and trailing extra data blocks
+func Parse(data []byte) (*Link, error) {
+	if len(data) < headerSize ||
+		binary.LittleEndian.Uint32(data) != headerSize ||
+		!bytes.Equal(data[4:20], linkCLSID) {
+		return nil, errors.New("not a shell link")
+	}
+
+	flags := binary.LittleEndian.Uint32(data[20:])
+	link := &Link{IconIndex: int(int32(binary.LittleEndian.Uint32(data[56:])))}
+	r := &reader{data: data, pos: headerSize}
+
+	if flags&hasLinkTargetIDList != 0 {
+		size, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		if err := r.skip(int(size)); err != nil {
+			return nil, err
+		}
+	}
+
+	if flags&hasLinkInfo != 0 {
+		target, size, err := parseLinkInfo(data[r.pos:])
+		if err != nil {
+			return nil, err
+		}
+		link.Target = target
+		if err := r.skip(size); err != nil {
+			return nil, err
+		}
+	}
+
+	unicode := flags&isUnicode != 0
+	fields := []struct {
+		flag uint32
+		dest *string
+	}{
+		{hasName, &link.Description},
+		{hasRelativePath, &link.RelativePath},
+		{hasWorkingDir, &link.WorkingDir},
+		{hasArguments, &link.Arguments},
+		{hasIconLocation, &link.IconLocation},
+	}
+	for _, s := range fields {
+		if flags&s.flag == 0 {
+			continue
+		}
+		value, err := r.stringData(unicode)
+		if err != nil {
+			return nil, err
+		}
+		*s.dest = value
+	}
+
+	// Extra data blocks hold the unexpanded paths of links to locations
+	// like %ProgramFiles%, which LinkInfo may lack
+	for r.pos+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[r.pos:]))
+		if size < 8 || r.pos+size > len(data) {
+			break
+		}
+		block := data[r.pos : r.pos+size]
+		switch binary.LittleEndian.Uint32(block[4:]) {
+		case environmentBlockSignature:
+			if target := envBlockTarget(block); target != "" && link.Target == "" {
+				link.Target = target
+			}
+		case iconEnvBlockSignature:
+			if icon := envBlockTarget(block); icon != "" {
+				link.IconLocation = icon
+			}
+		}
+		r.pos += size
+	}
+
+	return link, nil
+}
+
+// parseLinkInfo returns the local target path from a LinkInfo structure
+// and the structure's total size
+func parseLinkInfo(data []byte) (string, int, error) {
+	if len(data) < 28 {
+		return "", 0, errors.New("link info truncated")
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	headerLen := int(binary.LittleEndian.Uint32(data[4:]))
+	flags := binary.LittleEndian.Uint32(data[8:])
+	if size < 28 || size > len(data) {
+		return "", 0, errors.New("link info truncated")
+	}
+	info := data[:size]
+
+	if flags&linkInfoVolumeIDAndLocalBasePath == 0 {
+		return "", size, nil
+	}
+
+	basePathOffset := int(binary.LittleEndian.Uint32(info[16:]))
+	suffixOffset := int(binary.LittleEndian.Uint32(info[24:]))
+
+	// Newer links carry Unicode copies of both paths
+	if headerLen >= 0x24 && size >= 36 {
+		uBase := int(binary.LittleEndian.Uint32(info[28:]))
+		uSuffix := int(binary.LittleEndian.Uint32(info[32:]))
+		if uBase > 0 {
+			return cStringUTF16(info, uBase) + cStringUTF16(info, uSuffix), size, nil
+		}
+	}
+	return cStringANSI(info, basePathOffset) + cStringANSI(info, suffixOffset), size, nil
+}
+
+// envBlockTarget reads the Unicode path of an environment-style extra data block
+func envBlockTarget(block []byte) string {
+	const ansiLen, unicodeLen = 260, 520
+	if len(block) < 8+ansiLen+unicodeLen {
+		return ""
+	}
+	if s := cStringUTF16(block, 8+ansiLen); s != "" {
+		return s
+	}
+	return cStringANSI(block, 8)
+}
+
+// reader walks the variable-length part of a link
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) uint16() (uint16, error) {
+	if r.pos+2 > len(r.data) {
+		return 0, errors.New("shell link truncated")
+	}
+	v := binary.LittleEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return v, nil
+}
+
+func (r *reader) skip(n int) error {
+	if r.pos+n > len(r.data) {
+		return errors.New("shell link truncated")
+	}
+	r.pos += n
+	return nil
+}
+
+// stringData reads a character-counted StringData entry
+func (r *reader) stringData(unicode bool) (string, error) {
+	count, err := r.uint16()
+	if err != nil {
+		return "", err
+	}
+	n := int(count)
+	if unicode {
+		n *= 2
+	}
+	if r.pos+n > len(r.data) {
+		return "", fmt.Errorf("string data truncated")
+	}
+	raw := r.data[r.pos : r.pos+n]
+	r.pos += n
+	if unicode {
+		return decodeUTF16(raw), nil
+	}
+	return decodeANSI(raw), nil
+}
+
+// cStringANSI reads a NUL-terminated string in the ANSI code page at offset
+func cStringANSI(b []byte, offset int) string {
+	if offset <= 0 || offset >= len(b) {
+		return ""
+	}
+	end := bytes.IndexByte(b[offset:], 0)
+	if end < 0 {
+		end = len(b) - offset
+	}
+	return decodeANSI(b[offset : offset+end])
+}
+
+// cStringUTF16 reads a NUL-terminated UTF-16 string at offset
+func cStringUTF16(b []byte, offset int) string {
+	if offset <= 0 || offset >= len(b) {
+		return ""
+	}
+	end := offset
+	for end+1 < len(b) && (b[end] != 0 || b[end+1] != 0) {
+		end += 2
+	}
+	return decodeUTF16(b[offset:end])
+}
+
+// isASCII reports whether b decodes the same in every ANSI code page
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
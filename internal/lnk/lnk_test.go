@@ -0,0 +1,245 @@
+package lnk
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16Bytes encodes s as null-terminated little-endian UTF-16
+func utf16Bytes(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return append(b, 0, 0)
+}
+
+// buildHeader makes a shell link header with the given flags and icon index
+func buildHeader(flags uint32, iconIndex int32) []byte {
+	h := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(h[0:], headerSize)
+	copy(h[4:], linkCLSID)
+	binary.LittleEndian.PutUint32(h[20:], flags)
+	binary.LittleEndian.PutUint32(h[56:], uint32(iconIndex))
+	return h
+}
+
+// buildLinkInfo makes a LinkInfo structure with ANSI base and suffix paths
+// and, when unicodeBase is set, Unicode copies of them
+func buildLinkInfo(base, suffix []byte, unicodeBase, unicodeSuffix string) []byte {
+	headerLen := 0x1c
+	if unicodeBase != "" {
+		headerLen = 0x24
+	}
+	info := make([]byte, headerLen)
+	binary.LittleEndian.PutUint32(info[4:], uint32(headerLen))
+	binary.LittleEndian.PutUint32(info[8:], linkInfoVolumeIDAndLocalBasePath)
+
+	put := func(at int, data []byte) {
+		binary.LittleEndian.PutUint32(info[at:], uint32(len(info)))
+		info = append(info, data...)
+	}
+	// An empty VolumeID
+	binary.LittleEndian.PutUint32(info[12:], uint32(len(info)))
+	info = append(info, make([]byte, 16)...)
+	put(16, append(base, 0))
+	put(24, append(suffix, 0))
+	if unicodeBase != "" {
+		put(28, utf16Bytes(unicodeBase))
+		put(32, utf16Bytes(unicodeSuffix))
+	}
+	binary.LittleEndian.PutUint32(info[0:], uint32(len(info)))
+	return info
+}
+
+// stringData makes a character-counted StringData entry
+func stringData(s string, unicode bool) []byte {
+	if !unicode {
+		return append(binary.LittleEndian.AppendUint16(nil, uint16(len(s))), s...)
+	}
+	u := utf16.Encode([]rune(s))
+	b := binary.LittleEndian.AppendUint16(nil, uint16(len(u)))
+	for _, c := range u {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return b
+}
+
+// envBlock makes an environment-style extra data block holding an ANSI
+// and a Unicode path
+func envBlock(signature uint32, ansi []byte, unicode string) []byte {
+	block := make([]byte, 8+260+520)
+	binary.LittleEndian.PutUint32(block[0:], uint32(len(block)))
+	binary.LittleEndian.PutUint32(block[4:], signature)
+	copy(block[8:], ansi)
+	if unicode != "" {
+		copy(block[8+260:], utf16Bytes(unicode))
+	}
+	return block
+}
+
+// terminalBlock ends the extra data
+var terminalBlock = []byte{0, 0, 0, 0}
+
+func join(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestParseANSI(t *testing.T) {
+	data := join(
+		buildHeader(hasLinkTargetIDList|hasLinkInfo|hasName|hasWorkingDir|hasArguments, 3),
+		[]byte{4, 0, 0xaa, 0xbb, 0xcc, 0xdd},
+		buildLinkInfo([]byte("C:\\Tools\\Caf\xe9\\"), []byte("app.exe"), "", ""),
+		stringData("Caf\xe9 editor", false),
+		stringData("C:\\Tools", false),
+		stringData("--new", false),
+		terminalBlock,
+	)
+
+	link, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	cafe := "Caf" + decodeANSI([]byte{0xe9})
+	want := Link{
+		Target:      "C:\\Tools\\" + cafe + "\\app.exe",
+		Description: cafe + " editor",
+		WorkingDir:  "C:\\Tools",
+		Arguments:   "--new",
+		IconIndex:   3,
+	}
+	if *link != want {
+		t.Errorf("Parse = %+v, want %+v", *link, want)
+	}
+}
+
+func TestParseUnicode(t *testing.T) {
+	data := join(
+		buildHeader(hasLinkInfo|hasRelativePath|hasIconLocation|isUnicode, -2),
+		buildLinkInfo([]byte("C:\\?\\"), []byte("app.exe"), "C:\\Программы\\", "app.exe"),
+		stringData("..\\Программы\\app.exe", true),
+		stringData("C:\\Программы\\app.ico", true),
+	)
+
+	link, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := Link{
+		Target:       "C:\\Программы\\app.exe",
+		RelativePath: "..\\Программы\\app.exe",
+		IconLocation: "C:\\Программы\\app.ico",
+		IconIndex:    -2,
+	}
+	if *link != want {
+		t.Errorf("Parse = %+v, want %+v", *link, want)
+	}
+}
+
+func TestParseEnvironmentBlock(t *testing.T) {
+	data := join(
+		buildHeader(hasIconLocation, 0),
+		stringData("C:\\Program Files\\App\\app.ico", false),
+		envBlock(environmentBlockSignature, []byte("%ProgramFiles%\\App\\ansi.exe"), "%ProgramFiles%\\App\\app.exe"),
+		envBlock(iconEnvBlockSignature, []byte("%ProgramFiles%\\App\\app.ico"), ""),
+		terminalBlock,
+	)
+
+	link, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	// The Unicode path wins over the ANSI one, which fills in for it when empty
+	if link.Target != "%ProgramFiles%\\App\\app.exe" {
+		t.Errorf("Target = %q, want the Unicode path of the environment block", link.Target)
+	}
+	if link.IconLocation != "%ProgramFiles%\\App\\app.ico" {
+		t.Errorf("IconLocation = %q, want the ANSI path of the icon block", link.IconLocation)
+	}
+
+	// LinkInfo takes precedence over the environment block
+	data = join(
+		buildHeader(hasLinkInfo, 0),
+		buildLinkInfo([]byte("C:\\App\\"), []byte("app.exe"), "", ""),
+		envBlock(environmentBlockSignature, nil, "%ProgramFiles%\\App\\app.exe"),
+	)
+	if link, err := Parse(data); err != nil || link.Target != "C:\\App\\app.exe" {
+		t.Errorf("Parse with LinkInfo: Target = %q, %v; want C:\\App\\app.exe", link.Target, err)
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	body := join(
+		buildHeader(hasLinkTargetIDList|hasLinkInfo|hasName|hasArguments|isUnicode, 0),
+		[]byte{2, 0, 0xaa, 0xbb},
+		buildLinkInfo([]byte("C:\\App\\"), []byte("app.exe"), "C:\\App\\", "app.exe"),
+		stringData("App", true),
+		stringData("--new", true),
+	)
+	data := join(body, envBlock(environmentBlockSignature, nil, "%ProgramFiles%\\App\\app.exe"))
+
+	// Cuts before the end of the strings fail; a cut extra data block is
+	// ignored
+	for n := 0; n < len(data); n++ {
+		link, err := Parse(data[:n])
+		if n < len(body) {
+			if err == nil {
+				t.Fatalf("Parse of %d of %d bytes succeeded, want an error", n, len(data))
+			}
+			continue
+		}
+		if err != nil || link.Target != "C:\\App\\app.exe" {
+			t.Fatalf("Parse of %d bytes: Target = %q, %v; want C:\\App\\app.exe", n, link.Target, err)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	valid := join(
+		buildHeader(hasLinkInfo, 0),
+		buildLinkInfo([]byte("C:\\App\\"), []byte("app.exe"), "", ""),
+	)
+
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+	}{
+		{"wrong header size", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[0:], 0x50)
+			return data
+		}},
+		{"wrong class ID", func(data []byte) []byte {
+			data[4] = 0
+			return data
+		}},
+		{"link info size past the end", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[headerSize:], 0xffff)
+			return data
+		}},
+		{"link info size below its header", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[headerSize:], 4)
+			return data
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.modify(append([]byte(nil), valid...))
+			if _, err := Parse(data); err == nil {
+				t.Error("Parse succeeded, want an error")
+			}
+		})
+	}
+
+	// Offsets past the structure yield an empty path rather than a panic
+	data := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(data[headerSize+16:], 0xffff)
+	binary.LittleEndian.PutUint32(data[headerSize+24:], 0xffff)
+	if link, err := Parse(data); err != nil || link.Target != "" {
+		t.Errorf("Parse with bad offsets: Target = %q, %v; want empty", link.Target, err)
+	}
+}
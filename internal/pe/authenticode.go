@@ -0,0 +1,621 @@
+package pe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"os"
+	"time"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// ErrNotSigned is returned for PE files without an embedded signature
+var ErrNotSigned = errors.New("file is not signed")
+
+const (
+	certTypePKCSSignedData = 0x0002
+	securityDirIndex       = 4
+)
+
+var (
+	oidSignedData         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidCounterSignature   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidRFC3161Timestamp   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+	oidSpcIndirectData    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidDigestSHA1         = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidSHA1WithRSA        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}
+	oidSHA256WithRSA      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidRSAEncryption      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256    = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384    = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512    = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidECPublicKey        = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidTimestampTokenInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+)
+
+// trustedRoots are the roots certificate chains must end in; nil means the
+// system roots
+var trustedRoots *x509.CertPool
+
+// Signature describes the Authenticode signature embedded in a PE file
+type Signature struct {
+	Subject      string    `json:"subject"`
+	Organization string    `json:"organization"`
+	Issuer       string    `json:"issuer"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	// SigningTime is the time a verified timestamp vouches for; it is zero
+	// when there is no timestamp or it does not check out
+	SigningTime time.Time `json:"signingTime"`
+	Thumbprint  string    `json:"thumbprint"`
+	// Valid is true when the image digest, the signer's signature and the
+	// certificate chain all check out
+	Valid bool `json:"valid"`
+	// Problem explains why Valid is false
+	Problem string `json:"problem,omitempty"`
+
+	Certificates []*x509.Certificate `json:"-"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version int
+	// SID names the signer's certificate by issuer and serial number or,
+	// in RFC 3161 tokens, by subject key identifier
+	SID                       asn1.RawValue
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type digestInfo struct {
+	DigestAlgorithm pkix.AlgorithmIdentifier
+	Digest          []byte
+}
+
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest digestInfo
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint digestInfo
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+// ReadSignature extracts the Authenticode signature of a PE file and
+// verifies the image digest, the signer's signature and its certificate
+// chain. Verification failures are reported in Signature.Valid and
+// Signature.Problem; the error is only set when no signature could be read.
+func ReadSignature(path string) (*Signature, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	layout, err := readHeaderLayout(f)
+	if err != nil {
+		return nil, err
+	}
+	if layout.certOffset == 0 || layout.certSize < 8 {
+		return nil, ErrNotSigned
+	}
+
+	blob := make([]byte, layout.certSize)
+	if _, err := f.ReadAt(blob, int64(layout.certOffset)); err != nil {
+		return nil, fmt.Errorf("failed to read certificate table: %w", err)
+	}
+	length := binary.LittleEndian.Uint32(blob[0:4])
+	certType := binary.LittleEndian.Uint16(blob[6:8])
+	if certType != certTypePKCSSignedData || length < 8 || length > layout.certSize {
+		return nil, fmt.Errorf("unsupported certificate type 0x%x", certType)
+	}
+
+	sd, err := parseSignedData(blob[8:length])
+	if err != nil {
+		return nil, err
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, errors.New("signature has no signer")
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificates: %w", err)
+	}
+
+	si := sd.SignerInfos[0]
+	signer := findSigner(certs, si.SID)
+	if signer == nil {
+		return nil, errors.New("signer certificate not found")
+	}
+
+	sig := &Signature{
+		Subject:      signer.Subject.CommonName,
+		Issuer:       signer.Issuer.CommonName,
+		NotBefore:    signer.NotBefore,
+		NotAfter:     signer.NotAfter,
+		Certificates: certs,
+	}
+	if len(signer.Subject.Organization) > 0 {
+		sig.Organization = signer.Subject.Organization[0]
+	}
+	if sig.Subject == "" {
+		sig.Subject = sig.Organization
+	}
+	thumb := sha1.Sum(signer.Raw)
+	sig.Thumbprint = hex.EncodeToString(thumb[:])
+	sig.SigningTime = timestamp(si, certs)
+
+	if err := verify(f, layout, sd, si, signer); err != nil {
+		sig.Problem = err.Error()
+		return sig, nil
+	}
+	// Timestamped signatures are checked at signing time, so they stay
+	// valid after the certificate expires
+	if err := verifyChain(signer, certs, sig.SigningTime, x509.ExtKeyUsageCodeSigning); err != nil {
+		sig.Problem = err.Error()
+		return sig, nil
+	}
+	sig.Valid = true
+	return sig, nil
+}
+
+// headerLayout holds the file offsets needed to compute the Authenticode digest
+type headerLayout struct {
+	checksumOffset int64
+	certDirOffset  int64
+	certOffset     uint32
+	certSize       uint32
+	fileSize       int64
+}
+
+// readHeaderLayout locates the checksum field and the security directory
+func readHeaderLayout(f *os.File) (*headerLayout, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var dos [64]byte
+	if _, err := f.ReadAt(dos[:], 0); err != nil || dos[0] != 'M' || dos[1] != 'Z' {
+		return nil, errors.New("not a PE file")
+	}
+	peOffset := int64(binary.LittleEndian.Uint32(dos[0x3c:]))
+
+	var sig [4]byte
+	if _, err := f.ReadAt(sig[:], peOffset); err != nil || !bytes.Equal(sig[:], []byte("PE\x00\x00")) {
+		return nil, errors.New("not a PE file")
+	}
+
+	optOffset := peOffset + 4 + 20
+	var magic [2]byte
+	if _, err := f.ReadAt(magic[:], optOffset); err != nil {
+		return nil, errors.New("truncated optional header")
+	}
+
+	var dataDirOffset int64
+	switch binary.LittleEndian.Uint16(magic[:]) {
+	case 0x10b:
+		dataDirOffset = optOffset + 96
+	case 0x20b:
+		dataDirOffset = optOffset + 112
+	default:
+		return nil, errors.New("unknown optional header format")
+	}
+
+	layout := &headerLayout{
+		checksumOffset: optOffset + 64,
+		certDirOffset:  dataDirOffset + securityDirIndex*8,
+		fileSize:       info.Size(),
+	}
+
+	var dir [8]byte
+	if _, err := f.ReadAt(dir[:], layout.certDirOffset); err != nil {
+		return nil, errors.New("truncated data directories")
+	}
+	layout.certOffset = binary.LittleEndian.Uint32(dir[0:4])
+	layout.certSize = binary.LittleEndian.Uint32(dir[4:8])
+	if int64(layout.certOffset)+int64(layout.certSize) > layout.fileSize {
+		return nil, errors.New("certificate table outside of file")
+	}
+	return layout, nil
+}
+
+// parseSignedData decodes the PKCS#7 SignedData wrapper of the signature
+func parseSignedData(der []byte) (*signedData, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("failed to parse signature: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("signature is not PKCS#7 signed data")
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("failed to parse signed data: %w", err)
+	}
+	return &sd, nil
+}
+
+// findSigner picks the certificate named by a signer info's SID
+func findSigner(certs []*x509.Certificate, sid asn1.RawValue) *x509.Certificate {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if len(c.SubjectKeyId) > 0 && bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+		return nil
+	}
+
+	var ias issuerAndSerial
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil || ias.SerialNumber == nil {
+		return nil
+	}
+	for _, c := range certs {
+		if c.SerialNumber.Cmp(ias.SerialNumber) == 0 && bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) {
+			return c
+		}
+	}
+	return nil
+}
+
+// verify checks the image digest and the signer's signature over it
+func verify(f *os.File, layout *headerLayout, sd *signedData, si signerInfo, signer *x509.Certificate) error {
+	if !sd.ContentInfo.ContentType.Equal(oidSpcIndirectData) {
+		return errors.New("signature does not cover a PE image")
+	}
+
+	// The signed content is SpcIndirectDataContent without its outer tag
+	var indirect asn1.RawValue
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &indirect); err != nil {
+		return fmt.Errorf("failed to parse signed content: %w", err)
+	}
+	var spc spcIndirectDataContent
+	if _, err := asn1.Unmarshal(indirect.FullBytes, &spc); err != nil {
+		return fmt.Errorf("failed to parse signed content: %w", err)
+	}
+
+	imageHash, err := hashFor(spc.MessageDigest.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	digest, err := imageDigest(f, layout, imageHash.New())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, spc.MessageDigest.Digest) {
+		return errors.New("file was modified after signing")
+	}
+
+	signerHash, err := hashFor(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	signed := indirect.Bytes
+	if len(si.AuthenticatedAttributes.FullBytes) > 0 {
+		if _, signed, err = signedAttributes(si, signerHash, indirect.Bytes); err != nil {
+			return err
+		}
+	}
+
+	return checkSignature(signer, si.DigestEncryptionAlgorithm.Algorithm, signerHash, signed, si.EncryptedDigest)
+}
+
+// signedAttributes checks that the signed attributes of a signer info
+// carry the digest of content, and returns them with the bytes the signer
+// signed
+func signedAttributes(si signerInfo, h crypto.Hash, content []byte) ([]attribute, []byte, error) {
+	if len(si.AuthenticatedAttributes.FullBytes) == 0 {
+		return nil, nil, errors.New("signer has no signed attributes")
+	}
+	attrs, err := parseAttributes(si.AuthenticatedAttributes.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	var messageDigest []byte
+	for _, a := range attrs {
+		if a.Type.Equal(oidMessageDigest) {
+			asn1.Unmarshal(a.Values.Bytes, &messageDigest)
+		}
+	}
+	if !bytes.Equal(digestOf(h, content), messageDigest) {
+		return nil, nil, errors.New("signed attributes do not match content")
+	}
+
+	// Attributes are signed as a SET, not with their implicit [0] tag
+	signed := append([]byte{0x31}, si.AuthenticatedAttributes.FullBytes[1:]...)
+	return attrs, signed, nil
+}
+
+// imageDigest hashes the PE file, skipping the checksum, the security
+// directory entry and the certificate table itself
+func imageDigest(f *os.File, layout *headerLayout, h hash.Hash) ([]byte, error) {
+	ranges := [][2]int64{
+		{0, layout.checksumOffset},
+		{layout.checksumOffset + 4, layout.certDirOffset},
+		{layout.certDirOffset + 8, int64(layout.certOffset)},
+		{int64(layout.certOffset) + int64(layout.certSize), layout.fileSize},
+	}
+	for _, r := range ranges {
+		if r[1] <= r[0] {
+			continue
+		}
+		if _, err := io.Copy(h, io.NewSectionReader(f, r[0], r[1]-r[0])); err != nil {
+			return nil, fmt.Errorf("failed to hash image: %w", err)
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// checkSignature verifies a signer's signature directly against its key.
+// x509.Certificate.CheckSignature refuses SHA-1, which older but still
+// valid Authenticode signatures use.
+func checkSignature(cert *x509.Certificate, algo asn1.ObjectIdentifier, h crypto.Hash, signed, signature []byte) error {
+	digest := digestOf(h, signed)
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if !oneOf(algo, oidRSAEncryption, oidSHA1WithRSA, oidSHA256WithRSA, oidSHA384WithRSA, oidSHA512WithRSA) {
+			return fmt.Errorf("unsupported signature algorithm %v", algo)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, h, digest, signature); err != nil {
+			return errors.New("signature does not match signer certificate")
+		}
+	case *ecdsa.PublicKey:
+		if !oneOf(algo, oidECPublicKey, oidECDSAWithSHA256, oidECDSAWithSHA384, oidECDSAWithSHA512) {
+			return fmt.Errorf("unsupported signature algorithm %v", algo)
+		}
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return errors.New("signature does not match signer certificate")
+		}
+	default:
+		return errors.New("unsupported signer key type")
+	}
+	return nil
+}
+
+// verifyChain builds a chain from cert to a trusted root for the given
+// usage, at time at or, when at is zero, now
+func verifyChain(cert *x509.Certificate, certs []*x509.Certificate, at time.Time, usage x509.ExtKeyUsage) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		if c != cert {
+			intermediates.AddCert(c)
+		}
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         trustedRoots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+		CurrentTime:   at,
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("untrusted certificate chain: %w", err)
+	}
+	return nil
+}
+
+// timestamp returns the signing time from the countersignature or RFC 3161
+// timestamp of a signature. A time is only returned once the timestamp
+// authority's signature over the signer's signature checks out and its
+// certificate chains to a trusted root, as anyone can write a timestamp.
+func timestamp(si signerInfo, certs []*x509.Certificate) time.Time {
+	if len(si.UnauthenticatedAttributes.Bytes) == 0 {
+		return time.Time{}
+	}
+	attrs, err := parseAttributes(si.UnauthenticatedAttributes.Bytes)
+	if err != nil {
+		return time.Time{}
+	}
+
+	for _, a := range attrs {
+		var at time.Time
+		var err error
+		switch {
+		case a.Type.Equal(oidCounterSignature):
+			at, err = counterSignatureTime(a.Values.Bytes, si, certs)
+		case a.Type.Equal(oidRFC3161Timestamp):
+			at, err = rfc3161Time(a.Values.Bytes, si, certs)
+		default:
+			continue
+		}
+		if err == nil {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// counterSignatureTime verifies a PKCS#9 countersignature, whose signer
+// info signs the digest of the signer's signature and the signing time
+func counterSignatureTime(der []byte, si signerInfo, certs []*x509.Certificate) (time.Time, error) {
+	var counter signerInfo
+	if _, err := asn1.Unmarshal(der, &counter); err != nil {
+		return time.Time{}, err
+	}
+	h, err := hashFor(counter.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return time.Time{}, err
+	}
+	attrs, signed, err := signedAttributes(counter, h, si.EncryptedDigest)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var at time.Time
+	for _, a := range attrs {
+		if a.Type.Equal(oidSigningTime) {
+			asn1.Unmarshal(a.Values.Bytes, &at)
+		}
+	}
+	if at.IsZero() {
+		return time.Time{}, errors.New("countersignature has no signing time")
+	}
+	return at, checkTimestampSigner(counter, certs, signed, at)
+}
+
+// rfc3161Time verifies an RFC 3161 timestamp token, whose TSTInfo holds
+// the digest of the signer's signature and the time
+func rfc3161Time(der []byte, si signerInfo, certs []*x509.Certificate) (time.Time, error) {
+	sd, err := parseSignedData(der)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !sd.ContentInfo.ContentType.Equal(oidTimestampTokenInfo) || len(sd.SignerInfos) == 0 {
+		return time.Time{}, errors.New("not a timestamp token")
+	}
+	var content []byte
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return time.Time{}, err
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(content, &info); err != nil {
+		return time.Time{}, err
+	}
+
+	imprintHash, err := hashFor(info.MessageImprint.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !bytes.Equal(digestOf(imprintHash, si.EncryptedDigest), info.MessageImprint.Digest) {
+		return time.Time{}, errors.New("timestamp is for another signature")
+	}
+
+	tsi := sd.SignerInfos[0]
+	h, err := hashFor(tsi.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return time.Time{}, err
+	}
+	_, signed, err := signedAttributes(tsi, h, content)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	tokenCerts := certs
+	if len(sd.Certificates.Bytes) > 0 {
+		own, err := x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return time.Time{}, err
+		}
+		tokenCerts = append(own, certs...)
+	}
+	return info.GenTime, checkTimestampSigner(tsi, tokenCerts, signed, info.GenTime)
+}
+
+// checkTimestampSigner verifies a timestamp authority's signature over its
+// signed attributes and its certificate chain at the time it vouches for
+func checkTimestampSigner(tsi signerInfo, certs []*x509.Certificate, signed []byte, at time.Time) error {
+	tsa := findSigner(certs, tsi.SID)
+	if tsa == nil {
+		return errors.New("timestamp certificate not found")
+	}
+	h, err := hashFor(tsi.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	if err := checkSignature(tsa, tsi.DigestEncryptionAlgorithm.Algorithm, h, signed, tsi.EncryptedDigest); err != nil {
+		return err
+	}
+	return verifyChain(tsa, certs, at, x509.ExtKeyUsageTimeStamping)
+}
+
+// digestOf hashes data with h
+func digestOf(h crypto.Hash, data []byte) []byte {
+	hasher := h.New()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+// parseAttributes decodes the contents of a PKCS#9 attribute set
+func parseAttributes(der []byte) ([]attribute, error) {
+	var attrs []attribute
+	for len(der) > 0 {
+		var a attribute
+		rest, err := asn1.Unmarshal(der, &a)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse attributes: %w", err)
+		}
+		attrs = append(attrs, a)
+		der = rest
+	}
+	return attrs, nil
+}
+
+// hashFor maps a digest algorithm OID to a hash function
+func hashFor(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidDigestSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidDigestSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidDigestSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidDigestSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported digest algorithm %v", oid)
+}
+
+// oneOf reports whether oid equals any of the candidates
+func oneOf(oid asn1.ObjectIdentifier, candidates ...asn1.ObjectIdentifier) bool {
+	for _, c := range candidates {
+		if oid.Equal(c) {
+			return true
+		}
+	}
+	return false
+}
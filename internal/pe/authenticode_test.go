@@ -0,0 +1,478 @@
+package pe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oidContentType         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSpcPEImageData      = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 15}
+	oidTimestampTestPolicy = asn1.ObjectIdentifier{1, 2, 3, 4}
+)
+
+// signedAt is when the test files were signed, while the expired signer
+// certificate was still valid
+var signedAt = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testCert is a certificate with its key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// testPKI holds a trusted root and the certificates it issued
+type testPKI struct {
+	root    testCert
+	signer  testCert
+	expired testCert
+	tsa     testCert
+}
+
+var serial int64
+
+// issue creates a certificate from template, signed by parent or, without
+// one, by itself
+func issue(t *testing.T, template *x509.Certificate, parent *testCert) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	template.SerialNumber = big.NewInt(serial)
+	template.SubjectKeyId = []byte{byte(serial), 0x5e, 0x1f}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{cert: cert, key: key}
+}
+
+// newTestPKI makes a root, which the test trusts, with a code signing
+// certificate, an expired one and a timestamp authority
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	from, until := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var p testPKI
+	p.root = issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		NotBefore:             from,
+		NotAfter:              until,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	p.signer = issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Contoso Code Signing", Organization: []string{"Contoso Ltd."}},
+		NotBefore:   from,
+		NotAfter:    until,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, &p.root)
+	p.expired = issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Contoso 2020"},
+		NotBefore:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:    time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, &p.root)
+	p.tsa = issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Test Timestamps"},
+		NotBefore:   from,
+		NotAfter:    until,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, &p.root)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(p.root.cert)
+	old := trustedRoots
+	trustedRoots = roots
+	t.Cleanup(func() { trustedRoots = old })
+	return &p
+}
+
+// untrustedTSA makes a self-signed timestamp authority, as someone forging
+// a timestamp would
+func untrustedTSA(t *testing.T) testCert {
+	t.Helper()
+	return issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Forged Timestamps"},
+		NotBefore:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:    time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, nil)
+}
+
+// mustMarshal DER-encodes v
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	der, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// testAttribute encodes a PKCS#9 attribute with a single value
+func testAttribute(t *testing.T, oid asn1.ObjectIdentifier, value any) []byte {
+	t.Helper()
+	return mustMarshal(t, attribute{
+		Type:   oid,
+		Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshal(t, value)},
+	})
+}
+
+// attributeSet wraps encoded attributes in the implicit tag of signed
+// ([0]) or unsigned ([1]) attributes
+func attributeSet(tag int, attrs ...[]byte) asn1.RawValue {
+	var b []byte
+	for _, a := range attrs {
+		b = append(b, a...)
+	}
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: true, Bytes: b}
+}
+
+// signerSID names a certificate by issuer and serial number
+func signerSID(t *testing.T, c *x509.Certificate) asn1.RawValue {
+	return asn1.RawValue{FullBytes: mustMarshal(t, issuerAndSerial{
+		Issuer:       asn1.RawValue{FullBytes: c.RawIssuer},
+		SerialNumber: c.SerialNumber,
+	})}
+}
+
+// newSignerInfo signs the given signed attributes with c
+func newSignerInfo(t *testing.T, c testCert, sid asn1.RawValue, attrs ...[]byte) signerInfo {
+	t.Helper()
+	set := attributeSet(0, attrs...)
+	signed := append([]byte{0x31}, mustMarshal(t, set)[1:]...)
+	digest := sha256.Sum256(signed)
+	sig, err := ecdsa.SignASN1(rand.Reader, c.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signerInfo{
+		Version:                   1,
+		SID:                       sid,
+		DigestAlgorithm:           pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256},
+		AuthenticatedAttributes:   set,
+		DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+		EncryptedDigest:           sig,
+	}
+}
+
+// wrapSignedData encodes SignedData in its ContentInfo
+func wrapSignedData(t *testing.T, sd signedData) []byte {
+	return mustMarshal(t, contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: mustMarshal(t, sd)},
+	})
+}
+
+// certificateSet encodes certificates as the [0] field of SignedData
+func certificateSet(certs ...*x509.Certificate) asn1.RawValue {
+	var b []byte
+	for _, c := range certs {
+		b = append(b, c.Raw...)
+	}
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b}
+}
+
+// counterSignature makes a PKCS#9 countersignature by tsa over a signature
+func counterSignature(t *testing.T, tsa testCert, at time.Time, signature []byte) []byte {
+	digest := sha256.Sum256(signature)
+	counter := newSignerInfo(t, tsa, signerSID(t, tsa.cert),
+		testAttribute(t, oidContentType, oidData),
+		testAttribute(t, oidSigningTime, at),
+		testAttribute(t, oidMessageDigest, digest[:]),
+	)
+	return testAttribute(t, oidCounterSignature, counter)
+}
+
+// rfc3161Token makes an RFC 3161 timestamp token by tsa over a signature,
+// naming the authority by subject key identifier
+func rfc3161Token(t *testing.T, tsa testCert, at time.Time, signature []byte) []byte {
+	imprint := sha256.Sum256(signature)
+	content := mustMarshal(t, tstInfo{
+		Version:        1,
+		Policy:         oidTimestampTestPolicy,
+		MessageImprint: digestInfo{DigestAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256}, Digest: imprint[:]},
+		SerialNumber:   big.NewInt(1),
+		GenTime:        at,
+	})
+	contentDigest := sha256.Sum256(content)
+	sid := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: tsa.cert.SubjectKeyId}
+	tsi := newSignerInfo(t, tsa, sid,
+		testAttribute(t, oidContentType, oidTimestampTokenInfo),
+		testAttribute(t, oidMessageDigest, contentDigest[:]),
+	)
+
+	token := wrapSignedData(t, signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidDigestSHA256}},
+		ContentInfo: contentInfo{
+			ContentType: oidTimestampTokenInfo,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: mustMarshal(t, content)},
+		},
+		Certificates: certificateSet(tsa.cert),
+		SignerInfos:  []signerInfo{tsi},
+	})
+	return mustMarshal(t, attribute{
+		Type:   oidRFC3161Timestamp,
+		Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: token},
+	})
+}
+
+// testImageDigest hashes a PE file the way Authenticode does, before a
+// certificate table is appended
+func testImageDigest(data []byte) []byte {
+	peOffset := int(binary.LittleEndian.Uint32(data[0x3c:]))
+	checksum := peOffset + 24 + 64
+	certDir := peOffset + 24 + 112 + securityDirIndex*8
+
+	h := sha256.New()
+	h.Write(data[:checksum])
+	h.Write(data[checksum+4 : certDir])
+	h.Write(data[certDir+8:])
+	return h.Sum(nil)
+}
+
+// signPE signs a PE file built by buildPE with signer. The timestamp
+// function, if any, returns the unsigned attribute that timestamps the
+// signature.
+func signPE(t *testing.T, data []byte, signer testCert, chain []*x509.Certificate, timestamp func(signature []byte) []byte) []byte {
+	t.Helper()
+	for len(data)%8 != 0 {
+		data = append(data, 0)
+	}
+
+	spc := mustMarshal(t, spcIndirectDataContent{
+		Data: asn1.RawValue{FullBytes: mustMarshal(t, struct{ Type asn1.ObjectIdentifier }{oidSpcPEImageData})},
+		MessageDigest: digestInfo{
+			DigestAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256},
+			Digest:          testImageDigest(data),
+		},
+	})
+	var indirect asn1.RawValue
+	if _, err := asn1.Unmarshal(spc, &indirect); err != nil {
+		t.Fatal(err)
+	}
+	contentDigest := sha256.Sum256(indirect.Bytes)
+	si := newSignerInfo(t, signer, signerSID(t, signer.cert),
+		testAttribute(t, oidContentType, oidSpcIndirectData),
+		testAttribute(t, oidMessageDigest, contentDigest[:]),
+	)
+	if timestamp != nil {
+		si.UnauthenticatedAttributes = attributeSet(1, timestamp(si.EncryptedDigest))
+	}
+
+	der := wrapSignedData(t, signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidDigestSHA256}},
+		ContentInfo: contentInfo{
+			ContentType: oidSpcIndirectData,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: spc},
+		},
+		Certificates: certificateSet(append([]*x509.Certificate{signer.cert}, chain...)...),
+		SignerInfos:  []signerInfo{si},
+	})
+
+	table := make([]byte, 8, 8+len(der)+8)
+	table = append(table, der...)
+	for len(table)%8 != 0 {
+		table = append(table, 0)
+	}
+	binary.LittleEndian.PutUint32(table[0:], uint32(len(table)))
+	binary.LittleEndian.PutUint16(table[4:], 0x0200)
+	binary.LittleEndian.PutUint16(table[6:], certTypePKCSSignedData)
+
+	peOffset := int(binary.LittleEndian.Uint32(data[0x3c:]))
+	certDir := peOffset + 24 + 112 + securityDirIndex*8
+	binary.LittleEndian.PutUint32(data[certDir:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[certDir+4:], uint32(len(table)))
+	return append(data, table...)
+}
+
+// testImage is an unsigned PE file with some section data
+func testImage() []byte {
+	return buildPE(16, []byte("section data that the signature covers"))
+}
+
+func TestReadSignatureUnsigned(t *testing.T) {
+	if _, err := ReadSignature(writeTemp(t, "app.exe", testImage())); !errors.Is(err, ErrNotSigned) {
+		t.Errorf("ReadSignature error = %v, want ErrNotSigned", err)
+	}
+}
+
+func TestReadSignatureSigned(t *testing.T) {
+	p := newTestPKI(t)
+	path := writeTemp(t, "app.exe", signPE(t, testImage(), p.signer, nil, nil))
+
+	sig, err := ReadSignature(path)
+	if err != nil {
+		t.Fatalf("ReadSignature: %v", err)
+	}
+	if !sig.Valid {
+		t.Fatalf("signature is not valid: %s", sig.Problem)
+	}
+	if sig.Subject != "Contoso Code Signing" || sig.Organization != "Contoso Ltd." || sig.Issuer != "Test Root" {
+		t.Errorf("signer = %q, %q, issued by %q", sig.Subject, sig.Organization, sig.Issuer)
+	}
+	if !sig.SigningTime.IsZero() {
+		t.Errorf("SigningTime = %v, want none without a timestamp", sig.SigningTime)
+	}
+	if len(sig.Thumbprint) != 40 {
+		t.Errorf("Thumbprint = %q, want a SHA-1 hex digest", sig.Thumbprint)
+	}
+}
+
+func TestReadSignatureTampered(t *testing.T) {
+	p := newTestPKI(t)
+	data := signPE(t, testImage(), p.signer, nil, nil)
+	data[testSectionOffset] ^= 0xff
+
+	sig, err := ReadSignature(writeTemp(t, "app.exe", data))
+	if err != nil {
+		t.Fatalf("ReadSignature: %v", err)
+	}
+	if sig.Valid || sig.Problem != "file was modified after signing" {
+		t.Errorf("Valid = %v, Problem = %q; want a modified file", sig.Valid, sig.Problem)
+	}
+}
+
+func TestReadSignatureUntrustedChain(t *testing.T) {
+	newTestPKI(t)
+	other := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Self Signed"},
+		NotBefore:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:    time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, nil)
+
+	sig, err := ReadSignature(writeTemp(t, "app.exe", signPE(t, testImage(), other, nil, nil)))
+	if err != nil {
+		t.Fatalf("ReadSignature: %v", err)
+	}
+	if sig.Valid || !strings.HasPrefix(sig.Problem, "untrusted certificate chain") {
+		t.Errorf("Valid = %v, Problem = %q; want an untrusted chain", sig.Valid, sig.Problem)
+	}
+}
+
+func TestReadSignatureTimestamp(t *testing.T) {
+	p := newTestPKI(t)
+	forged := untrustedTSA(t)
+
+	tests := []struct {
+		name      string
+		timestamp func(signature []byte) []byte
+		// valid is whether the expired certificate is accepted, which
+		// takes a timestamp that checks out
+		valid bool
+	}{
+		{"none", nil, false},
+		{"countersignature", func(s []byte) []byte { return counterSignature(t, p.tsa, signedAt, s) }, true},
+		{"RFC 3161", func(s []byte) []byte { return rfc3161Token(t, p.tsa, signedAt, s) }, true},
+		{"forged countersignature", func(s []byte) []byte { return counterSignature(t, forged, signedAt, s) }, false},
+		{"forged RFC 3161", func(s []byte) []byte { return rfc3161Token(t, forged, signedAt, s) }, false},
+		{"countersignature of another signature", func(s []byte) []byte {
+			return counterSignature(t, p.tsa, signedAt, []byte("another signature"))
+		}, false},
+		{"RFC 3161 of another signature", func(s []byte) []byte {
+			return rfc3161Token(t, p.tsa, signedAt, []byte("another signature"))
+		}, false},
+		{"countersignature by a code signing certificate", func(s []byte) []byte {
+			return counterSignature(t, p.signer, signedAt, s)
+		}, false},
+		{"tampered countersignature", func(s []byte) []byte {
+			attr := counterSignature(t, p.tsa, signedAt, s)
+			// Move the signing time forward by a year after signing
+			i := strings.Index(string(attr), "200601120000Z")
+			copy(attr[i:], "210601120000Z")
+			return attr
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Countersigners' certificates travel with the signer's
+			data := signPE(t, testImage(), p.expired, []*x509.Certificate{p.tsa.cert, forged.cert}, tt.timestamp)
+			sig, err := ReadSignature(writeTemp(t, "app.exe", data))
+			if err != nil {
+				t.Fatalf("ReadSignature: %v", err)
+			}
+			if sig.Valid != tt.valid {
+				t.Errorf("Valid = %v (%s), want %v", sig.Valid, sig.Problem, tt.valid)
+			}
+			if tt.valid && !sig.SigningTime.Equal(signedAt) {
+				t.Errorf("SigningTime = %v, want %v", sig.SigningTime, signedAt)
+			}
+			if !tt.valid && !sig.SigningTime.IsZero() {
+				t.Errorf("SigningTime = %v, want none for an unverified timestamp", sig.SigningTime)
+			}
+		})
+	}
+}
+
+func TestReadSignatureMalformed(t *testing.T) {
+	p := newTestPKI(t)
+	signed := signPE(t, testImage(), p.signer, nil, nil)
+	peOffset := int(binary.LittleEndian.Uint32(signed[0x3c:]))
+	certDir := peOffset + 24 + 112 + securityDirIndex*8
+	tableOffset := int(binary.LittleEndian.Uint32(signed[certDir:]))
+
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+	}{
+		{"table past the end", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[certDir+4:], uint32(len(data)))
+			return data
+		}},
+		{"unsupported certificate type", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[tableOffset+6:], 1)
+			return data
+		}},
+		{"length past the table", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[tableOffset:], 0xffff)
+			return data
+		}},
+		{"garbage signature", func(data []byte) []byte {
+			for i := tableOffset + 8; i < len(data); i++ {
+				data[i] = 0xaa
+			}
+			return data
+		}},
+		{"not a PE file", func(data []byte) []byte { return []byte("plain text") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.modify(append([]byte(nil), signed...))
+			if _, err := ReadSignature(writeTemp(t, "app.exe", data)); err == nil {
+				t.Error("ReadSignature succeeded, want an error")
+			}
+		})
+	}
+}
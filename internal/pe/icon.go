@@ -0,0 +1,258 @@
+package pe
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// iconEntry is one image listed in an icon directory
+type iconEntry struct {
+	width    int
+	height   int
+	bitCount int
+	size     uint32
+	// offset into an .ico file, or the RT_ICON resource ID in a PE file
+	ref uint32
+}
+
+// ExtractIconPNG returns the largest image of an icon as PNG. The path may
+// be an .ico file or a PE file; for PE files a non-negative index selects
+// the n-th icon group and a negative index selects the group with that
+// resource ID, as in the "path,index" form of registry icon locations.
+func ExtractIconPNG(path string, index int) ([]byte, error) {
+	if strings.EqualFold(filepath.Ext(path), ".ico") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return iconFileToPNG(data)
+	}
+
+	f, err := pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res, err := readResources(f)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := pickIconGroup(res, index)
+	if err != nil {
+		return nil, err
+	}
+	groupData, err := res.data(group)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseIconDir(groupData, 14)
+	if err != nil {
+		return nil, err
+	}
+
+	best := bestIcon(entries)
+	for _, l := range res.find(rtIcon) {
+		if l.Name.Name == "" && l.Name.ID == best.ref {
+			data, err := res.data(l)
+			if err != nil {
+				return nil, err
+			}
+			return imageToPNG(data)
+		}
+	}
+	return nil, fmt.Errorf("icon image %d missing", best.ref)
+}
+
+// pickIconGroup resolves a registry-style icon index to an RT_GROUP_ICON leaf
+func pickIconGroup(res *resources, index int) (resourceLeaf, error) {
+	groups := res.find(rtGroupIcon)
+	if len(groups) == 0 {
+		return resourceLeaf{}, ErrNoResource
+	}
+	if index < 0 {
+		for _, g := range groups {
+			if g.Name.Name == "" && g.Name.ID == uint32(-index) {
+				return g, nil
+			}
+		}
+		return resourceLeaf{}, ErrNoResource
+	}
+	if index >= len(groups) {
+		return resourceLeaf{}, ErrNoResource
+	}
+	return groups[index], nil
+}
+
+// iconFileToPNG converts the largest image of an .ico file to PNG
+func iconFileToPNG(data []byte) ([]byte, error) {
+	entries, err := parseIconDir(data, 16)
+	if err != nil {
+		return nil, err
+	}
+	best := bestIcon(entries)
+	end := uint64(best.ref) + uint64(best.size)
+	if end > uint64(len(data)) {
+		return nil, errors.New("icon image out of range")
+	}
+	return imageToPNG(data[best.ref:end])
+}
+
+// parseIconDir reads an ICONDIR (16-byte entries) or GRPICONDIR (14-byte entries)
+func parseIconDir(data []byte, entrySize int) ([]iconEntry, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint16(data[2:]) != 1 {
+		return nil, errors.New("not an icon directory")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	if count == 0 || 6+count*entrySize > len(data) {
+		return nil, errors.New("icon directory is empty or truncated")
+	}
+
+	entries := make([]iconEntry, 0, count)
+	for i := 0; i < count; i++ {
+		raw := data[6+i*entrySize:]
+		e := iconEntry{
+			width:    int(raw[0]),
+			height:   int(raw[1]),
+			bitCount: int(binary.LittleEndian.Uint16(raw[6:])),
+			size:     binary.LittleEndian.Uint32(raw[8:]),
+		}
+		// A stored size of 0 means 256 pixels
+		if e.width == 0 {
+			e.width = 256
+		}
+		if e.height == 0 {
+			e.height = 256
+		}
+		if entrySize == 14 {
+			e.ref = uint32(binary.LittleEndian.Uint16(raw[12:]))
+		} else {
+			e.ref = binary.LittleEndian.Uint32(raw[12:])
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// bestIcon prefers the largest image, then the deepest color
+func bestIcon(entries []iconEntry) iconEntry {
+	best := entries[0]
+	for _, e := range entries[1:] {
+		if e.width > best.width || (e.width == best.width && e.bitCount > best.bitCount) {
+			best = e
+		}
+	}
+	return best
+}
+
+// imageToPNG passes PNG images through and converts DIB images to PNG
+func imageToPNG(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, pngSignature) {
+		return data, nil
+	}
+	img, err := decodeIconDIB(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode icon: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeIconDIB decodes an icon bitmap: a BITMAPINFOHEADER, an optional
+// palette, the color rows and a 1-bit transparency mask, both bottom-up
+func decodeIconDIB(data []byte) (*image.NRGBA, error) {
+	if len(data) < 40 {
+		return nil, errors.New("icon bitmap truncated")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[0:]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:])))
+	// The height covers both the color rows and the mask
+	height := int(int32(binary.LittleEndian.Uint32(data[8:]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:]))
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:]))
+
+	if width <= 0 || height <= 0 || width > 1024 || height > 1024 || headerSize < 40 || headerSize > len(data) {
+		return nil, errors.New("invalid icon bitmap header")
+	}
+
+	var palette []color.NRGBA
+	if bitCount <= 8 {
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+		start := headerSize
+		if start+colorsUsed*4 > len(data) {
+			return nil, errors.New("icon palette truncated")
+		}
+		for i := 0; i < colorsUsed; i++ {
+			p := data[start+i*4:]
+			palette = append(palette, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xff})
+		}
+	}
+
+	pixels := headerSize + len(palette)*4
+	stride := ((width*bitCount + 31) / 32) * 4
+	maskStart := pixels + stride*height
+	maskStride := ((width + 31) / 32) * 4
+	if maskStart > len(data) {
+		return nil, errors.New("icon bitmap truncated")
+	}
+	hasMask := maskStart+maskStride*height <= len(data)
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		row := data[pixels+(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				if c.A != 0 {
+					hasAlpha = true
+				}
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xff}
+			case 8, 4, 1:
+				bit := x * bitCount
+				idx := int(row[bit/8]>>(8-bitCount-bit%8)) & (1<<bitCount - 1)
+				if idx < len(palette) {
+					c = palette[idx]
+				}
+			default:
+				return nil, fmt.Errorf("unsupported icon bit depth %d", bitCount)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// Without an alpha channel, transparency comes from the AND mask
+	if !hasAlpha {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				c.A = 0xff
+				if hasMask && data[maskStart+(height-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+					c.A = 0
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return img, nil
+}
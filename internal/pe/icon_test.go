@@ -0,0 +1,230 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// testPNG encodes a square image of the given size
+func testPNG(t *testing.T, size int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testDIB makes a 2x2 icon bitmap of the given depth: 32-bit with alpha,
+// or 24-bit with the top-left pixel masked out
+func testDIB(bitCount int) []byte {
+	header := make([]byte, 40)
+	binary.LittleEndian.PutUint32(header[0:], 40)
+	binary.LittleEndian.PutUint32(header[4:], 2)
+	binary.LittleEndian.PutUint32(header[8:], 4)
+	binary.LittleEndian.PutUint16(header[12:], 1)
+	binary.LittleEndian.PutUint16(header[14:], uint16(bitCount))
+
+	stride := ((2*bitCount + 31) / 32) * 4
+	pixels := make([]byte, stride*2)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			p := pixels[y*stride+x*bitCount/8:]
+			// Blue, green, red and, for 32 bits, alpha
+			p[0], p[1], p[2] = 0x30, 0x20, 0x10
+			if bitCount == 32 {
+				p[3] = 0x80
+			}
+		}
+	}
+	// Rows are bottom-up, so the top row's mask comes second
+	mask := make([]byte, 8)
+	mask[4] = 0x80
+
+	data := append(header, pixels...)
+	return append(data, mask...)
+}
+
+// buildIconFile makes an .ico file of the given 2x2 images and their
+// bit depths
+func buildIconFile(images [][]byte, depths []int) []byte {
+	data := make([]byte, 6+16*len(images))
+	binary.LittleEndian.PutUint16(data[2:], 1)
+	binary.LittleEndian.PutUint16(data[4:], uint16(len(images)))
+	for i, img := range images {
+		e := data[6+i*16:]
+		e[0], e[1] = 2, 2
+		binary.LittleEndian.PutUint16(e[6:], uint16(depths[i]))
+		binary.LittleEndian.PutUint32(e[8:], uint32(len(img)))
+		binary.LittleEndian.PutUint32(e[12:], uint32(len(data)))
+		data = append(data, img...)
+	}
+	return data
+}
+
+// buildIconGroup makes an RT_GROUP_ICON listing RT_ICON resources 1..n
+// with the given sizes
+func buildIconGroup(images [][]byte, sizes []int) []byte {
+	data := make([]byte, 6+14*len(images))
+	binary.LittleEndian.PutUint16(data[2:], 1)
+	binary.LittleEndian.PutUint16(data[4:], uint16(len(images)))
+	for i, img := range images {
+		e := data[6+i*14:]
+		e[0], e[1] = byte(sizes[i]), byte(sizes[i])
+		binary.LittleEndian.PutUint16(e[6:], 32)
+		binary.LittleEndian.PutUint32(e[8:], uint32(len(img)))
+		binary.LittleEndian.PutUint16(e[12:], uint16(i+1))
+	}
+	return data
+}
+
+// decodePNG decodes PNG data, failing the test if it is not an image
+func decodePNG(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	return img
+}
+
+func TestExtractIconPNGFromPE(t *testing.T) {
+	small, large := testPNG(t, 16), testPNG(t, 48)
+	images := [][]byte{small, large}
+	rsrc := buildResources(map[uint32][][]byte{
+		rtIcon:      images,
+		rtGroupIcon: {buildIconGroup(images, []int{16, 48})},
+	})
+	path := writeTemp(t, "app.exe", buildPE(16, rsrc))
+
+	data, err := ExtractIconPNG(path, 0)
+	if err != nil {
+		t.Fatalf("ExtractIconPNG: %v", err)
+	}
+	if !bytes.Equal(data, large) {
+		t.Errorf("ExtractIconPNG returned %d bytes, want the 48-pixel image", len(data))
+	}
+
+	// Negative indexes select the group by resource ID
+	if _, err := ExtractIconPNG(path, -1); err != nil {
+		t.Errorf("ExtractIconPNG(-1): %v", err)
+	}
+	for _, index := range []int{1, -2} {
+		if _, err := ExtractIconPNG(path, index); err == nil {
+			t.Errorf("ExtractIconPNG(%d) succeeded, want an error", index)
+		}
+	}
+}
+
+func TestExtractIconPNGMissingImage(t *testing.T) {
+	images := [][]byte{testPNG(t, 16)}
+	group := buildIconGroup(images, []int{16})
+	// Point the group at an RT_ICON that does not exist
+	binary.LittleEndian.PutUint16(group[6+12:], 9)
+	rsrc := buildResources(map[uint32][][]byte{rtIcon: images, rtGroupIcon: {group}})
+
+	if _, err := ExtractIconPNG(writeTemp(t, "app.exe", buildPE(16, rsrc)), 0); err == nil {
+		t.Error("ExtractIconPNG succeeded, want an error")
+	}
+}
+
+func TestExtractIconPNGFromIconFile(t *testing.T) {
+	path := writeTemp(t, "app.ico", buildIconFile([][]byte{testDIB(24), testDIB(32)}, []int{24, 32}))
+
+	data, err := ExtractIconPNG(path, 0)
+	if err != nil {
+		t.Fatalf("ExtractIconPNG: %v", err)
+	}
+	img := decodePNG(t, data)
+	if got := img.Bounds().Size(); got != image.Pt(2, 2) {
+		t.Fatalf("icon size = %v, want 2x2", got)
+	}
+	// The 32-bit image wins and keeps its own alpha
+	if got := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA); got != (color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80}) {
+		t.Errorf("pixel = %+v, want the 32-bit color with alpha 0x80", got)
+	}
+}
+
+func TestDecodeIconDIBMask(t *testing.T) {
+	img, err := decodeIconDIB(testDIB(24))
+	if err != nil {
+		t.Fatalf("decodeIconDIB: %v", err)
+	}
+	if got := img.NRGBAAt(0, 0).A; got != 0 {
+		t.Errorf("masked pixel alpha = %d, want 0", got)
+	}
+	if got := img.NRGBAAt(1, 1); got != (color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}) {
+		t.Errorf("pixel = %+v, want opaque", got)
+	}
+}
+
+func TestIconFileMalformed(t *testing.T) {
+	valid := buildIconFile([][]byte{testDIB(32)}, []int{32})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not an icon", []byte("MZ\x00\x00\x00\x00")},
+		{"no images", buildIconFile(nil, nil)},
+		{"directory truncated", valid[:10]},
+		{"image truncated", valid[:len(valid)-30]},
+		{"image past the end", func() []byte {
+			data := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint32(data[6+8:], 0xffffffff)
+			binary.LittleEndian.PutUint32(data[6+12:], 0xffffffff)
+			return data
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := iconFileToPNG(tt.data); err == nil {
+				t.Error("iconFileToPNG succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestDecodeIconDIBMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+	}{
+		{"header truncated", func(data []byte) []byte { return data[:20] }},
+		{"pixels truncated", func(data []byte) []byte { return data[:44] }},
+		{"oversized width", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[4:], 1<<20)
+			return data
+		}},
+		{"negative height", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[8:], 0xfffffffc)
+			return data
+		}},
+		{"header size past the end", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[0:], 0xffff)
+			return data
+		}},
+		{"palette past the end", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[14:], 8)
+			return data[:60]
+		}},
+		{"unsupported depth", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[14:], 16)
+			return data
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeIconDIB(tt.modify(testDIB(32))); err == nil {
+				t.Error("decodeIconDIB succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package pe
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Resource types used by Enodia
+const (
+	rtIcon      = 3
+	rtGroupIcon = 14
+	rtVersion   = 16
+)
+
+const resourceDirIndex = 2
+
+// ErrNoResource is returned when a PE file lacks the requested resource
+var ErrNoResource = errors.New("resource not found")
+
+// resourceID names a resource directory entry by number or by string
+type resourceID struct {
+	ID   uint32
+	Name string
+}
+
+// resourceLeaf is one type/name/language entry of the resource tree
+type resourceLeaf struct {
+	Type resourceID
+	Name resourceID
+	Lang uint32
+	rva  uint32
+	size uint32
+}
+
+// resources gives access to the resource tree of a PE file
+type resources struct {
+	file   *pe.File
+	dir    []byte
+	leaves []resourceLeaf
+}
+
+// readResources walks the three-level resource directory of a PE file
+func readResources(f *pe.File) (*resources, error) {
+	// Headers may declare more directories than the 16 debug/pe keeps
+	var dirs []pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dirs = oh.DataDirectory[:min(int(oh.NumberOfRvaAndSizes), len(oh.DataDirectory))]
+	case *pe.OptionalHeader64:
+		dirs = oh.DataDirectory[:min(int(oh.NumberOfRvaAndSizes), len(oh.DataDirectory))]
+	}
+	if len(dirs) <= resourceDirIndex || dirs[resourceDirIndex].Size == 0 {
+		return nil, ErrNoResource
+	}
+
+	r := &resources{file: f}
+	dir, err := r.read(dirs[resourceDirIndex].VirtualAddress, dirs[resourceDirIndex].Size)
+	if err != nil {
+		return nil, err
+	}
+	r.dir = dir
+
+	types, err := r.entries(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range types {
+		if !t.isDir {
+			continue
+		}
+		names, err := r.entries(t.offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if !n.isDir {
+				continue
+			}
+			langs, err := r.entries(n.offset)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range langs {
+				if l.isDir || int(l.offset)+16 > len(r.dir) {
+					continue
+				}
+				r.leaves = append(r.leaves, resourceLeaf{
+					Type: t.id,
+					Name: n.id,
+					Lang: l.id.ID,
+					rva:  binary.LittleEndian.Uint32(r.dir[l.offset:]),
+					size: binary.LittleEndian.Uint32(r.dir[l.offset+4:]),
+				})
+			}
+		}
+	}
+	return r, nil
+}
+
+// dirEntry is a decoded IMAGE_RESOURCE_DIRECTORY_ENTRY
+type dirEntry struct {
+	id     resourceID
+	offset uint32
+	isDir  bool
+}
+
+// entries decodes the entries of the resource directory at offset
+func (r *resources) entries(offset uint32) ([]dirEntry, error) {
+	if int(offset)+16 > len(r.dir) {
+		return nil, errors.New("resource directory out of range")
+	}
+	named := binary.LittleEndian.Uint16(r.dir[offset+12:])
+	ids := binary.LittleEndian.Uint16(r.dir[offset+14:])
+
+	count := int(named) + int(ids)
+	start := int(offset) + 16
+	if start+count*8 > len(r.dir) {
+		return nil, errors.New("resource directory out of range")
+	}
+
+	result := make([]dirEntry, 0, count)
+	for i := 0; i < count; i++ {
+		raw := r.dir[start+i*8:]
+		nameField := binary.LittleEndian.Uint32(raw)
+		dataField := binary.LittleEndian.Uint32(raw[4:])
+
+		e := dirEntry{
+			offset: dataField &^ 0x80000000,
+			isDir:  dataField&0x80000000 != 0,
+		}
+		if nameField&0x80000000 != 0 {
+			e.id.Name = r.stringAt(nameField &^ 0x80000000)
+		} else {
+			e.id.ID = nameField
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// stringAt reads a length-prefixed UTF-16 resource name
+func (r *resources) stringAt(offset uint32) string {
+	if int(offset)+2 > len(r.dir) {
+		return ""
+	}
+	n := int(binary.LittleEndian.Uint16(r.dir[offset:]))
+	start := int(offset) + 2
+	if start+n*2 > len(r.dir) {
+		return ""
+	}
+	return decodeUTF16(r.dir[start : start+n*2])
+}
+
+// find returns the leaves of a resource type, in directory order
+func (r *resources) find(typ uint32) []resourceLeaf {
+	var result []resourceLeaf
+	for _, l := range r.leaves {
+		if l.Type.Name == "" && l.Type.ID == typ {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// data returns the raw bytes of a resource leaf
+func (r *resources) data(l resourceLeaf) ([]byte, error) {
+	return r.read(l.rva, l.size)
+}
+
+// read copies size bytes starting at a relative virtual address
+func (r *resources) read(rva, size uint32) ([]byte, error) {
+	for _, s := range r.file.Sections {
+		end := uint64(s.VirtualAddress) + uint64(max(s.VirtualSize, s.Size))
+		if rva < s.VirtualAddress || uint64(rva) >= end {
+			continue
+		}
+		if uint64(rva)+uint64(size) > end || size > 64<<20 {
+			return nil, fmt.Errorf("resource at 0x%x out of range", rva)
+		}
+		buf := make([]byte, size)
+		off := int64(rva - s.VirtualAddress)
+		// Bytes past the raw data on disk are zero in memory
+		if avail := int64(s.Size) - off; avail > 0 {
+			n := min(int64(size), avail)
+			if _, err := s.ReadAt(buf[:n], off); err != nil {
+				return nil, fmt.Errorf("failed to read resource: %w", err)
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("resource at 0x%x not in any section", rva)
+}
+
+// decodeUTF16 converts little-endian UTF-16 bytes to a string
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	for len(u) > 0 && u[len(u)-1] == 0 {
+		u = u[:len(u)-1]
+	}
+	return string(utf16.Decode(u))
+}
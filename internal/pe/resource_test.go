@@ -0,0 +1,249 @@
+package pe
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"unicode/utf16"
+)
+
+const (
+	testSectionRVA    = 0x1000
+	testSectionOffset = 0x200
+)
+
+// buildResources lays out a resource tree of the given types, each a list
+// of resources numbered from 1, for a section at testSectionRVA
+func buildResources(types map[uint32][][]byte) []byte {
+	ids := make([]uint32, 0, len(types))
+	for id := range types {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	dirSize := func(n int) int { return 16 + 8*n }
+	// Directories first, then data entries, then the data
+	size := dirSize(len(ids))
+	leaves := 0
+	for _, id := range ids {
+		size += dirSize(len(types[id])) + len(types[id])*dirSize(1)
+		leaves += len(types[id])
+	}
+	entryStart := size
+	dataStart := entryStart + leaves*16
+
+	out := make([]byte, dataStart)
+	header := func(at, count int) {
+		binary.LittleEndian.PutUint16(out[at+14:], uint16(count))
+	}
+	entry := func(at int, id uint32, offset int, dir bool) {
+		binary.LittleEndian.PutUint32(out[at:], id)
+		field := uint32(offset)
+		if dir {
+			field |= 0x80000000
+		}
+		binary.LittleEndian.PutUint32(out[at+4:], field)
+	}
+
+	header(0, len(ids))
+	next := dirSize(len(ids))
+	leaf := 0
+	for i, id := range ids {
+		entry(16+i*8, id, next, true)
+		typeDir := next
+		header(typeDir, len(types[id]))
+		next += dirSize(len(types[id]))
+		for j, data := range types[id] {
+			entry(typeDir+16+j*8, uint32(j+1), next, true)
+			header(next, 1)
+			dataEntry := entryStart + leaf*16
+			entry(next+16, 0x409, dataEntry, false)
+			next += dirSize(1)
+
+			binary.LittleEndian.PutUint32(out[dataEntry:], uint32(testSectionRVA+len(out)))
+			binary.LittleEndian.PutUint32(out[dataEntry+4:], uint32(len(data)))
+			out = append(out, data...)
+			for len(out)%4 != 0 {
+				out = append(out, 0)
+			}
+			leaf++
+		}
+	}
+	return out
+}
+
+// buildPE makes a minimal x64 PE file whose single section holds rsrc.
+// The header declares dirs data directories, the resource one pointing at
+// the whole section.
+func buildPE(dirs uint32, rsrc []byte) []byte {
+	var buf bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	buf.Write(dos)
+	buf.WriteString("PE\x00\x00")
+
+	binary.Write(&buf, binary.LittleEndian, pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_AMD64,
+		NumberOfSections:     1,
+		SizeOfOptionalHeader: uint16(112 + 8*dirs),
+		Characteristics:      0x22,
+	})
+
+	oh := pe.OptionalHeader64{
+		Magic:               0x20b,
+		SectionAlignment:    0x1000,
+		FileAlignment:       0x200,
+		SizeOfImage:         testSectionRVA + 0x1000,
+		SizeOfHeaders:       testSectionOffset,
+		Subsystem:           2,
+		NumberOfRvaAndSizes: dirs,
+	}
+	oh.DataDirectory[resourceDirIndex] = pe.DataDirectory{VirtualAddress: testSectionRVA, Size: uint32(len(rsrc))}
+	var ohBuf bytes.Buffer
+	binary.Write(&ohBuf, binary.LittleEndian, oh)
+	ohBytes := ohBuf.Bytes()
+	if want := 112 + 8*int(dirs); want <= len(ohBytes) {
+		ohBytes = ohBytes[:want]
+	} else {
+		ohBytes = append(ohBytes, make([]byte, want-len(ohBytes))...)
+	}
+	buf.Write(ohBytes)
+
+	var name [8]uint8
+	copy(name[:], ".rsrc")
+	binary.Write(&buf, binary.LittleEndian, pe.SectionHeader32{
+		Name:             name,
+		VirtualSize:      uint32(len(rsrc)),
+		VirtualAddress:   testSectionRVA,
+		SizeOfRawData:    uint32(len(rsrc)),
+		PointerToRawData: testSectionOffset,
+		Characteristics:  0x40000040,
+	})
+
+	buf.Write(make([]byte, testSectionOffset-buf.Len()))
+	buf.Write(rsrc)
+	return buf.Bytes()
+}
+
+// writeTemp writes data to a file in a temporary directory
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// openPE parses an in-memory PE file
+func openPE(t *testing.T, data []byte) *pe.File {
+	t.Helper()
+	f, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("pe.NewFile: %v", err)
+	}
+	return f
+}
+
+// utf16Bytes encodes s as null-terminated little-endian UTF-16
+func utf16Bytes(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return append(b, 0, 0)
+}
+
+func TestReadResources(t *testing.T) {
+	rsrc := buildResources(map[uint32][][]byte{
+		rtVersion: {[]byte("version")},
+		rtIcon:    {[]byte("one"), []byte("two")},
+	})
+	res, err := readResources(openPE(t, buildPE(16, rsrc)))
+	if err != nil {
+		t.Fatalf("readResources: %v", err)
+	}
+
+	icons := res.find(rtIcon)
+	if len(icons) != 2 || icons[0].Name.ID != 1 || icons[1].Name.ID != 2 || icons[0].Lang != 0x409 {
+		t.Fatalf("icons = %+v, want IDs 1 and 2 in language 0x409", icons)
+	}
+	data, err := res.data(icons[1])
+	if err != nil || string(data) != "two" {
+		t.Errorf("data = %q, %v; want %q", data, err, "two")
+	}
+	if got := res.find(rtGroupIcon); len(got) != 0 {
+		t.Errorf("group icons = %+v, want none", got)
+	}
+}
+
+func TestReadResourcesDirectoryCount(t *testing.T) {
+	rsrc := buildResources(map[uint32][][]byte{rtVersion: {[]byte("version")}})
+
+	// More directories than debug/pe keeps are ignored past the 16th
+	res, err := readResources(openPE(t, buildPE(17, rsrc)))
+	if err != nil {
+		t.Fatalf("readResources with 17 directories: %v", err)
+	}
+	if len(res.find(rtVersion)) != 1 {
+		t.Errorf("version resources = %+v, want one", res.find(rtVersion))
+	}
+
+	// Too few directories to reach the resource directory
+	if _, err := readResources(openPE(t, buildPE(2, rsrc))); !errors.Is(err, ErrNoResource) {
+		t.Errorf("readResources with 2 directories error = %v, want ErrNoResource", err)
+	}
+}
+
+func TestReadResourcesMalformed(t *testing.T) {
+	rsrc := buildResources(map[uint32][][]byte{rtVersion: {[]byte("version")}})
+
+	tests := []struct {
+		name   string
+		modify func(data []byte)
+	}{
+		{"directory past the section", func(data []byte) {
+			// The resource directory claims more bytes than the section has
+			binary.LittleEndian.PutUint32(data[0x40+4+20+112+8*resourceDirIndex+4:], 0x100000)
+		}},
+		{"entry count past the directory", func(data []byte) {
+			binary.LittleEndian.PutUint16(data[testSectionOffset+14:], 0x7fff)
+		}},
+		{"subdirectory past the directory", func(data []byte) {
+			binary.LittleEndian.PutUint32(data[testSectionOffset+16+4:], 0x80000000|0xffff)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildPE(16, rsrc)
+			tt.modify(data)
+			if _, err := readResources(openPE(t, data)); err == nil {
+				t.Error("readResources succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestResourceDataOutOfRange(t *testing.T) {
+	res, err := readResources(openPE(t, buildPE(16, buildResources(map[uint32][][]byte{rtVersion: {[]byte("version")}}))))
+	if err != nil {
+		t.Fatalf("readResources: %v", err)
+	}
+	leaf := res.find(rtVersion)[0]
+
+	for _, l := range []resourceLeaf{
+		{rva: leaf.rva, size: 0xfffffff0},
+		{rva: 0xfffffff0, size: 0x20},
+		{rva: 0x10, size: 4},
+	} {
+		if _, err := res.data(l); err == nil {
+			t.Errorf("data(rva 0x%x, size 0x%x) succeeded, want an error", l.rva, l.size)
+		}
+	}
+}
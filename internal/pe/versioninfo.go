@@ -0,0 +1,203 @@
+package pe
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const fixedFileInfoSignature = 0xfeef04bd
+
+// VersionInfo holds the VS_VERSIONINFO strings and the architecture of a PE file
+type VersionInfo struct {
+	ProductName      string `json:"productName"`
+	FileDescription  string `json:"fileDescription"`
+	CompanyName      string `json:"companyName"`
+	FileVersion      string `json:"fileVersion"`
+	ProductVersion   string `json:"productVersion"`
+	OriginalFilename string `json:"originalFilename"`
+	Architecture     string `json:"architecture"`
+}
+
+// ReadVersionInfo reads the version resource of a PE file. Files without
+// a version resource still report their architecture.
+func ReadVersionInfo(path string) (*VersionInfo, error) {
+	f, err := pe.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &VersionInfo{Architecture: architecture(f.FileHeader.Machine)}
+
+	res, err := readResources(f)
+	if errors.Is(err, ErrNoResource) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+
+	leaves := res.find(rtVersion)
+	if len(leaves) == 0 {
+		return info, nil
+	}
+	data, err := res.data(leaves[0])
+	if err != nil {
+		return info, err
+	}
+	if err := parseVersionInfo(data, info); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// DisplayName picks the most readable name a version resource offers
+func (v *VersionInfo) DisplayName() string {
+	if v == nil {
+		return ""
+	}
+	if v.FileDescription != "" {
+		return v.FileDescription
+	}
+	return v.ProductName
+}
+
+// versionBlock is one node of the VS_VERSIONINFO tree
+type versionBlock struct {
+	key      string
+	value    []byte
+	isText   bool
+	children []versionBlock
+}
+
+// parseVersionInfo fills info from a VS_VERSIONINFO resource
+func parseVersionInfo(data []byte, info *VersionInfo) error {
+	root, _, err := parseVersionBlock(data, 0)
+	if err != nil {
+		return err
+	}
+	if root.key != "VS_VERSION_INFO" {
+		return fmt.Errorf("unexpected version resource key %q", root.key)
+	}
+
+	if len(root.value) >= 24 && binary.LittleEndian.Uint32(root.value) == fixedFileInfoSignature {
+		ms := binary.LittleEndian.Uint32(root.value[8:])
+		ls := binary.LittleEndian.Uint32(root.value[12:])
+		info.FileVersion = fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xffff, ls>>16, ls&0xffff)
+		ms = binary.LittleEndian.Uint32(root.value[16:])
+		ls = binary.LittleEndian.Uint32(root.value[20:])
+		info.ProductVersion = fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xffff, ls>>16, ls&0xffff)
+	}
+
+	table := pickStringTable(root)
+	if table == nil {
+		return nil
+	}
+	for _, s := range table.children {
+		value := strings.TrimSpace(decodeUTF16(s.value))
+		if value == "" {
+			continue
+		}
+		switch s.key {
+		case "ProductName":
+			info.ProductName = value
+		case "FileDescription":
+			info.FileDescription = value
+		case "CompanyName":
+			info.CompanyName = value
+		case "FileVersion":
+			info.FileVersion = value
+		case "ProductVersion":
+			info.ProductVersion = value
+		case "OriginalFilename":
+			info.OriginalFilename = value
+		}
+	}
+	return nil
+}
+
+// pickStringTable prefers the US English string table, then the first one
+func pickStringTable(root versionBlock) *versionBlock {
+	var first *versionBlock
+	for i := range root.children {
+		if root.children[i].key != "StringFileInfo" {
+			continue
+		}
+		tables := root.children[i].children
+		for j := range tables {
+			if strings.HasPrefix(strings.ToLower(tables[j].key), "0409") {
+				return &tables[j]
+			}
+			if first == nil {
+				first = &tables[j]
+			}
+		}
+	}
+	return first
+}
+
+// parseVersionBlock decodes the block at offset and returns the offset
+// just past it. Every block is a length, a value length, a type, a
+// null-terminated UTF-16 key, a value and child blocks, all 32-bit aligned.
+func parseVersionBlock(data []byte, offset int) (versionBlock, int, error) {
+	var b versionBlock
+	if offset+6 > len(data) {
+		return b, 0, errors.New("truncated version resource")
+	}
+	length := int(binary.LittleEndian.Uint16(data[offset:]))
+	valueLength := int(binary.LittleEndian.Uint16(data[offset+2:]))
+	b.isText = binary.LittleEndian.Uint16(data[offset+4:]) == 1
+
+	end := offset + length
+	if length < 6 || end > len(data) {
+		return b, 0, errors.New("truncated version resource")
+	}
+
+	pos := offset + 6
+	keyStart := pos
+	for pos+1 < end && (data[pos] != 0 || data[pos+1] != 0) {
+		pos += 2
+	}
+	b.key = decodeUTF16(data[keyStart:pos])
+	pos = align4(pos + 2)
+
+	if b.isText {
+		valueLength *= 2
+	}
+	if valueLength > 0 && pos < end {
+		b.value = data[pos:min(pos+valueLength, end)]
+		pos = align4(pos + valueLength)
+	}
+
+	for pos < end {
+		child, next, err := parseVersionBlock(data, pos)
+		if err != nil {
+			return b, 0, err
+		}
+		b.children = append(b.children, child)
+		pos = align4(next)
+	}
+	return b, end, nil
+}
+
+// architecture names the CPU a PE file was built for
+func architecture(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "x86"
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "x64"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT, pe.IMAGE_FILE_MACHINE_ARM:
+		return "arm"
+	}
+	return ""
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
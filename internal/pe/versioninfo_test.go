@@ -0,0 +1,162 @@
+package pe
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// buildVersionBlock lays out a VS_VERSIONINFO block with its children
+func buildVersionBlock(key string, value []byte, text bool, children ...[]byte) []byte {
+	b := make([]byte, 6)
+	b = append(b, utf16Bytes(key)...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	b = append(b, value...)
+	for _, c := range children {
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		b = append(b, c...)
+	}
+
+	valueLength := len(value)
+	if text {
+		valueLength /= 2
+		binary.LittleEndian.PutUint16(b[4:], 1)
+	}
+	binary.LittleEndian.PutUint16(b[0:], uint16(len(b)))
+	binary.LittleEndian.PutUint16(b[2:], uint16(valueLength))
+	return b
+}
+
+// buildVersionInfo makes a version resource with file version 1.2.3.4,
+// product version 5.6.7.8 and the given strings in the US English table
+func buildVersionInfo(strs map[string]string) []byte {
+	fixed := make([]byte, 52)
+	binary.LittleEndian.PutUint32(fixed[0:], fixedFileInfoSignature)
+	binary.LittleEndian.PutUint32(fixed[4:], 0x00010000)
+	binary.LittleEndian.PutUint32(fixed[8:], 1<<16|2)
+	binary.LittleEndian.PutUint32(fixed[12:], 3<<16|4)
+	binary.LittleEndian.PutUint32(fixed[16:], 5<<16|6)
+	binary.LittleEndian.PutUint32(fixed[20:], 7<<16|8)
+
+	var entries [][]byte
+	for _, key := range []string{"CompanyName", "FileDescription", "FileVersion", "ProductName"} {
+		if v, ok := strs[key]; ok {
+			entries = append(entries, buildVersionBlock(key, utf16Bytes(v), true))
+		}
+	}
+	table := buildVersionBlock("040904b0", nil, true, entries...)
+	other := buildVersionBlock("040704b0", nil, true, buildVersionBlock("ProductName", utf16Bytes("Deutsch"), true))
+	stringInfo := buildVersionBlock("StringFileInfo", nil, true, other, table)
+	return buildVersionBlock("VS_VERSION_INFO", fixed, false, stringInfo)
+}
+
+func TestReadVersionInfo(t *testing.T) {
+	version := buildVersionInfo(map[string]string{
+		"CompanyName":     "Contoso Ltd.",
+		"FileDescription": "  Contoso Editor  ",
+		"ProductName":     "Contoso Suite",
+	})
+	path := writeTemp(t, "app.exe", buildPE(16, buildResources(map[uint32][][]byte{rtVersion: {version}})))
+
+	info, err := ReadVersionInfo(path)
+	if err != nil {
+		t.Fatalf("ReadVersionInfo: %v", err)
+	}
+	want := VersionInfo{
+		ProductName:     "Contoso Suite",
+		FileDescription: "Contoso Editor",
+		CompanyName:     "Contoso Ltd.",
+		FileVersion:     "1.2.3.4",
+		ProductVersion:  "5.6.7.8",
+		Architecture:    "x64",
+	}
+	if *info != want {
+		t.Errorf("ReadVersionInfo = %+v, want %+v", *info, want)
+	}
+	if got := info.DisplayName(); got != "Contoso Editor" {
+		t.Errorf("DisplayName = %q, want Contoso Editor", got)
+	}
+}
+
+func TestReadVersionInfoStringOverridesFixed(t *testing.T) {
+	version := buildVersionInfo(map[string]string{"FileVersion": "1.2.3-beta"})
+	path := writeTemp(t, "app.exe", buildPE(16, buildResources(map[uint32][][]byte{rtVersion: {version}})))
+
+	info, err := ReadVersionInfo(path)
+	if err != nil {
+		t.Fatalf("ReadVersionInfo: %v", err)
+	}
+	if info.FileVersion != "1.2.3-beta" || info.ProductVersion != "5.6.7.8" {
+		t.Errorf("versions = %q, %q; want 1.2.3-beta, 5.6.7.8", info.FileVersion, info.ProductVersion)
+	}
+}
+
+func TestReadVersionInfoWithoutResource(t *testing.T) {
+	path := writeTemp(t, "app.exe", buildPE(2, nil))
+
+	info, err := ReadVersionInfo(path)
+	if err != nil {
+		t.Fatalf("ReadVersionInfo: %v", err)
+	}
+	if info.Architecture != "x64" || info.FileVersion != "" {
+		t.Errorf("ReadVersionInfo = %+v, want only the architecture", *info)
+	}
+}
+
+func TestReadVersionInfoTruncatedFile(t *testing.T) {
+	version := buildVersionInfo(map[string]string{"ProductName": "Contoso"})
+	data := buildPE(16, buildResources(map[uint32][][]byte{rtVersion: {version}}))
+
+	// Every cut must fail or succeed cleanly, never panic
+	for n := 0; n < len(data); n += 7 {
+		ReadVersionInfo(writeTemp(t, "app.exe", data[:n]))
+	}
+}
+
+func TestParseVersionInfoTruncated(t *testing.T) {
+	data := buildVersionInfo(map[string]string{"ProductName": "Contoso", "FileVersion": "1.0"})
+
+	for n := 0; n < len(data); n++ {
+		var info VersionInfo
+		if err := parseVersionInfo(data[:n], &info); err == nil {
+			t.Fatalf("parseVersionInfo of %d of %d bytes succeeded, want an error", n, len(data))
+		}
+	}
+}
+
+func TestParseVersionInfoMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(data []byte)
+	}{
+		{"length past the data", func(data []byte) { binary.LittleEndian.PutUint16(data[0:], 0xffff) }},
+		{"length shorter than the header", func(data []byte) { binary.LittleEndian.PutUint16(data[0:], 2) }},
+		{"child length past its parent", func(data []byte) {
+			// The StringFileInfo block follows the 40-byte header and the
+			// 52-byte fixed info
+			binary.LittleEndian.PutUint16(data[92:], 0xfff0)
+		}},
+		{"wrong key", func(data []byte) { data[6] = 'X' }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildVersionInfo(map[string]string{"ProductName": "Contoso"})
+			tt.modify(data)
+			var info VersionInfo
+			if err := parseVersionInfo(data, &info); err == nil {
+				t.Error("parseVersionInfo succeeded, want an error")
+			}
+		})
+	}
+
+	// An oversized value length is cut at the end of its block
+	data := buildVersionInfo(map[string]string{"ProductName": "Contoso"})
+	binary.LittleEndian.PutUint16(data[2:], 0xffff)
+	var info VersionInfo
+	if err := parseVersionInfo(data, &info); err != nil || info.FileVersion != "1.2.3.4" {
+		t.Errorf("oversized value: FileVersion = %q, %v; want 1.2.3.4", info.FileVersion, err)
+	}
+}
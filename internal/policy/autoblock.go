@@ -0,0 +1,280 @@
+package policy
+
+import (
+	"enodia/internal/apps"
+	"enodia/internal/config"
+	"enodia/internal/firewall"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const autoBlockFile = "autoblock.json"
+
+// AutoBlockSettings configure the block applied to newly installed apps
+type AutoBlockSettings struct {
+	Enabled bool `json:"enabled"`
+	// Kinds limits the block to executables of these kinds, such as
+	// "main"; empty blocks every executable. Store apps are blocked as a
+	// whole either way.
+	Kinds []string `json:"kinds,omitempty"`
+}
+
+// ReviewItem is a newly installed app that was blocked automatically and
+// waits for someone to approve or reject it
+type ReviewItem struct {
+	AppID     string `json:"appId"`
+	Name      string `json:"name"`
+	Publisher string `json:"publisher"`
+	Version   string `json:"version"`
+	// Executables are the executables the auto-block blocked; Package is
+	// the display name the rules of a blocked Store app are named after
+	Executables []string  `json:"executables,omitempty"`
+	Package     string    `json:"package,omitempty"`
+	QueuedAt    time.Time `json:"queuedAt"`
+}
+
+// savedAutoBlock is the layout of autoblock.json
+type savedAutoBlock struct {
+	Settings AutoBlockSettings `json:"settings"`
+	Queue    []ReviewItem      `json:"queue"`
+}
+
+// AutoBlock blocks newly installed apps until they are approved
+type AutoBlock struct {
+	fw       *firewall.Manager
+	owners   *RuleOwners
+	mu       sync.Mutex
+	settings AutoBlockSettings
+	queue    map[string]*ReviewItem
+}
+
+// NewAutoBlock loads the auto-block settings and the review queue
+func NewAutoBlock(fw *firewall.Manager, owners *RuleOwners) *AutoBlock {
+	p := &AutoBlock{fw: fw, owners: owners, queue: make(map[string]*ReviewItem)}
+
+	var saved savedAutoBlock
+	if err := config.Load(autoBlockFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load auto-block settings: %v", err)
+	}
+	p.settings = saved.Settings
+	for i := range saved.Queue {
+		item := &saved.Queue[i]
+		p.queue[item.AppID] = item
+		owners.Claim(reviewOwner(item.AppID), item.ruleKeys())
+	}
+	return p
+}
+
+// Settings returns the auto-block settings
+func (p *AutoBlock) Settings() AutoBlockSettings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.settings
+	s.Kinds = append([]string(nil), s.Kinds...)
+	return s
+}
+
+// SetSettings replaces the auto-block settings. Apps already queued keep
+// the block they got.
+func (p *AutoBlock) SetSettings(s AutoBlockSettings) error {
+	for _, kind := range s.Kinds {
+		if !apps.IsKind(kind) {
+			return fmt.Errorf("unknown executable kind %q", kind)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settings = AutoBlockSettings{Enabled: s.Enabled, Kinds: append([]string(nil), s.Kinds...)}
+	return p.saveLocked()
+}
+
+// Apply blocks newly installed apps, when enabled, and queues them for
+// review. It returns the apps it queued.
+func (p *AutoBlock) Apply(added []apps.InstalledApp) []ReviewItem {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.settings.Enabled || len(added) == 0 {
+		return nil
+	}
+
+	var queued []ReviewItem
+	for _, app := range added {
+		if _, exists := p.queue[app.ID]; exists {
+			continue
+		}
+		item := ReviewItem{
+			AppID:     app.ID,
+			Name:      app.Name,
+			Publisher: app.Publisher,
+			Version:   app.Version,
+			QueuedAt:  time.Now(),
+		}
+
+		if app.AppType == "store" {
+			if app.PackageSID == "" {
+				continue
+			}
+			if err := p.fw.BlockStoreApp(app.PackageSID, app.Name); err != nil {
+				log.Printf("[Enodia] Warning: Could not block %s: %v", app.Name, err)
+				continue
+			}
+			item.Package = app.Name
+		} else {
+			exes := app.Executables
+			if len(p.settings.Kinds) > 0 {
+				exes = app.ExecutablesOfKind(p.settings.Kinds...)
+			}
+			if len(exes) == 0 {
+				continue
+			}
+			for exe, err := range p.fw.BlockApps(exes) {
+				if err != nil {
+					log.Printf("[Enodia] Warning: Could not block %s: %v", exe, err)
+					continue
+				}
+				item.Executables = append(item.Executables, exe)
+			}
+			if len(item.Executables) == 0 {
+				continue
+			}
+			sort.Strings(item.Executables)
+		}
+
+		log.Printf("[Enodia] Auto-blocked new app %s pending review", app.Name)
+		p.owners.Claim(reviewOwner(app.ID), item.ruleKeys())
+		p.queue[app.ID] = &item
+		queued = append(queued, item)
+	}
+
+	if len(queued) > 0 {
+		if err := p.saveLocked(); err != nil {
+			log.Printf("[Enodia] Warning: Could not save review queue: %v", err)
+		}
+	}
+	return queued
+}
+
+// Queue returns the apps waiting for review, oldest first
+func (p *AutoBlock) Queue() []ReviewItem {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queueLocked()
+}
+
+// Approve lifts the auto-block of an app and takes it off the queue.
+// Rules that another block still holds are kept. The review lets go of
+// each rule only once it is removed, so an app whose rules could not all
+// be removed stays queued.
+func (p *AutoBlock) Approve(appID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	item, exists := p.queue[appID]
+	if !exists {
+		return fmt.Errorf("app %s is not waiting for review", appID)
+	}
+	owner := reviewOwner(appID)
+	var exes, released []string
+	var firstErr error
+	for _, path := range item.ruleKeys() {
+		switch {
+		case len(p.owners.Others(owner, path)) > 0:
+			released = append(released, path)
+		case path == PackageKey(item.Package):
+			if err := p.fw.UnblockStoreApp(item.Package); err != nil {
+				firstErr = err
+				continue
+			}
+			released = append(released, path)
+		default:
+			exes = append(exes, path)
+		}
+	}
+	if len(exes) > 0 {
+		for exe, err := range p.fw.UnblockApps(exes) {
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			released = append(released, exe)
+		}
+	}
+	p.owners.Release(owner, released)
+	if firstErr != nil {
+		return firstErr
+	}
+	delete(p.queue, appID)
+	log.Printf("[Enodia] Approved %s", item.Name)
+	return p.saveLocked()
+}
+
+// Reject keeps the auto-block of an app and takes it off the queue. The
+// rules then belong to the user like any other block.
+func (p *AutoBlock) Reject(appID string) (ReviewItem, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	item, exists := p.queue[appID]
+	if !exists {
+		return ReviewItem{}, fmt.Errorf("app %s is not waiting for review", appID)
+	}
+	keys := item.ruleKeys()
+	p.owners.Claim(OwnerManual, keys)
+	p.owners.Release(reviewOwner(appID), keys)
+	delete(p.queue, appID)
+	log.Printf("[Enodia] Rejected %s, keeping its block", item.Name)
+	return *item, p.saveLocked()
+}
+
+// Clear empties the review queue and keeps the settings
+func (p *AutoBlock) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queue = make(map[string]*ReviewItem)
+	if err := p.saveLocked(); err != nil {
+		log.Printf("[Enodia] Warning: Could not save review queue: %v", err)
+	}
+}
+
+// ruleKeys returns the ownership keys of the rules the auto-block made
+func (item *ReviewItem) ruleKeys() []string {
+	keys := append([]string(nil), item.Executables...)
+	if item.Package != "" {
+		keys = append(keys, PackageKey(item.Package))
+	}
+	return keys
+}
+
+// reviewOwner names an app waiting for review as the owner of its rules
+func reviewOwner(appID string) string {
+	return "review:" + appID
+}
+
+// queueLocked returns a copy of the queue. The caller must hold p.mu.
+func (p *AutoBlock) queueLocked() []ReviewItem {
+	queue := make([]ReviewItem, 0, len(p.queue))
+	for _, item := range p.queue {
+		c := *item
+		c.Executables = append([]string(nil), item.Executables...)
+		queue = append(queue, c)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if !queue[i].QueuedAt.Equal(queue[j].QueuedAt) {
+			return queue[i].QueuedAt.Before(queue[j].QueuedAt)
+		}
+		return queue[i].Name < queue[j].Name
+	})
+	return queue
+}
+
+// saveLocked persists the settings and the queue. The caller must hold p.mu.
+func (p *AutoBlock) saveLocked() error {
+	return config.Save(autoBlockFile, savedAutoBlock{Settings: p.settings, Queue: p.queueLocked()})
+}
@@ -0,0 +1,329 @@
+package policy
+
+import (
+	"enodia/internal/config"
+	"enodia/internal/firewall"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	foldersFile = "folders.json"
+
+	// folderSettleDelay gives installers time to finish writing before a rescan
+	folderSettleDelay = 2 * time.Second
+	// folderPollInterval is used when change notifications are unavailable
+	folderPollInterval = 30 * time.Second
+)
+
+// BlockedFolder is a folder whose executables are all blocked
+type BlockedFolder struct {
+	Path        string   `json:"path"`
+	Executables []string `json:"executables"`
+}
+
+// watchedFolder is a blocked folder with its watcher goroutine
+type watchedFolder struct {
+	BlockedFolder
+	stop chan struct{}
+	// syncMu makes syncs of the folder take turns
+	syncMu sync.Mutex
+}
+
+// FolderWatcher blocks every executable below a folder and keeps the
+// rules in sync as executables are added or removed
+type FolderWatcher struct {
+	fw      *firewall.Manager
+	owners  *RuleOwners
+	mu      sync.Mutex
+	folders map[string]*watchedFolder
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewFolderWatcher restores saved folder blocks and starts watching them
+func NewFolderWatcher(fw *firewall.Manager, owners *RuleOwners) *FolderWatcher {
+	w := &FolderWatcher{
+		fw:      fw,
+		owners:  owners,
+		folders: make(map[string]*watchedFolder),
+	}
+
+	var saved []BlockedFolder
+	if err := config.Load(foldersFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load blocked folders: %v", err)
+	}
+	w.mu.Lock()
+	for _, f := range saved {
+		owners.Claim(folderOwner(f.Path), f.Executables)
+		w.startLocked(f)
+	}
+	w.mu.Unlock()
+	return w
+}
+
+// Close stops all folder watchers. The firewall rules stay in place.
+// Closing twice is harmless.
+func (w *FolderWatcher) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		for key, f := range w.folders {
+			close(f.stop)
+			delete(w.folders, key)
+		}
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+// BlockFolder blocks every executable under dir and watches it for new ones
+func (w *FolderWatcher) BlockFolder(dir string) error {
+	dir = filepath.Clean(dir)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open folder: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", dir)
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return fmt.Errorf("folder watcher is closed")
+	}
+	if _, exists := w.folders[folderKey(dir)]; exists {
+		w.mu.Unlock()
+		return nil
+	}
+	f := w.startLocked(BlockedFolder{Path: dir})
+	w.mu.Unlock()
+
+	w.sync(f)
+	log.Printf("[Enodia] Blocked folder: %s", dir)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.saveLocked()
+}
+
+// UnblockFolder stops watching dir and removes the rules it created,
+// except those another block still holds
+func (w *FolderWatcher) UnblockFolder(dir string) error {
+	key := folderKey(dir)
+
+	w.mu.Lock()
+	f, exists := w.folders[key]
+	if !exists {
+		w.mu.Unlock()
+		return fmt.Errorf("%s is not blocked", dir)
+	}
+	close(f.stop)
+	delete(w.folders, key)
+	exes := f.Executables
+	err := w.saveLocked()
+	w.mu.Unlock()
+
+	if free := w.owners.Release(folderOwner(f.Path), exes); len(free) > 0 {
+		w.fw.UnblockApps(free)
+	}
+	log.Printf("[Enodia] Unblocked folder: %s", f.Path)
+	return err
+}
+
+// BlockedFolders returns the currently blocked folders
+func (w *FolderWatcher) BlockedFolders() []BlockedFolder {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	result := make([]BlockedFolder, 0, len(w.folders))
+	for _, f := range w.folders {
+		result = append(result, BlockedFolder{
+			Path:        f.Path,
+			Executables: append([]string(nil), f.Executables...),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// startLocked registers a folder and launches its watcher goroutine.
+// The caller must hold w.mu.
+func (w *FolderWatcher) startLocked(folder BlockedFolder) *watchedFolder {
+	f := &watchedFolder{BlockedFolder: folder, stop: make(chan struct{})}
+	w.folders[folderKey(folder.Path)] = f
+
+	w.wg.Add(1)
+	go w.watch(f)
+	return f
+}
+
+// poll rescans a folder periodically until it is unblocked
+func (w *FolderWatcher) poll(f *watchedFolder) {
+	ticker := time.NewTicker(folderPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			w.sync(f)
+		}
+	}
+}
+
+// sync blocks new executables under a folder and unblocks vanished ones.
+// The firewall is changed without holding w.mu, so other calls are not
+// held up by a large batch.
+func (w *FolderWatcher) sync(f *watchedFolder) {
+	f.syncMu.Lock()
+	defer f.syncMu.Unlock()
+
+	// Only a complete scan tells which executables are gone, so a folder
+	// that is missing or unreadable, as on a disconnected drive, is left
+	// as it is
+	current, err := findAllExecutables(f.Path)
+	if err != nil {
+		log.Printf("[Enodia] Warning: Could not scan folder %s: %v", f.Path, err)
+		return
+	}
+
+	w.mu.Lock()
+	if stopped(f) {
+		w.mu.Unlock()
+		return
+	}
+	known := make(map[string]bool, len(f.Executables))
+	for _, exe := range f.Executables {
+		known[strings.ToLower(exe)] = true
+	}
+	present := make(map[string]bool, len(current))
+	for _, exe := range current {
+		present[strings.ToLower(exe)] = true
+	}
+	var added, removed []string
+	blocked := make([]string, 0, len(current))
+	for _, exe := range current {
+		if known[strings.ToLower(exe)] {
+			blocked = append(blocked, exe)
+		} else {
+			added = append(added, exe)
+		}
+	}
+	for _, exe := range f.Executables {
+		if !present[strings.ToLower(exe)] {
+			removed = append(removed, exe)
+		}
+	}
+	w.mu.Unlock()
+
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+
+	owner := folderOwner(f.Path)
+	var newlyBlocked []string
+	if len(added) > 0 {
+		for path, err := range w.fw.BlockApps(added) {
+			if err != nil {
+				log.Printf("[Enodia] Warning: Could not block %s: %v", path, err)
+				continue
+			}
+			newlyBlocked = append(newlyBlocked, path)
+		}
+		w.owners.Claim(owner, newlyBlocked)
+	}
+	if free := w.owners.Release(owner, removed); len(free) > 0 {
+		w.fw.UnblockApps(free)
+	}
+
+	w.mu.Lock()
+	if stopped(f) {
+		closed := w.closed
+		w.mu.Unlock()
+		// Unblocked meanwhile: the rules just made go too. On Close all
+		// rules stay in place.
+		if !closed {
+			if free := w.owners.Release(owner, newlyBlocked); len(free) > 0 {
+				w.fw.UnblockApps(free)
+			}
+		}
+		return
+	}
+	defer w.mu.Unlock()
+
+	blocked = append(blocked, newlyBlocked...)
+	sort.Strings(blocked)
+	f.Executables = blocked
+	log.Printf("[Enodia] Folder %s: %d new, %d removed executables", f.Path, len(added), len(removed))
+
+	if err := w.saveLocked(); err != nil {
+		log.Printf("[Enodia] Warning: Could not save blocked folders: %v", err)
+	}
+}
+
+// stopped reports whether a folder was unblocked or the watcher closed
+func stopped(f *watchedFolder) bool {
+	select {
+	case <-f.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// saveLocked persists the folder list. The caller must hold w.mu.
+func (w *FolderWatcher) saveLocked() error {
+	saved := make([]BlockedFolder, 0, len(w.folders))
+	for _, f := range w.folders {
+		saved = append(saved, f.BlockedFolder)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Path < saved[j].Path })
+	return config.Save(foldersFile, saved)
+}
+
+// findAllExecutables finds every .exe file below dir, at any depth. It
+// fails if dir or any folder below it cannot be read.
+func findAllExecutables(dir string) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", dir)
+	}
+
+	var exes []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".exe") {
+			exes = append(exes, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return exes, nil
+}
+
+// folderOwner names a folder block as the owner of its rules
+func folderOwner(dir string) string {
+	return "folder:" + dir
+}
+
+// folderKey normalizes a folder path for case-insensitive lookups
+func folderKey(dir string) string {
+	return strings.ToLower(filepath.Clean(dir))
+}
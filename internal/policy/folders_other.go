@@ -0,0 +1,11 @@
+//go:build !windows
+
+package policy
+
+// watch polls a folder on platforms without change notifications
+func (w *FolderWatcher) watch(f *watchedFolder) {
+	defer w.wg.Done()
+
+	w.sync(f)
+	w.poll(f)
+}
@@ -0,0 +1,58 @@
+//go:build windows
+
+package policy
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// watch rescans a folder whenever Windows reports a change below it
+func (w *FolderWatcher) watch(f *watchedFolder) {
+	defer w.wg.Done()
+
+	// Catch up on anything that changed while Enodia was not running
+	w.sync(f)
+
+	handle, err := windows.FindFirstChangeNotification(f.Path, true,
+		windows.FILE_NOTIFY_CHANGE_FILE_NAME|windows.FILE_NOTIFY_CHANGE_DIR_NAME)
+	if err != nil {
+		log.Printf("[Enodia] Warning: Could not watch %s, polling instead: %v", f.Path, err)
+		w.poll(f)
+		return
+	}
+	defer windows.FindCloseChangeNotification(handle)
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		default:
+		}
+
+		event, err := windows.WaitForSingleObject(handle, 1000)
+		if err != nil {
+			log.Printf("[Enodia] Warning: Watch on %s failed, polling instead: %v", f.Path, err)
+			w.poll(f)
+			return
+		}
+		if event != windows.WAIT_OBJECT_0 {
+			continue
+		}
+
+		select {
+		case <-f.stop:
+			return
+		case <-time.After(folderSettleDelay):
+		}
+		w.sync(f)
+
+		if err := windows.FindNextChangeNotification(handle); err != nil {
+			log.Printf("[Enodia] Warning: Watch on %s failed, polling instead: %v", f.Path, err)
+			w.poll(f)
+			return
+		}
+	}
+}
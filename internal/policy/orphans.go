@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"enodia/internal/firewall"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Reasons a rule is orphaned
+const (
+	OrphanMissingExecutable = "missing-executable"
+	OrphanMissingPackage    = "missing-package"
+)
+
+// OrphanedRule is an Enodia rule for an executable or package that is
+// gone, as after the app was uninstalled
+type OrphanedRule struct {
+	firewall.Rule
+	Reason string `json:"reason"`
+}
+
+// FindOrphanedRules returns the rules whose executable no longer exists or
+// whose package SID is not one of the installed packages. With nil
+// packageSIDs, as when Store apps could not be listed, package rules are
+// not checked.
+func FindOrphanedRules(rules []firewall.Rule, packageSIDs map[string]bool) []OrphanedRule {
+	orphans := []OrphanedRule{}
+	for _, r := range rules {
+		switch {
+		case r.AppPath != "":
+			// Only a file that is surely gone counts; access errors do not
+			if _, err := os.Stat(r.AppPath); os.IsNotExist(err) {
+				orphans = append(orphans, OrphanedRule{Rule: r, Reason: OrphanMissingExecutable})
+			}
+		case r.PackageSID != "" && packageSIDs != nil:
+			if !packageSIDs[strings.ToUpper(r.PackageSID)] {
+				orphans = append(orphans, OrphanedRule{Rule: r, Reason: OrphanMissingPackage})
+			}
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Name < orphans[j].Name })
+	return orphans
+}
@@ -0,0 +1,209 @@
+package policy
+
+import (
+	"enodia/internal/config"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const ownersFile = "owners.json"
+
+// OwnerManual holds the rules the user created by hand. Policies own
+// their rules under names like "folder:C:\Tools" or "tag:games".
+const OwnerManual = "manual"
+
+// OwnedRule lists what holds the rules of one executable or package
+type OwnedRule struct {
+	// Path is the executable path, or PackageKey of a Store app
+	Path   string   `json:"path"`
+	Owners []string `json:"owners"`
+}
+
+// RuleOwners records which blocks hold each Enodia rule, so a policy
+// that lets go of an executable removes its rules only when nothing else
+// still holds it
+type RuleOwners struct {
+	mu    sync.Mutex
+	rules map[string]*OwnedRule
+	// fresh is set when there was no saved ownership to load
+	fresh bool
+}
+
+// PackageKey is the ownership key of the rules of a Store app, which are
+// named after its display name
+func PackageKey(name string) string {
+	return "PKG-" + name
+}
+
+// LoadRuleOwners reads the saved rule ownership
+func LoadRuleOwners() *RuleOwners {
+	o := &RuleOwners{rules: make(map[string]*OwnedRule)}
+
+	var saved []OwnedRule
+	if err := config.Load(ownersFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load rule owners: %v", err)
+	}
+	o.fresh = saved == nil
+	for i := range saved {
+		o.rules[ruleKey(saved[i].Path)] = &saved[i]
+	}
+	return o
+}
+
+// Claim records that owner holds the rules of the given paths
+func (o *RuleOwners) Claim(owner string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, path := range paths {
+		key := ruleKey(path)
+		r, ok := o.rules[key]
+		if !ok {
+			r = &OwnedRule{Path: path}
+			o.rules[key] = r
+		}
+		if !containsOwner(r.Owners, owner) {
+			r.Owners = append(r.Owners, owner)
+		}
+	}
+	o.saveLocked()
+}
+
+// Release drops owner's hold on the given paths and returns the paths
+// nothing holds any more, whose rules may be removed
+func (o *RuleOwners) Release(owner string, paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var free []string
+	for _, path := range paths {
+		key := ruleKey(path)
+		r, ok := o.rules[key]
+		if !ok {
+			free = append(free, path)
+			continue
+		}
+		kept := r.Owners[:0]
+		for _, existing := range r.Owners {
+			if existing != owner {
+				kept = append(kept, existing)
+			}
+		}
+		r.Owners = kept
+		if len(kept) == 0 {
+			delete(o.rules, key)
+			free = append(free, path)
+		}
+	}
+	o.saveLocked()
+	return free
+}
+
+// Owners returns what holds the rules of a path
+func (o *RuleOwners) Owners(path string) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if r, ok := o.rules[ruleKey(path)]; ok {
+		return append([]string(nil), r.Owners...)
+	}
+	return nil
+}
+
+// Others returns what holds the rules of a path besides owner
+func (o *RuleOwners) Others(owner, path string) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var others []string
+	if r, ok := o.rules[ruleKey(path)]; ok {
+		for _, existing := range r.Owners {
+			if existing != owner {
+				others = append(others, existing)
+			}
+		}
+	}
+	return others
+}
+
+// Drop forgets the owners of the given paths, whose rules were removed
+// outright
+func (o *RuleOwners) Drop(paths []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, path := range paths {
+		delete(o.rules, ruleKey(path))
+	}
+	o.saveLocked()
+}
+
+// Clear forgets every owner
+func (o *RuleOwners) Clear() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.rules = make(map[string]*OwnedRule)
+	o.saveLocked()
+}
+
+// AdoptUnowned hands the rules of the given paths that nothing holds to
+// the user. It only acts the first time ownership is recorded, so blocks
+// made before Enodia tracked owners are kept as manual blocks.
+func (o *RuleOwners) AdoptUnowned(paths []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.fresh {
+		return
+	}
+	o.fresh = false
+	for _, path := range paths {
+		key := ruleKey(path)
+		if _, ok := o.rules[key]; !ok {
+			o.rules[key] = &OwnedRule{Path: path, Owners: []string{OwnerManual}}
+		}
+	}
+	o.saveLocked()
+}
+
+// saveLocked persists the owners. The caller must hold o.mu.
+func (o *RuleOwners) saveLocked() {
+	saved := make([]OwnedRule, 0, len(o.rules))
+	for _, r := range o.rules {
+		saved = append(saved, *r)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Path < saved[j].Path })
+	if err := config.Save(ownersFile, saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not save rule owners: %v", err)
+	}
+}
+
+// DescribeOwners names owners for messages, as in "folder C:\Tools, tag
+// games"
+func DescribeOwners(owners []string) string {
+	names := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		names = append(names, strings.Replace(owner, ":", " ", 1))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// containsOwner reports whether owners contains owner
+func containsOwner(owners []string, owner string) bool {
+	for _, o := range owners {
+		if o == owner {
+			return true
+		}
+	}
+	return false
+}
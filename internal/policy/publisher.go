@@ -0,0 +1,220 @@
+package policy
+
+import (
+	"enodia/internal/apps"
+	"enodia/internal/config"
+	"enodia/internal/firewall"
+	"enodia/internal/pe"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const publishersFile = "publishers.json"
+
+// PublisherBlock blocks every executable signed by one signer
+type PublisherBlock struct {
+	// Signer is matched against the certificate subject, case-insensitively
+	Signer      string    `json:"signer"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Executables []string  `json:"executables"`
+}
+
+// cachedSigner avoids re-hashing executables that did not change
+type cachedSigner struct {
+	size    int64
+	modTime time.Time
+	subject string
+}
+
+// PublisherPolicy applies publisher blocks to discovered executables
+type PublisherPolicy struct {
+	fw      *firewall.Manager
+	owners  *RuleOwners
+	mu      sync.Mutex
+	blocks  map[string]*PublisherBlock
+	signers map[string]cachedSigner
+}
+
+// NewPublisherPolicy loads the saved publisher blocks
+func NewPublisherPolicy(fw *firewall.Manager, owners *RuleOwners) *PublisherPolicy {
+	p := &PublisherPolicy{
+		fw:      fw,
+		owners:  owners,
+		blocks:  make(map[string]*PublisherBlock),
+		signers: make(map[string]cachedSigner),
+	}
+
+	var saved []PublisherBlock
+	if err := config.Load(publishersFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load publisher blocks: %v", err)
+	}
+	for i := range saved {
+		p.blocks[strings.ToLower(saved[i].Signer)] = &saved[i]
+		owners.Claim(publisherOwner(saved[i].Signer), saved[i].Executables)
+	}
+	return p
+}
+
+// Block adds a publisher block and applies it to the discovered apps
+func (p *PublisherPolicy) Block(signer string, discovered []apps.InstalledApp) error {
+	signer = strings.TrimSpace(signer)
+	if signer == "" {
+		return fmt.Errorf("signer is required")
+	}
+
+	p.mu.Lock()
+	key := strings.ToLower(signer)
+	if _, exists := p.blocks[key]; !exists {
+		p.blocks[key] = &PublisherBlock{Signer: signer, CreatedAt: time.Now()}
+		log.Printf("[Enodia] Blocking publisher: %s", signer)
+	}
+	err := p.saveLocked()
+	p.mu.Unlock()
+
+	p.Apply(discovered)
+	return err
+}
+
+// Unblock removes a publisher block together with the rules it created,
+// except those another block still holds
+func (p *PublisherPolicy) Unblock(signer string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.ToLower(strings.TrimSpace(signer))
+	block, exists := p.blocks[key]
+	if !exists {
+		return fmt.Errorf("publisher %s is not blocked", signer)
+	}
+	if free := p.owners.Release(publisherOwner(block.Signer), block.Executables); len(free) > 0 {
+		p.fw.UnblockApps(free)
+	}
+	delete(p.blocks, key)
+	log.Printf("[Enodia] Unblocked publisher: %s", block.Signer)
+	return p.saveLocked()
+}
+
+// Blocks returns all publisher blocks
+func (p *PublisherPolicy) Blocks() []PublisherBlock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]PublisherBlock, 0, len(p.blocks))
+	for _, b := range p.blocks {
+		result = append(result, PublisherBlock{
+			Signer:      b.Signer,
+			CreatedAt:   b.CreatedAt,
+			Executables: append([]string(nil), b.Executables...),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Signer < result[j].Signer })
+	return result
+}
+
+// Apply blocks every discovered executable signed by a blocked publisher
+// that is not covered yet, and drops executables that have disappeared
+func (p *PublisherPolicy) Apply(discovered []apps.InstalledApp) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.blocks) == 0 {
+		return
+	}
+
+	changed := false
+	for _, block := range p.blocks {
+		kept := block.Executables[:0]
+		for _, exe := range block.Executables {
+			if _, err := os.Stat(exe); err == nil {
+				kept = append(kept, exe)
+			} else {
+				if free := p.owners.Release(publisherOwner(block.Signer), []string{exe}); len(free) > 0 {
+					p.fw.UnblockApp(exe)
+				}
+				changed = true
+			}
+		}
+		block.Executables = kept
+	}
+
+	for _, app := range discovered {
+		if app.AppType != "win32" {
+			continue
+		}
+		for _, exe := range app.Executables {
+			// Blocking is the safe direction, so an untrusted chain still matches
+			subject := p.signerOf(exe)
+			if subject == "" {
+				continue
+			}
+			block, exists := p.blocks[strings.ToLower(subject)]
+			if !exists || containsPath(block.Executables, exe) {
+				continue
+			}
+			if err := p.fw.BlockApp(exe); err != nil {
+				log.Printf("[Enodia] Warning: Could not block %s: %v", exe, err)
+				continue
+			}
+			log.Printf("[Enodia] Blocked %s (signed by %s)", exe, subject)
+			p.owners.Claim(publisherOwner(block.Signer), []string{exe})
+			block.Executables = append(block.Executables, exe)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := p.saveLocked(); err != nil {
+			log.Printf("[Enodia] Warning: Could not save publisher blocks: %v", err)
+		}
+	}
+}
+
+// signerOf returns the signer subject of an executable, using the cache
+// while the file is unchanged. The caller must hold p.mu.
+func (p *PublisherPolicy) signerOf(exe string) string {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return ""
+	}
+	key := strings.ToLower(exe)
+	if c, ok := p.signers[key]; ok && c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+		return c.subject
+	}
+
+	subject := ""
+	if sig, err := pe.ReadSignature(exe); err == nil {
+		subject = sig.Subject
+	}
+	p.signers[key] = cachedSigner{size: info.Size(), modTime: info.ModTime(), subject: subject}
+	return subject
+}
+
+// saveLocked persists the publisher blocks. The caller must hold p.mu.
+func (p *PublisherPolicy) saveLocked() error {
+	saved := make([]PublisherBlock, 0, len(p.blocks))
+	for _, b := range p.blocks {
+		saved = append(saved, *b)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Signer < saved[j].Signer })
+	return config.Save(publishersFile, saved)
+}
+
+// publisherOwner names a publisher block as the owner of its rules
+func publisherOwner(signer string) string {
+	return "publisher:" + strings.ToLower(signer)
+}
+
+// containsPath reports whether paths contains path, ignoring case
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if strings.EqualFold(p, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package policy
+
+import (
+	"enodia/internal/config"
+	"errors"
+)
+
+// stateFiles are the files the policies keep their blocks and rule owners in
+var stateFiles = []string{
+	foldersFile, publishersFile, tagBlocksFile, trackedFile, ownersFile, autoBlockFile,
+}
+
+// RemoveState deletes the saved state of every policy, so that purging the
+// rules while Enodia is not running leaves no blocks to restore on the next
+// start
+func RemoveState() error {
+	var errs []error
+	for _, name := range stateFiles {
+		if err := config.Remove(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
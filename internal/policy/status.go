@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"enodia/internal/apps"
+	"enodia/internal/firewall"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Block states of a discovered app
+const (
+	// StateBlocked: every executable is blocked in both directions, or a
+	// Store app's package is blocked outbound
+	StateBlocked = "blocked"
+	// StatePartial: some executables, or only one direction, are blocked
+	StatePartial = "partial"
+	// StatePaused: the app has Enodia rules, but all are disabled
+	StatePaused = "paused"
+	// StateUnblocked: the app has no Enodia rules
+	StateUnblocked = "unblocked"
+)
+
+// Block directions
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+	DirectionBoth     = "both"
+)
+
+// ExecutableStatus is the block state of one executable of an app, or of
+// the package of a Store app
+type ExecutableStatus struct {
+	Path            string `json:"path"`
+	InboundBlocked  bool   `json:"inboundBlocked"`
+	OutboundBlocked bool   `json:"outboundBlocked"`
+	Paused          bool   `json:"paused"`
+	// Package is set for the package of a Store app. AppContainers accept
+	// no inbound connections they do not declare, so the package counts
+	// as fully blocked once its outbound rule is enabled.
+	Package bool `json:"package,omitempty"`
+}
+
+// AppStatus is a discovered app with its block state
+type AppStatus struct {
+	App   apps.InstalledApp `json:"app"`
+	State string            `json:"state"`
+	// Direction is the union of the directions blocked on any of the
+	// app's executables, or "" when none is
+	Direction string `json:"direction"`
+	// Blocked counts the executables with at least one enabled rule
+	Blocked     int                `json:"blocked"`
+	Total       int                `json:"total"`
+	Executables []ExecutableStatus `json:"executables"`
+}
+
+// StatusView joins the discovered apps with the firewall rules
+type StatusView struct {
+	Apps []AppStatus `json:"apps"`
+	// Orphans are blocked executables and packages that belong to no
+	// discovered app, such as those of uninstalled apps
+	Orphans []firewall.BlockedApp `json:"orphans"`
+}
+
+// JoinStatus computes the block state of every discovered app from the
+// Enodia rules. Executables are matched by path, ignoring case, and Store
+// apps by the package SID their rules apply to, which unlike the display
+// name in the rule names is unique.
+func JoinStatus(discovered []apps.InstalledApp, blocked []firewall.BlockedApp) StatusView {
+	byKey := make(map[string]firewall.BlockedApp, len(blocked))
+	bySID := make(map[string]firewall.BlockedApp)
+	for _, b := range blocked {
+		if b.PackageSID != "" {
+			bySID[strings.ToUpper(b.PackageSID)] = b
+		} else {
+			byKey[ruleKey(b.AppPath)] = b
+		}
+	}
+	matched := make(map[string]bool)
+
+	view := StatusView{Apps: make([]AppStatus, 0, len(discovered)), Orphans: []firewall.BlockedApp{}}
+	for _, app := range discovered {
+		status := AppStatus{App: app, Executables: []ExecutableStatus{}}
+		if app.AppType == "store" {
+			exe := ExecutableStatus{Path: PackageKey(app.Name), Package: true}
+			if b, ok := bySID[strings.ToUpper(app.PackageSID)]; ok && app.PackageSID != "" {
+				matched[ruleKey(b.AppPath)] = true
+				exe.Path = b.AppPath
+				applyRuleState(&exe, b)
+			}
+			status.Executables = append(status.Executables, exe)
+		} else {
+			for _, target := range app.Executables {
+				exe := ExecutableStatus{Path: target}
+				if b, ok := byKey[ruleKey(target)]; ok {
+					matched[ruleKey(target)] = true
+					applyRuleState(&exe, b)
+				}
+				status.Executables = append(status.Executables, exe)
+			}
+		}
+		status.Total = len(status.Executables)
+		status.State, status.Direction, status.Blocked = blockState(status.Executables)
+		view.Apps = append(view.Apps, status)
+	}
+
+	for _, b := range blocked {
+		if !matched[ruleKey(b.AppPath)] {
+			view.Orphans = append(view.Orphans, b)
+		}
+	}
+	sort.Slice(view.Orphans, func(i, j int) bool { return view.Orphans[i].AppPath < view.Orphans[j].AppPath })
+	return view
+}
+
+// applyRuleState copies the state of an executable's or package's rules
+func applyRuleState(exe *ExecutableStatus, b firewall.BlockedApp) {
+	exe.InboundBlocked = b.InboundBlocked
+	exe.OutboundBlocked = b.OutboundBlocked
+	exe.Paused = b.Paused
+}
+
+// blockState sums up the states of an app's executables
+func blockState(exes []ExecutableStatus) (state, direction string, blocked int) {
+	inbound, outbound, full, paused := false, false, 0, 0
+	for _, e := range exes {
+		if e.InboundBlocked || e.OutboundBlocked {
+			blocked++
+		}
+		if e.OutboundBlocked && (e.InboundBlocked || e.Package) {
+			full++
+		}
+		if e.Paused {
+			paused++
+		}
+		inbound = inbound || e.InboundBlocked
+		outbound = outbound || e.OutboundBlocked
+	}
+
+	switch {
+	case inbound && outbound:
+		direction = DirectionBoth
+	case inbound:
+		direction = DirectionInbound
+	case outbound:
+		direction = DirectionOutbound
+	}
+
+	switch {
+	case len(exes) > 0 && full == len(exes):
+		state = StateBlocked
+	case blocked > 0:
+		state = StatePartial
+	case paused > 0:
+		state = StatePaused
+	default:
+		state = StateUnblocked
+	}
+	return state, direction, blocked
+}
+
+// ruleKey normalizes the path or package name of a rule for matching
+func ruleKey(path string) string {
+	if strings.HasPrefix(path, "PKG-") {
+		return strings.ToLower(path)
+	}
+	return strings.ToLower(filepath.Clean(path))
+}
@@ -0,0 +1,259 @@
+package policy
+
+import (
+	"enodia/internal/apps"
+	"enodia/internal/config"
+	"enodia/internal/firewall"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const tagBlocksFile = "tagblocks.json"
+
+// TagBlock blocks every app carrying a tag or category, including apps
+// that get it later
+type TagBlock struct {
+	Tag         string    `json:"tag"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Executables []string  `json:"executables"`
+	// Packages are the display names the rules of blocked Store apps are
+	// named after
+	Packages []string `json:"packages"`
+}
+
+// tagRules is the part of the firewall a tag policy changes
+type tagRules interface {
+	BlockApp(exePath string) error
+	UnblockApp(exePath string) error
+	UnblockApps(exePaths []string) map[string]error
+	BlockStoreApp(packageSID, displayName string) error
+	UnblockStoreApp(displayName string) error
+}
+
+// TagPolicy applies tag blocks to discovered apps
+type TagPolicy struct {
+	fw     tagRules
+	owners *RuleOwners
+	mu     sync.Mutex
+	blocks map[string]*TagBlock
+}
+
+// NewTagPolicy loads the saved tag blocks
+func NewTagPolicy(fw *firewall.Manager, owners *RuleOwners) *TagPolicy {
+	p := &TagPolicy{
+		fw:     fw,
+		owners: owners,
+		blocks: make(map[string]*TagBlock),
+	}
+
+	var saved []TagBlock
+	if err := config.Load(tagBlocksFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load tag blocks: %v", err)
+	}
+	for i := range saved {
+		b := &saved[i]
+		p.blocks[strings.ToLower(b.Tag)] = b
+		owners.Claim(tagOwner(b.Tag), b.Executables)
+		owners.Claim(tagOwner(b.Tag), packageKeys(b.Packages))
+	}
+	return p
+}
+
+// Block adds a tag block and applies it to the discovered apps
+func (p *TagPolicy) Block(tag string, discovered []apps.InstalledApp, packages map[string]bool) error {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return fmt.Errorf("tag is required")
+	}
+
+	p.mu.Lock()
+	key := strings.ToLower(tag)
+	if _, exists := p.blocks[key]; !exists {
+		p.blocks[key] = &TagBlock{Tag: tag, CreatedAt: time.Now()}
+		log.Printf("[Enodia] Blocking tag: %s", tag)
+	}
+	err := p.saveLocked()
+	p.mu.Unlock()
+
+	p.Apply(discovered, packages)
+	return err
+}
+
+// Unblock removes a tag block together with the rules it created, except
+// those another block still holds
+func (p *TagPolicy) Unblock(tag string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.ToLower(strings.TrimSpace(tag))
+	block, exists := p.blocks[key]
+	if !exists {
+		return fmt.Errorf("tag %s is not blocked", tag)
+	}
+	delete(p.blocks, key)
+	owner := tagOwner(block.Tag)
+	if free := p.owners.Release(owner, block.Executables); len(free) > 0 {
+		p.fw.UnblockApps(free)
+	}
+	for _, name := range block.Packages {
+		if free := p.owners.Release(owner, []string{PackageKey(name)}); len(free) > 0 {
+			p.fw.UnblockStoreApp(name)
+		}
+	}
+	log.Printf("[Enodia] Unblocked tag: %s", block.Tag)
+	return p.saveLocked()
+}
+
+// Blocks returns all tag blocks
+func (p *TagPolicy) Blocks() []TagBlock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]TagBlock, 0, len(p.blocks))
+	for _, b := range p.blocks {
+		result = append(result, TagBlock{
+			Tag:         b.Tag,
+			CreatedAt:   b.CreatedAt,
+			Executables: append([]string(nil), b.Executables...),
+			Packages:    append([]string(nil), b.Packages...),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Tag < result[j].Tag })
+	return result
+}
+
+// Apply blocks the apps that carry a blocked tag and are not covered yet.
+// Rules of executables and Store apps that disappeared, or whose app lost
+// the tag, are removed. packages holds the lowercased names of the
+// installed Store packages, listed or hidden; it is nil when the Store
+// could not be listed, and package rules of apps that were not discovered
+// are then left alone.
+func (p *TagPolicy) Apply(discovered []apps.InstalledApp, packages map[string]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.blocks) == 0 {
+		return
+	}
+
+	changed := false
+	for _, block := range p.blocks {
+		// Executables of discovered apps without the tag; those of apps
+		// that were not discovered this time are left alone
+		untagged := make(map[string]bool)
+		tagged := make(map[string]bool)
+		for _, app := range discovered {
+			if app.AppType == "store" {
+				if app.HasTag(block.Tag) {
+					tagged[strings.ToLower(PackageKey(app.Name))] = true
+				} else {
+					untagged[strings.ToLower(PackageKey(app.Name))] = true
+				}
+			}
+			for _, exe := range app.Executables {
+				if app.HasTag(block.Tag) {
+					tagged[strings.ToLower(exe)] = true
+				} else {
+					untagged[strings.ToLower(exe)] = true
+				}
+			}
+		}
+
+		kept := block.Executables[:0]
+		for _, exe := range block.Executables {
+			_, err := os.Stat(exe)
+			if err != nil || (untagged[strings.ToLower(exe)] && !tagged[strings.ToLower(exe)]) {
+				if free := p.owners.Release(tagOwner(block.Tag), []string{exe}); len(free) > 0 {
+					p.fw.UnblockApp(exe)
+				}
+				changed = true
+				continue
+			}
+			kept = append(kept, exe)
+		}
+		block.Executables = kept
+
+		keptPackages := block.Packages[:0]
+		for _, name := range block.Packages {
+			key := strings.ToLower(PackageKey(name))
+			gone := packages != nil && !packages[strings.ToLower(name)]
+			if gone || (untagged[key] && !tagged[key]) {
+				if free := p.owners.Release(tagOwner(block.Tag), []string{PackageKey(name)}); len(free) > 0 {
+					p.fw.UnblockStoreApp(name)
+				}
+				changed = true
+				continue
+			}
+			keptPackages = append(keptPackages, name)
+		}
+		block.Packages = keptPackages
+
+		for _, app := range discovered {
+			if !app.HasTag(block.Tag) {
+				continue
+			}
+			if app.AppType == "store" {
+				if app.PackageSID == "" || containsPath(block.Packages, app.Name) {
+					continue
+				}
+				if err := p.fw.BlockStoreApp(app.PackageSID, app.Name); err != nil {
+					log.Printf("[Enodia] Warning: Could not block %s: %v", app.Name, err)
+					continue
+				}
+				log.Printf("[Enodia] Blocked %s (tagged %s)", app.Name, block.Tag)
+				p.owners.Claim(tagOwner(block.Tag), []string{PackageKey(app.Name)})
+				block.Packages = append(block.Packages, app.Name)
+				changed = true
+				continue
+			}
+			for _, exe := range app.Executables {
+				if containsPath(block.Executables, exe) {
+					continue
+				}
+				if err := p.fw.BlockApp(exe); err != nil {
+					log.Printf("[Enodia] Warning: Could not block %s: %v", exe, err)
+					continue
+				}
+				log.Printf("[Enodia] Blocked %s (tagged %s)", exe, block.Tag)
+				p.owners.Claim(tagOwner(block.Tag), []string{exe})
+				block.Executables = append(block.Executables, exe)
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		if err := p.saveLocked(); err != nil {
+			log.Printf("[Enodia] Warning: Could not save tag blocks: %v", err)
+		}
+	}
+}
+
+// tagOwner names a tag block as the owner of its rules
+func tagOwner(tag string) string {
+	return "tag:" + strings.ToLower(tag)
+}
+
+// packageKeys returns the ownership keys of the rules of Store apps
+func packageKeys(names []string) []string {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, PackageKey(name))
+	}
+	return keys
+}
+
+// saveLocked persists the tag blocks. The caller must hold p.mu.
+func (p *TagPolicy) saveLocked() error {
+	saved := make([]TagBlock, 0, len(p.blocks))
+	for _, b := range p.blocks {
+		saved = append(saved, *b)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Tag < saved[j].Tag })
+	return config.Save(tagBlocksFile, saved)
+}
@@ -0,0 +1,136 @@
+package policy
+
+import (
+	"enodia/internal/apps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fakeRules records the rules a policy creates and removes
+type fakeRules struct {
+	blocked map[string]bool
+}
+
+func newFakeRules() *fakeRules {
+	return &fakeRules{blocked: make(map[string]bool)}
+}
+
+func (f *fakeRules) BlockApp(exePath string) error {
+	f.blocked[exePath] = true
+	return nil
+}
+
+func (f *fakeRules) UnblockApp(exePath string) error {
+	delete(f.blocked, exePath)
+	return nil
+}
+
+func (f *fakeRules) UnblockApps(exePaths []string) map[string]error {
+	results := make(map[string]error)
+	for _, path := range exePaths {
+		results[path] = f.UnblockApp(path)
+	}
+	return results
+}
+
+func (f *fakeRules) BlockStoreApp(packageSID, displayName string) error {
+	f.blocked[PackageKey(displayName)] = true
+	return nil
+}
+
+func (f *fakeRules) UnblockStoreApp(displayName string) error {
+	delete(f.blocked, PackageKey(displayName))
+	return nil
+}
+
+// rules lists what is blocked, in order
+func (f *fakeRules) rules() []string {
+	var rules []string
+	for rule := range f.blocked {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+// useTempConfig points the config directory at a temporary folder
+func useTempConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("AppData", dir)
+	t.Setenv("HOME", dir)
+}
+
+// storeApp makes a discovered Store app with the given tags
+func storeApp(name string, tags ...string) apps.InstalledApp {
+	return apps.InstalledApp{
+		ID:         "store-" + name,
+		Name:       name,
+		AppType:    "store",
+		PackageSID: "S-1-15-2-" + name,
+		Tags:       tags,
+	}
+}
+
+func TestTagPolicyApplyPackages(t *testing.T) {
+	useTempConfig(t)
+	fw := newFakeRules()
+	owners := LoadRuleOwners()
+	p := &TagPolicy{fw: fw, owners: owners, blocks: make(map[string]*TagBlock)}
+
+	exe := filepath.Join(t.TempDir(), "game.exe")
+	if err := os.WriteFile(exe, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	game := apps.InstalledApp{ID: "game", Name: "Game", AppType: "win32", Executables: []string{exe}, Tags: []string{"games"}}
+	discovered := []apps.InstalledApp{game, storeApp("Solitaire", "games"), storeApp("Puzzle", "games"), storeApp("Racer", "games")}
+	installed := map[string]bool{"solitaire": true, "puzzle": true, "racer": true}
+
+	if err := p.Block("Games", discovered, installed); err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+	want := []string{exe, PackageKey("Puzzle"), PackageKey("Racer"), PackageKey("Solitaire")}
+	if got := fw.rules(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rules = %q, want %q", got, want)
+	}
+
+	// The user also blocked Racer by hand
+	owners.Claim(OwnerManual, []string{PackageKey("Racer")})
+
+	// Puzzle loses the tag and Racer is uninstalled; Solitaire is hidden,
+	// so only the package list knows it is still there
+	discovered = []apps.InstalledApp{game, storeApp("Puzzle")}
+	p.Apply(discovered, map[string]bool{"solitaire": true, "puzzle": true})
+
+	want = []string{exe, PackageKey("Racer"), PackageKey("Solitaire")}
+	if got := fw.rules(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rules = %q, want %q", got, want)
+	}
+	if got := p.Blocks()[0].Packages; !reflect.DeepEqual(got, []string{"Solitaire"}) {
+		t.Errorf("block packages = %q, want Solitaire", got)
+	}
+	if got := owners.Owners(PackageKey("Racer")); !reflect.DeepEqual(got, []string{OwnerManual}) {
+		t.Errorf("Racer owners = %q, want the manual block only", got)
+	}
+
+	// Without a package list, packages that were not discovered stay blocked
+	p.Apply([]apps.InstalledApp{game}, nil)
+	if got := p.Blocks()[0].Packages; !reflect.DeepEqual(got, []string{"Solitaire"}) {
+		t.Errorf("block packages without a package list = %q, want Solitaire", got)
+	}
+
+	// A package that is gone is released once the Store can be listed
+	p.Apply([]apps.InstalledApp{game}, map[string]bool{})
+	if got := fw.rules(); !reflect.DeepEqual(got, []string{exe, PackageKey("Racer")}) {
+		t.Errorf("rules = %q, want the game and the manual block", got)
+	}
+
+	// The saved block matches
+	if got := NewTagPolicy(nil, owners).Blocks(); len(got) != 1 || len(got[0].Packages) != 0 || !reflect.DeepEqual(got[0].Executables, []string{exe}) {
+		t.Errorf("saved blocks = %+v", got)
+	}
+}
@@ -0,0 +1,305 @@
+package policy
+
+import (
+	"enodia/internal/apps"
+	"enodia/internal/config"
+	"enodia/internal/firewall"
+	"enodia/internal/pe"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const trackedFile = "tracked.json"
+
+// versionSegment matches versioned folder names like "app-1.2.3" or "v2.0.1"
+var versionSegment = regexp.MustCompile(`^[a-z_-]*v?\d+(\.\d+)+[a-z0-9._-]*$`)
+
+// TrackedExecutable remembers which app a blocked executable belongs to,
+// so the block can follow the app when an update moves the executable
+type TrackedExecutable struct {
+	Path      string `json:"path"`
+	AppID     string `json:"appId"`
+	AppName   string `json:"appName"`
+	Publisher string `json:"publisher"`
+	FileName  string `json:"fileName"`
+	// Version resource fields, which survive renames of the file itself
+	ProductName      string `json:"productName"`
+	OriginalFilename string `json:"originalFilename"`
+	// Version is the file version when the executable was blocked or migrated
+	Version    string    `json:"version,omitempty"`
+	BlockedAt  time.Time `json:"blockedAt"`
+	MigratedAt time.Time `json:"migratedAt,omitempty"`
+}
+
+// Tracker keeps the identity of the executables the user blocked by hand,
+// holds their rules against other blocks and migrates them when discovery
+// finds them at a new path
+type Tracker struct {
+	fw      *firewall.Manager
+	owners  *RuleOwners
+	mu      sync.Mutex
+	tracked map[string]*TrackedExecutable
+}
+
+// NewTracker loads the tracked executables from disk
+func NewTracker(fw *firewall.Manager, owners *RuleOwners) *Tracker {
+	t := &Tracker{
+		fw:      fw,
+		owners:  owners,
+		tracked: make(map[string]*TrackedExecutable),
+	}
+
+	var saved []TrackedExecutable
+	if err := config.Load(trackedFile, &saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not load tracked executables: %v", err)
+	}
+	paths := make([]string, 0, len(saved))
+	for i := range saved {
+		t.tracked[strings.ToLower(saved[i].Path)] = &saved[i]
+		paths = append(paths, saved[i].Path)
+	}
+	owners.Claim(OwnerManual, paths)
+	return t
+}
+
+// Track records that the given executables of app are blocked by hand.
+// The app is empty for executables that belong to no discovered app.
+func (t *Tracker) Track(app apps.InstalledApp, exePaths []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for _, path := range exePaths {
+		te := &TrackedExecutable{
+			Path:      path,
+			AppID:     app.ID,
+			AppName:   app.Name,
+			Publisher: app.Publisher,
+			FileName:  filepath.Base(path),
+			BlockedAt: now,
+		}
+		if info, err := pe.ReadVersionInfo(path); err == nil {
+			te.ProductName = info.ProductName
+			te.OriginalFilename = info.OriginalFilename
+			te.Version = info.FileVersion
+		}
+		t.tracked[strings.ToLower(path)] = te
+	}
+	t.owners.Claim(OwnerManual, exePaths)
+	t.saveLocked()
+}
+
+// Forget stops tracking the given executables and releases the user's
+// hold on their rules; blocks that still hold them are left alone
+func (t *Tracker) Forget(exePaths []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, path := range exePaths {
+		delete(t.tracked, strings.ToLower(path))
+	}
+	t.owners.Release(OwnerManual, exePaths)
+	t.saveLocked()
+}
+
+// Clear stops tracking every executable
+func (t *Tracker) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tracked = make(map[string]*TrackedExecutable)
+	t.saveLocked()
+}
+
+// Migrate moves the rules of tracked executables to the matching
+// executable of the freshly discovered app: when their path no longer
+// exists, or when discovery lists the app's executable at a newer path
+// while the old file is left behind, as Squirrel does with its app-x.y.z
+// folders. The old rule is removed unless another block holds it.
+// It returns the number of migrated executables.
+func (t *Tracker) Migrate(discovered []apps.InstalledApp) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	migrated := 0
+	for key, te := range t.tracked {
+		var moved *apps.Executable
+		if _, err := os.Stat(te.Path); errors.Is(err, os.ErrNotExist) {
+			moved = t.findMoved(te, discovered)
+		} else {
+			moved = t.findUpdated(te, discovered)
+		}
+		if moved == nil {
+			continue
+		}
+		newPath := moved.Path
+
+		if err := t.fw.BlockApp(newPath); err != nil {
+			log.Printf("[Enodia] Warning: Could not migrate block for %s: %v", te.Path, err)
+			continue
+		}
+		t.owners.Claim(OwnerManual, []string{newPath})
+		if free := t.owners.Release(OwnerManual, []string{te.Path}); len(free) > 0 {
+			if err := t.fw.UnblockApp(te.Path); err != nil {
+				log.Printf("[Enodia] Warning: Could not remove old rule for %s: %v", te.Path, err)
+			}
+		}
+		log.Printf("[Enodia] Migrated block for %s: %s -> %s", te.AppName, te.Path, newPath)
+
+		delete(t.tracked, key)
+		te.Path = newPath
+		if moved.Version != "" {
+			te.Version = moved.Version
+		}
+		te.MigratedAt = time.Now()
+		t.tracked[strings.ToLower(newPath)] = te
+		migrated++
+	}
+
+	if migrated > 0 {
+		t.saveLocked()
+	}
+	return migrated
+}
+
+// findMoved looks for the new location of a tracked executable among
+// the executables of the app it belongs to
+func (t *Tracker) findMoved(te *TrackedExecutable, discovered []apps.InstalledApp) *apps.Executable {
+	oldShape := versionlessPath(te.Path)
+
+	var fallback *apps.Executable
+	for i := range discovered {
+		app := &discovered[i]
+		if !sameApp(te, *app) {
+			continue
+		}
+		for j := range app.Binaries {
+			exe := &app.Binaries[j]
+			if !sameExecutable(te, *exe) {
+				continue
+			}
+			if _, taken := t.tracked[strings.ToLower(exe.Path)]; taken {
+				continue
+			}
+			if versionlessPath(exe.Path) == oldShape {
+				return exe
+			}
+			if fallback == nil {
+				fallback = exe
+			}
+		}
+	}
+	return fallback
+}
+
+// findUpdated looks for a newer copy of a tracked executable that still
+// exists: an executable of the same app, by ID, with the same file name
+// in a newer versioned folder or with a newer file version. Nothing is
+// returned while discovery still lists the tracked path.
+func (t *Tracker) findUpdated(te *TrackedExecutable, discovered []apps.InstalledApp) *apps.Executable {
+	if te.AppID == "" {
+		return nil
+	}
+	oldShape := versionlessPath(te.Path)
+
+	for i := range discovered {
+		app := &discovered[i]
+		if app.ID != te.AppID || containsPath(app.Executables, te.Path) {
+			continue
+		}
+		for j := range app.Binaries {
+			exe := &app.Binaries[j]
+			if !strings.EqualFold(filepath.Base(exe.Path), te.FileName) {
+				continue
+			}
+			if _, taken := t.tracked[strings.ToLower(exe.Path)]; taken {
+				continue
+			}
+			newerFolder := versionlessPath(exe.Path) == oldShape &&
+				apps.CompareVersions(folderVersion(exe.Path), folderVersion(te.Path)) > 0
+			newerVersion := te.Version != "" && exe.Version != "" && apps.CompareVersions(exe.Version, te.Version) > 0
+			if newerFolder || newerVersion {
+				return exe
+			}
+		}
+	}
+	return nil
+}
+
+// saveLocked persists the tracked executables. The caller must hold t.mu.
+func (t *Tracker) saveLocked() {
+	saved := make([]TrackedExecutable, 0, len(t.tracked))
+	for _, te := range t.tracked {
+		saved = append(saved, *te)
+	}
+	if err := config.Save(trackedFile, saved); err != nil {
+		log.Printf("[Enodia] Warning: Could not save tracked executables: %v", err)
+	}
+}
+
+// sameApp reports whether a discovered app is the one a tracked executable
+// was blocked for. IDs change with the install path, so it falls back to
+// comparing names without version numbers.
+func sameApp(te *TrackedExecutable, app apps.InstalledApp) bool {
+	if te.AppID == "" && te.AppName == "" {
+		return false
+	}
+	if te.AppID != "" && app.ID == te.AppID {
+		return true
+	}
+	return strings.EqualFold(app.Publisher, te.Publisher) &&
+		versionlessName(app.Name) == versionlessName(te.AppName)
+}
+
+// sameExecutable reports whether a discovered executable is the tracked
+// one, by file name or, for renamed files, by its version resource
+func sameExecutable(te *TrackedExecutable, exe apps.Executable) bool {
+	if strings.EqualFold(filepath.Base(exe.Path), te.FileName) {
+		return true
+	}
+	return te.OriginalFilename != "" &&
+		strings.EqualFold(exe.OriginalFilename, te.OriginalFilename) &&
+		strings.EqualFold(exe.ProductName, te.ProductName)
+}
+
+// versionlessPath lowercases a path and replaces versioned folder names
+// with a placeholder, so "app-1.0.1\x.exe" and "app-1.0.2\x.exe" compare equal
+func versionlessPath(path string) string {
+	segments := strings.Split(strings.ToLower(filepath.Clean(path)), string(filepath.Separator))
+	for i, s := range segments[:len(segments)-1] {
+		if versionSegment.MatchString(s) {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, string(filepath.Separator))
+}
+
+// folderVersion returns the version in the first versioned folder name of
+// a path, such as "1.0.2" for "app-1.0.2\x.exe", or "" when there is none
+func folderVersion(path string) string {
+	segments := strings.Split(strings.ToLower(filepath.Clean(path)), string(filepath.Separator))
+	for _, s := range segments[:len(segments)-1] {
+		if versionSegment.MatchString(s) {
+			return strings.TrimLeft(s, "abcdefghijklmnopqrstuvwxyz_-")
+		}
+	}
+	return ""
+}
+
+// versionlessName drops version numbers from an app display name
+func versionlessName(name string) string {
+	var kept []string
+	for _, word := range strings.Fields(strings.ToLower(name)) {
+		if versionSegment.MatchString(strings.Trim(word, "()")) {
+			continue
+		}
+		kept = append(kept, word)
+	}
+	return strings.Join(kept, " ")
+}
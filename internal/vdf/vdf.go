@@ -0,0 +1,197 @@
+package vdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Node is one key of a Valve KeyValues document (.vdf, .acf). A node has
+// either a string value or child nodes.
+type Node struct {
+	Key      string
+	Value    string
+	Children []*Node
+}
+
+// Child returns the first child with the given key, ignoring case, or nil
+func (n *Node) Child(key string) *Node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if strings.EqualFold(c.Key, key) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Get follows a path of keys and returns the string value at its end, or ""
+func (n *Node) Get(keys ...string) string {
+	for _, key := range keys {
+		n = n.Child(key)
+	}
+	if n == nil {
+		return ""
+	}
+	return n.Value
+}
+
+// Open reads and parses a KeyValues file
+func Open(path string) (*Node, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse decodes a KeyValues document. The returned root node has an empty
+// key and the document's top-level keys as children. Comments and
+// platform conditionals such as [$WIN32] are skipped.
+func Parse(r io.Reader) (*Node, error) {
+	p := &parser{r: bufio.NewReader(r), line: 1}
+	root := &Node{}
+	if err := p.parseChildren(root, false); err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.line, err)
+	}
+	return root, nil
+}
+
+type parser struct {
+	r    *bufio.Reader
+	line int
+}
+
+// parseChildren reads key/value pairs until the closing brace, or until
+// the end of input for the document root
+func (p *parser) parseChildren(parent *Node, nested bool) error {
+	for {
+		tok, quoted, err := p.token()
+		if err == io.EOF {
+			if nested {
+				return errors.New("unexpected end of input")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !quoted && tok == "}" {
+			if !nested {
+				return errors.New("unexpected '}'")
+			}
+			return nil
+		}
+		if !quoted && tok == "{" {
+			return errors.New("unexpected '{'")
+		}
+
+		node := &Node{Key: tok}
+		value, quoted, err := p.token()
+		if err != nil {
+			if err == io.EOF {
+				return errors.New("unexpected end of input")
+			}
+			return err
+		}
+		if !quoted && value == "{" {
+			if err := p.parseChildren(node, true); err != nil {
+				return err
+			}
+		} else {
+			node.Value = value
+		}
+		parent.Children = append(parent.Children, node)
+	}
+}
+
+// token returns the next string, brace or bare word and whether it was quoted
+func (p *parser) token() (string, bool, error) {
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			return "", false, err
+		}
+		switch {
+		case c == '\n':
+			p.line++
+		case c == ' ' || c == '\t' || c == '\r':
+		case c == '/':
+			next, err := p.r.ReadByte()
+			if err != nil || next != '/' {
+				return "", false, errors.New("unexpected '/'")
+			}
+			if _, err := p.r.ReadString('\n'); err != nil && err != io.EOF {
+				return "", false, err
+			}
+			p.line++
+		case c == '[':
+			// Platform conditional such as [$WIN32]
+			if _, err := p.r.ReadString(']'); err != nil {
+				return "", false, errors.New("unterminated conditional")
+			}
+		case c == '{' || c == '}':
+			return string(c), false, nil
+		case c == '"':
+			s, err := p.quoted()
+			return s, true, err
+		default:
+			var b strings.Builder
+			b.WriteByte(c)
+			for {
+				c, err := p.r.ReadByte()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return "", false, err
+				}
+				if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '{' || c == '}' || c == '"' {
+					p.r.UnreadByte()
+					break
+				}
+				b.WriteByte(c)
+			}
+			return b.String(), false, nil
+		}
+	}
+}
+
+// quoted reads the rest of a quoted string, handling backslash escapes
+func (p *parser) quoted() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			return "", errors.New("unterminated string")
+		}
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			next, err := p.r.ReadByte()
+			if err != nil {
+				return "", errors.New("unterminated string")
+			}
+			switch next {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(next)
+			}
+		case '\n':
+			p.line++
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
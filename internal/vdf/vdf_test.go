@@ -0,0 +1,75 @@
+package vdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`// Steam library list
+"libraryfolders"
+{
+	"0"
+	{
+		"path"		"C:\\Program Files (x86)\\Steam"
+		"label"		"Games \"SSD\""
+		"apps" { "440" "123" }
+	}
+	bare	word
+	"multi"		"one
+two"
+	"escapes"	"tab\there\nnew line"
+	"cond"		"windows"	[$WIN32]
+}
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"libraryfolders", "0", "path"}, `C:\Program Files (x86)\Steam`},
+		{[]string{"LibraryFolders", "0", "LABEL"}, `Games "SSD"`},
+		{[]string{"libraryfolders", "0", "apps", "440"}, "123"},
+		{[]string{"libraryfolders", "bare"}, "word"},
+		{[]string{"libraryfolders", "multi"}, "one\ntwo"},
+		{[]string{"libraryfolders", "escapes"}, "tab\there\nnew line"},
+		{[]string{"libraryfolders", "cond"}, "windows"},
+		{[]string{"libraryfolders", "missing", "path"}, ""},
+	}
+	for _, tt := range tests {
+		if got := doc.Get(tt.keys...); got != tt.want {
+			t.Errorf("Get(%q) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+	if n := len(doc.Child("libraryfolders").Children); n != 5 {
+		t.Errorf("libraryfolders has %d children, want 5", n)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"unterminated string", "\"key\"\n\"value", "line 2: unterminated string"},
+		{"escape at the end", `"key" "value\`, "unterminated string"},
+		{"missing brace", "\"key\"\n{\n\"a\" \"b\"\n", "line 4: unexpected end of input"},
+		{"stray brace", `"key" "value" }`, "unexpected '}'"},
+		{"brace as key", `{ "a" "b" }`, "unexpected '{'"},
+		{"key without value", `"key"`, "unexpected end of input"},
+		{"single slash", `"key" / "value"`, "unexpected '/'"},
+		{"unterminated conditional", `"key" "value" [$WIN32`, "unterminated conditional"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
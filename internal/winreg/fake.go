@@ -0,0 +1,380 @@
+package winreg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Fake is an in-memory registry, typically loaded from a fixture that
+// captures the hives of a real machine
+type Fake struct {
+	roots map[Root]*fakeKey
+}
+
+type fakeKey struct {
+	name     string
+	modified time.Time
+	subkeys  map[string]*fakeKey
+	values   map[string]interface{}
+}
+
+// NewFake returns an empty fake registry
+func NewFake() *Fake {
+	return &Fake{roots: make(map[Root]*fakeKey)}
+}
+
+// SetString stores a string value, creating the key if needed
+func (f *Fake) SetString(root Root, path, name, value string) {
+	f.create(root, path).values[strings.ToLower(name)] = value
+}
+
+// SetDWORD stores a DWORD value, creating the key if needed
+func (f *Fake) SetDWORD(root Root, path, name string, value uint32) {
+	f.create(root, path).values[strings.ToLower(name)] = value
+}
+
+// SetModified sets the last write time of a key, creating it if needed
+func (f *Fake) SetModified(root Root, path string, t time.Time) {
+	f.create(root, path).modified = t
+}
+
+// OpenKey implements Reader
+func (f *Fake) OpenKey(root Root, path string) (Key, error) {
+	k, ok := f.roots[root]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return k.OpenSubKey(path)
+}
+
+// create returns the key at path, adding missing keys on the way
+func (f *Fake) create(root Root, path string) *fakeKey {
+	k, ok := f.roots[root]
+	if !ok {
+		k = newFakeKey(root.String())
+		f.roots[root] = k
+	}
+	for _, part := range splitKeyPath(path) {
+		child, ok := k.subkeys[strings.ToLower(part)]
+		if !ok {
+			child = newFakeKey(part)
+			k.subkeys[strings.ToLower(part)] = child
+		}
+		k = child
+	}
+	return k
+}
+
+// remove deletes the key at path together with its subkeys
+func (f *Fake) remove(root Root, path string) {
+	k, ok := f.roots[root]
+	if !ok {
+		return
+	}
+	parts := splitKeyPath(path)
+	if len(parts) == 0 {
+		delete(f.roots, root)
+		return
+	}
+	parent, err := k.open(strings.Join(parts[:len(parts)-1], `\`))
+	if err != nil {
+		return
+	}
+	delete(parent.subkeys, strings.ToLower(parts[len(parts)-1]))
+}
+
+func newFakeKey(name string) *fakeKey {
+	return &fakeKey{
+		name:    name,
+		subkeys: make(map[string]*fakeKey),
+		values:  make(map[string]interface{}),
+	}
+}
+
+func (k *fakeKey) open(path string) (*fakeKey, error) {
+	for _, part := range splitKeyPath(path) {
+		child, ok := k.subkeys[strings.ToLower(part)]
+		if !ok {
+			return nil, ErrNotExist
+		}
+		k = child
+	}
+	return k, nil
+}
+
+func (k *fakeKey) SubKeyNames() ([]string, error) {
+	names := make([]string, 0, len(k.subkeys))
+	for _, child := range k.subkeys {
+		names = append(names, child.name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (k *fakeKey) OpenSubKey(path string) (Key, error) {
+	child, err := k.open(path)
+	if err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+func (k *fakeKey) GetString(name string) (string, error) {
+	v, ok := k.values[strings.ToLower(name)].(string)
+	if !ok {
+		return "", ErrNotExist
+	}
+	return v, nil
+}
+
+func (k *fakeKey) GetDWORD(name string) (uint32, error) {
+	v, ok := k.values[strings.ToLower(name)].(uint32)
+	if !ok {
+		return 0, ErrNotExist
+	}
+	return v, nil
+}
+
+func (k *fakeKey) LastWriteTime() (time.Time, error) {
+	return k.modified, nil
+}
+
+func (k *fakeKey) Close() error {
+	return nil
+}
+
+// splitKeyPath splits a key path into its non-empty parts
+func splitKeyPath(path string) []string {
+	var parts []string
+	for _, p := range strings.Split(path, `\`) {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
+// fixtureKey is one key of a JSON fixture
+type fixtureKey struct {
+	Modified time.Time              `json:"modified"`
+	Values   map[string]interface{} `json:"values"`
+}
+
+// LoadJSON loads a fake registry from a JSON fixture that maps full key
+// paths to their values. Strings become REG_SZ and numbers REG_DWORD:
+//
+//	{
+//	  "HKEY_LOCAL_MACHINE\\SOFTWARE\\...\\Uninstall\\App": {
+//	    "modified": "2024-05-01T10:00:00Z",
+//	    "values": {"DisplayName": "App", "SystemComponent": 1}
+//	  }
+//	}
+func LoadJSON(path string) (*Fake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]fixtureKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	f := NewFake()
+	for full, fk := range keys {
+		root, rest, err := splitPath(full)
+		if err != nil {
+			return nil, err
+		}
+		k := f.create(root, rest)
+		k.modified = fk.Modified
+		for name, v := range fk.Values {
+			switch v := v.(type) {
+			case string:
+				k.values[strings.ToLower(name)] = v
+			case float64:
+				k.values[strings.ToLower(name)] = uint32(v)
+			default:
+				return nil, fmt.Errorf("%s: unsupported value type for %q", full, name)
+			}
+		}
+	}
+	return f, nil
+}
+
+// LoadRegFile loads a fake registry from a regedit export (.reg)
+func LoadRegFile(path string) (*Fake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseReg(bytes.NewReader(data))
+}
+
+// ParseReg parses a regedit export. Both the UTF-16 "Windows Registry
+// Editor Version 5.00" and the ANSI "REGEDIT4" formats are accepted.
+// REG_SZ, REG_EXPAND_SZ and REG_DWORD values are kept; other types are skipped.
+// Keys listed as [-key] are deleted with their subkeys.
+func ParseReg(r io.Reader) (*Fake, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := decodeRegText(data)
+
+	f := NewFake()
+	var current *fakeKey
+	var logical strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// Long hex values continue on the next line after a backslash
+		if strings.HasSuffix(line, `\`) && !strings.HasPrefix(strings.TrimSpace(line), "[") {
+			logical.WriteString(strings.TrimSpace(strings.TrimSuffix(line, `\`)))
+			continue
+		}
+		logical.WriteString(strings.TrimSpace(line))
+		entry := logical.String()
+		logical.Reset()
+
+		switch {
+		case entry == "" || strings.HasPrefix(entry, ";") ||
+			strings.HasPrefix(entry, "Windows Registry Editor") || entry == "REGEDIT4":
+			continue
+		case strings.HasPrefix(entry, "[-"):
+			root, rest, err := splitPath(strings.TrimSuffix(strings.TrimPrefix(entry, "[-"), "]"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			f.remove(root, rest)
+			current = nil
+		case strings.HasPrefix(entry, "["):
+			root, rest, err := splitPath(strings.Trim(entry, "[]"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			current = f.create(root, rest)
+		default:
+			if current == nil {
+				continue
+			}
+			name, value, err := parseRegValue(entry)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if value != nil {
+				current.values[strings.ToLower(name)] = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// decodeRegText converts a .reg file to a string, handling the UTF-16 BOM
+func decodeRegText(data []byte) string {
+	if len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe {
+		return decodeUTF16LE(data[2:])
+	}
+	return strings.TrimPrefix(string(data), "\ufeff")
+}
+
+// parseRegValue parses a `"Name"=value` or `@=value` line. It returns a
+// nil value for types the fake does not model.
+func parseRegValue(entry string) (string, interface{}, error) {
+	var name, rest string
+	if strings.HasPrefix(entry, "@=") {
+		rest = entry[2:]
+	} else {
+		var err error
+		name, rest, err = readQuoted(entry)
+		if err != nil {
+			return "", nil, err
+		}
+		if !strings.HasPrefix(rest, "=") {
+			return "", nil, fmt.Errorf("missing '=' after value name")
+		}
+		rest = rest[1:]
+	}
+
+	switch {
+	case strings.HasPrefix(rest, `"`):
+		s, _, err := readQuoted(rest)
+		return name, s, err
+	case strings.HasPrefix(rest, "dword:"):
+		n, err := strconv.ParseUint(rest[len("dword:"):], 16, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid dword: %w", err)
+		}
+		return name, uint32(n), nil
+	case strings.HasPrefix(rest, "hex(2):"):
+		b, err := parseHexBytes(rest[len("hex(2):"):])
+		if err != nil {
+			return "", nil, err
+		}
+		return name, decodeUTF16LE(b), nil
+	}
+	return name, nil, nil
+}
+
+// readQuoted reads a quoted .reg string with backslash escapes and
+// returns it together with the remaining input
+func readQuoted(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("expected quoted string")
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// parseHexBytes decodes comma-separated hex bytes
+func parseHexBytes(s string) ([]byte, error) {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, ",", ""), " ", "")
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex value: %w", err)
+	}
+	return b, nil
+}
+
+// decodeUTF16LE converts little-endian UTF-16 to a string, dropping trailing NULs
+func decodeUTF16LE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	for len(u) > 0 && u[len(u)-1] == 0 {
+		u = u[:len(u)-1]
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,225 @@
+package winreg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+const uninstallKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`
+
+func TestParseRegValues(t *testing.T) {
+	reg := `Windows Registry Editor Version 5.00
+
+; exported from a test machine
+[HKEY_LOCAL_MACHINE\` + uninstallKey + `\App]
+@="default"
+"DisplayName"="App"
+"Quoted"="say \"hi\""
+"Path"="C:\\Program Files\\App\\app.exe"
+"SystemComponent"=dword:00000001
+"EstimatedSize"=dword:0000fffe
+"InstallLocation"=hex(2):25,00,50,00,72,00,6f,00,67,00,72,00,61,00,6d,00,46,00,\
+  69,00,6c,00,65,00,73,00,25,00,5c,00,41,00,70,00,70,00,00,00
+"Timestamp"=hex(b):00,40,9c,2b,0e,5a,da,01
+"Blob"=hex:01,02,03
+"Multi"=hex(7):61,00,00,00,00,00
+`
+	f, err := ParseReg(strings.NewReader(reg))
+	if err != nil {
+		t.Fatalf("ParseReg: %v", err)
+	}
+	key, err := f.OpenKey(LocalMachine, uninstallKey+`\App`)
+	if err != nil {
+		t.Fatalf("OpenKey: %v", err)
+	}
+
+	strs := []struct {
+		name, want string
+	}{
+		{"", "default"},
+		{"DisplayName", "App"},
+		{"displayname", "App"},
+		{"Quoted", `say "hi"`},
+		{"Path", `C:\Program Files\App\app.exe`},
+		{"InstallLocation", `%ProgramFiles%\App`},
+	}
+	for _, tt := range strs {
+		got, err := key.GetString(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("GetString(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	dwords := []struct {
+		name string
+		want uint32
+	}{
+		{"SystemComponent", 1},
+		{"EstimatedSize", 0xfffe},
+	}
+	for _, tt := range dwords {
+		got, err := key.GetDWORD(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("GetDWORD(%q) = %d, %v; want %d", tt.name, got, err, tt.want)
+		}
+	}
+
+	// Types the fake does not model are skipped
+	for _, name := range []string{"Timestamp", "Blob", "Multi"} {
+		if _, err := key.GetString(name); !errors.Is(err, ErrNotExist) {
+			t.Errorf("GetString(%q) error = %v, want ErrNotExist", name, err)
+		}
+		if _, err := key.GetDWORD(name); !errors.Is(err, ErrNotExist) {
+			t.Errorf("GetDWORD(%q) error = %v, want ErrNotExist", name, err)
+		}
+	}
+	if _, err := key.GetDWORD("DisplayName"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("GetDWORD of a string error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestParseRegDeleteKey(t *testing.T) {
+	reg := `REGEDIT4
+
+[HKEY_LOCAL_MACHINE\` + uninstallKey + `\Kept]
+"DisplayName"="Kept"
+
+[HKEY_LOCAL_MACHINE\` + uninstallKey + `\Gone]
+"DisplayName"="Gone"
+
+[HKEY_LOCAL_MACHINE\` + uninstallKey + `\Gone\Child]
+"DisplayName"="Child"
+
+[-HKEY_LOCAL_MACHINE\` + uninstallKey + `\Gone]
+"Orphan"="dropped"
+
+[-HKEY_LOCAL_MACHINE\` + uninstallKey + `\NeverThere]
+
+[-HKEY_CURRENT_USER\Software]
+`
+	f, err := ParseReg(strings.NewReader(reg))
+	if err != nil {
+		t.Fatalf("ParseReg: %v", err)
+	}
+
+	if _, err := f.OpenKey(LocalMachine, uninstallKey+`\Gone`); !errors.Is(err, ErrNotExist) {
+		t.Errorf("deleted key still opens, error = %v", err)
+	}
+	if _, err := f.OpenKey(LocalMachine, uninstallKey+`\Gone\Child`); !errors.Is(err, ErrNotExist) {
+		t.Errorf("subkey of deleted key still opens, error = %v", err)
+	}
+
+	parent, err := f.OpenKey(LocalMachine, uninstallKey)
+	if err != nil {
+		t.Fatalf("OpenKey: %v", err)
+	}
+	names, _ := parent.SubKeyNames()
+	if len(names) != 1 || names[0] != "Kept" {
+		t.Errorf("SubKeyNames = %v, want [Kept]", names)
+	}
+}
+
+func TestParseRegUTF16(t *testing.T) {
+	text := "Windows Registry Editor Version 5.00\r\n\r\n[HKEY_CURRENT_USER\\Software\\Ünïcode]\r\n\"Name\"=\"Grüße\"\r\n"
+	data := []byte{0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(text)) {
+		data = append(data, byte(u), byte(u>>8))
+	}
+
+	f, err := ParseReg(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("ParseReg: %v", err)
+	}
+	key, err := f.OpenKey(CurrentUser, `Software\ünïcode`)
+	if err != nil {
+		t.Fatalf("OpenKey: %v", err)
+	}
+	if got, _ := key.GetString("Name"); got != "Grüße" {
+		t.Errorf("GetString = %q, want %q", got, "Grüße")
+	}
+}
+
+func TestParseRegErrors(t *testing.T) {
+	tests := []struct {
+		name, reg string
+	}{
+		{"unknown root", "[HKEY_NOWHERE\\Software]\n"},
+		{"unknown root in delete", "[-HKEY_NOWHERE\\Software]\n"},
+		{"bad dword", "[HKEY_CURRENT_USER\\Software]\n\"N\"=dword:xyz\n"},
+		{"bad hex", "[HKEY_CURRENT_USER\\Software]\n\"N\"=hex(2):zz,00\n"},
+		{"unterminated string", "[HKEY_CURRENT_USER\\Software]\n\"N\"=\"open\n"},
+		{"missing equals", "[HKEY_CURRENT_USER\\Software]\n\"N\" \"v\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseReg(strings.NewReader(tt.reg)); err == nil {
+				t.Error("ParseReg succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hive.json")
+	fixture := `{
+  "HKEY_LOCAL_MACHINE\\` + strings.ReplaceAll(uninstallKey, `\`, `\\`) + `\\App": {
+    "modified": "2024-05-01T10:00:00Z",
+    "values": {"DisplayName": "App", "SystemComponent": 1, "EstimatedSize": 2048}
+  },
+  "HKU\\S-1-5-21-1\\Software": {"values": {}}
+}`
+	if err := os.WriteFile(path, []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := LoadJSON(path)
+	if err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	key, err := f.OpenKey(LocalMachine, uninstallKey+`\app`)
+	if err != nil {
+		t.Fatalf("OpenKey: %v", err)
+	}
+	if got, _ := key.GetString("DisplayName"); got != "App" {
+		t.Errorf("DisplayName = %q, want App", got)
+	}
+	if got, _ := key.GetDWORD("SystemComponent"); got != 1 {
+		t.Errorf("SystemComponent = %d, want 1", got)
+	}
+	if got, _ := key.GetDWORD("EstimatedSize"); got != 2048 {
+		t.Errorf("EstimatedSize = %d, want 2048", got)
+	}
+	want := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	if got, _ := key.LastWriteTime(); !got.Equal(want) {
+		t.Errorf("LastWriteTime = %v, want %v", got, want)
+	}
+	if _, err := f.OpenKey(Users, `S-1-5-21-1\Software`); err != nil {
+		t.Errorf("HKU alias: %v", err)
+	}
+}
+
+func TestLoadJSONErrors(t *testing.T) {
+	tests := []struct {
+		name, fixture string
+	}{
+		{"unknown root", `{"HKEY_NOWHERE\\Software": {"values": {}}}`},
+		{"unsupported value", `{"HKCU\\Software": {"values": {"Flags": [1, 2]}}}`},
+		{"malformed", `{"HKCU\\Software": `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hive.json")
+			if err := os.WriteFile(path, []byte(tt.fixture), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadJSON(path); err == nil {
+				t.Error("LoadJSON succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package winreg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned for missing keys and values
+var ErrNotExist = errors.New("registry key or value does not exist")
+
+// Root identifies a predefined registry hive
+type Root int
+
+const (
+	LocalMachine Root = iota
+	CurrentUser
+	Users
+	ClassesRoot
+)
+
+// rootNames maps hive names, as used in .reg exports, to roots
+var rootNames = map[string]Root{
+	"HKEY_LOCAL_MACHINE": LocalMachine,
+	"HKLM":               LocalMachine,
+	"HKEY_CURRENT_USER":  CurrentUser,
+	"HKCU":               CurrentUser,
+	"HKEY_USERS":         Users,
+	"HKU":                Users,
+	"HKEY_CLASSES_ROOT":  ClassesRoot,
+	"HKCR":               ClassesRoot,
+}
+
+// String returns the full hive name
+func (r Root) String() string {
+	switch r {
+	case LocalMachine:
+		return "HKEY_LOCAL_MACHINE"
+	case CurrentUser:
+		return "HKEY_CURRENT_USER"
+	case Users:
+		return "HKEY_USERS"
+	case ClassesRoot:
+		return "HKEY_CLASSES_ROOT"
+	}
+	return fmt.Sprintf("Root(%d)", int(r))
+}
+
+// Key is an open registry key
+type Key interface {
+	// SubKeyNames lists the names of the direct subkeys
+	SubKeyNames() ([]string, error)
+	// OpenSubKey opens a key relative to this one
+	OpenSubKey(path string) (Key, error)
+	// GetString reads a REG_SZ or REG_EXPAND_SZ value without expanding it
+	GetString(name string) (string, error)
+	// GetDWORD reads a REG_DWORD value
+	GetDWORD(name string) (uint32, error)
+	// LastWriteTime reports when the key or one of its values last changed
+	LastWriteTime() (time.Time, error)
+	Close() error
+}
+
+// Reader opens keys below the predefined roots
+type Reader interface {
+	OpenKey(root Root, path string) (Key, error)
+}
+
+// splitPath splits a full key path like `HKEY_LOCAL_MACHINE\SOFTWARE\X`
+// into its root and the path below it
+func splitPath(full string) (Root, string, error) {
+	name, rest, _ := strings.Cut(strings.Trim(full, `\`), `\`)
+	root, ok := rootNames[strings.ToUpper(name)]
+	if !ok {
+		return 0, "", fmt.Errorf("unknown registry root %q", name)
+	}
+	return root, rest, nil
+}
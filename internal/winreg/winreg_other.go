@@ -0,0 +1,8 @@
+//go:build !windows
+
+package winreg
+
+// System returns an empty registry on platforms without one
+func System() Reader {
+	return NewFake()
+}
@@ -0,0 +1,84 @@
+package winreg
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// System returns a Reader for the live Windows registry
+func System() Reader {
+	return systemReader{}
+}
+
+type systemReader struct{}
+
+func (systemReader) OpenKey(root Root, path string) (Key, error) {
+	var base registry.Key
+	switch root {
+	case LocalMachine:
+		base = registry.LOCAL_MACHINE
+	case CurrentUser:
+		base = registry.CURRENT_USER
+	case Users:
+		base = registry.USERS
+	case ClassesRoot:
+		base = registry.CLASSES_ROOT
+	default:
+		return nil, ErrNotExist
+	}
+	return openSystemKey(base, path)
+}
+
+// systemKey wraps an open registry.Key
+type systemKey struct {
+	key registry.Key
+}
+
+func openSystemKey(parent registry.Key, path string) (Key, error) {
+	k, err := registry.OpenKey(parent, path, registry.READ)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &systemKey{key: k}, nil
+}
+
+func (k *systemKey) SubKeyNames() ([]string, error) {
+	names, err := k.key.ReadSubKeyNames(-1)
+	return names, mapError(err)
+}
+
+func (k *systemKey) OpenSubKey(path string) (Key, error) {
+	return openSystemKey(k.key, path)
+}
+
+func (k *systemKey) GetString(name string) (string, error) {
+	v, _, err := k.key.GetStringValue(name)
+	return v, mapError(err)
+}
+
+func (k *systemKey) GetDWORD(name string) (uint32, error) {
+	v, _, err := k.key.GetIntegerValue(name)
+	return uint32(v), mapError(err)
+}
+
+func (k *systemKey) LastWriteTime() (time.Time, error) {
+	info, err := k.key.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (k *systemKey) Close() error {
+	return k.key.Close()
+}
+
+// mapError translates missing keys and values to ErrNotExist
+func mapError(err error) error {
+	if errors.Is(err, registry.ErrNotExist) {
+		return ErrNotExist
+	}
+	return err
+}
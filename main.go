@@ -2,6 +2,10 @@ package main
 
 import (
 	"embed"
+	"enodia/internal/firewall"
+	"enodia/internal/policy"
+	"log"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,6 +16,14 @@ import (
 var assets embed.FS
 
 func main() {
+	// The uninstaller runs Enodia with --purge-rules to remove its
+	// firewall rules and blocks without opening the window
+	for _, arg := range os.Args[1:] {
+		if arg == "--purge-rules" {
+			os.Exit(purgeRules())
+		}
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -25,6 +37,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
@@ -34,3 +47,20 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+// purgeRules removes every Enodia firewall rule and the blocks that would
+// recreate them, and returns the exit code
+func purgeRules() int {
+	fw := firewall.NewManager()
+	defer fw.Close()
+
+	if _, err := fw.PurgeRules(); err != nil {
+		log.Printf("[Enodia] Warning: Could not purge rules: %v", err)
+		return 1
+	}
+	if err := policy.RemoveState(); err != nil {
+		log.Printf("[Enodia] Warning: Could not remove blocks: %v", err)
+		return 1
+	}
+	return 0
+}
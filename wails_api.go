@@ -3,18 +3,159 @@ package main
 import (
 	"enodia/internal/apps"
 	"enodia/internal/firewall"
+	"enodia/internal/policy"
 	"fmt"
+	"log"
+	"strings"
 )
 
 // GetInstalledApps returns all discovered applications
 func (a *App) GetInstalledApps() []apps.InstalledApp {
-	return a.installedApps
+	return a.discoveredApps()
 }
 
-// RefreshApps re-discovers applications
+// RefreshApps re-discovers applications. If the refresh is cancelled, the
+// apps of the previous discovery are returned.
 func (a *App) RefreshApps() []apps.InstalledApp {
-	a.installedApps = apps.DiscoverApps()
-	return a.installedApps
+	found, err := a.discover()
+	if err != nil {
+		return a.discoveredApps()
+	}
+	return found
+}
+
+// CancelDiscovery stops a running discovery
+func (a *App) CancelDiscovery() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cancelDiscovery != nil {
+		a.cancelDiscovery()
+	}
+}
+
+// GetFilterRules returns the rules that hide apps from discovery
+func (a *App) GetFilterRules() []apps.FilterRule {
+	return a.filters.Rules()
+}
+
+// SetFilterRules replaces the discovery filter rules and re-discovers
+// applications with them
+func (a *App) SetFilterRules(rules []apps.FilterRule) string {
+	if err := a.filters.Set(rules); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	go a.discover()
+	return "Updated"
+}
+
+// ResetFilterRules restores the default discovery filter rules
+func (a *App) ResetFilterRules() string {
+	if err := a.filters.Reset(); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	go a.discover()
+	return "Updated"
+}
+
+// GetHiddenApps returns the apps the filter rules hid in the last
+// discovery, with the rule that hid each
+func (a *App) GetHiddenApps() []apps.HiddenApp {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.hiddenApps == nil {
+		return []apps.HiddenApp{}
+	}
+	return a.hiddenApps
+}
+
+// GetPortableRoots returns the folders scanned for portable apps
+func (a *App) GetPortableRoots() []apps.ScanRoot {
+	roots := a.portable.Roots()
+	if roots == nil {
+		return []apps.ScanRoot{}
+	}
+	return roots
+}
+
+// SetPortableRoots replaces the folders scanned for portable apps and
+// re-discovers applications. An empty list turns the scan off.
+func (a *App) SetPortableRoots(roots []apps.ScanRoot) string {
+	if err := a.portable.Set(roots); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	go a.discover()
+	return "Updated"
+}
+
+// GetDiscoverySources returns every discovery source, in the order they
+// are merged, with its capabilities and whether it is enabled
+func (a *App) GetDiscoverySources() []apps.SourceInfo {
+	return a.sources.Sources()
+}
+
+// SetDiscoverySources enables, disables and orders the discovery sources
+// and re-discovers applications
+func (a *App) SetDiscoverySources(settings []apps.SourceSetting) string {
+	if err := a.sources.Set(settings); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	go a.discover()
+	return "Updated"
+}
+
+// GetSourceReports returns how each source did in the last discovery:
+// how many apps it found, how long it took and why it failed, if it did
+func (a *App) GetSourceReports() []apps.SourceReport {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.sourceReports == nil {
+		return []apps.SourceReport{}
+	}
+	return a.sourceReports
+}
+
+// GetApp returns the discovered app with the given ID
+func (a *App) GetApp(id string) (apps.InstalledApp, error) {
+	app, ok := a.catalog.ByID(id)
+	if !ok {
+		return apps.InstalledApp{}, fmt.Errorf("unknown app %q", id)
+	}
+	return app, nil
+}
+
+// GetCatalogVersion returns the version of the app list, which changes
+// with every discovery and every executable kind override
+func (a *App) GetCatalogVersion() uint64 {
+	return a.catalog.Version()
+}
+
+// trackBlocked records executables blocked by hand, so other blocks leave
+// their rules alone and the rules survive app updates
+func (a *App) trackBlocked(paths []string) {
+	for _, path := range paths {
+		app, _ := a.catalog.ForExecutable(path)
+		a.tracker.Track(app, []string{path})
+	}
+}
+
+// splitHeld separates the paths whose rules only the user holds, which a
+// manual unblock removes, from those a folder, publisher, tag or review
+// block still holds, with what holds them
+func (a *App) splitHeld(paths []string) (free []string, held map[string][]string) {
+	held = make(map[string][]string)
+	for _, path := range paths {
+		if others := a.owners.Others(policy.OwnerManual, path); len(others) > 0 {
+			held[path] = others
+		} else {
+			free = append(free, path)
+		}
+	}
+	return free, held
+}
+
+// heldMessage tells why a rule was kept on a manual unblock
+func heldMessage(owners []string) string {
+	return "Error: Still blocked by " + policy.DescribeOwners(owners)
 }
 
 // BlockFile blocks a single executable
@@ -25,6 +166,7 @@ func (a *App) BlockFile(path string) string {
 	if err := a.fw.BlockApp(path); err != nil {
 		return fmt.Sprintf("Error: %v", err)
 	}
+	a.trackBlocked([]string{path})
 	return "Blocked"
 }
 
@@ -33,9 +175,13 @@ func (a *App) UnblockFile(path string) string {
 	if a.fw == nil {
 		return "Error: Firewall not available"
 	}
+	if others := a.owners.Others(policy.OwnerManual, path); len(others) > 0 {
+		return heldMessage(others)
+	}
 	if err := a.fw.UnblockApp(path); err != nil {
 		return fmt.Sprintf("Error: %v", err)
 	}
+	a.tracker.Forget([]string{path})
 	return "Unblocked"
 }
 
@@ -48,13 +194,16 @@ func (a *App) BlockFiles(paths []string) map[string]string {
 		}
 		return result
 	}
+	var blocked []string
 	for path, err := range a.fw.BlockApps(paths) {
 		if err != nil {
 			result[path] = fmt.Sprintf("Error: %v", err)
 		} else {
 			result[path] = "Blocked"
+			blocked = append(blocked, path)
 		}
 	}
+	a.trackBlocked(blocked)
 	return result
 }
 
@@ -67,25 +216,37 @@ func (a *App) UnblockFiles(paths []string) map[string]string {
 		}
 		return result
 	}
-	for path, err := range a.fw.UnblockApps(paths) {
+	free, held := a.splitHeld(paths)
+	for path, owners := range held {
+		result[path] = heldMessage(owners)
+	}
+	var unblocked []string
+	for path, err := range a.fw.UnblockApps(free) {
 		if err != nil {
 			result[path] = fmt.Sprintf("Error: %v", err)
 		} else {
 			result[path] = "Unblocked"
+			unblocked = append(unblocked, path)
 		}
 	}
+	a.tracker.Forget(unblocked)
 	return result
 }
 
 // BlockInstalledApp blocks an app based on its type (Win32 or Store)
-func (a *App) BlockInstalledApp(app apps.InstalledApp) string {
+func (a *App) BlockInstalledApp(id string) string {
 	if a.fw == nil {
 		return "Error: Firewall not available"
 	}
+	app, ok := a.catalog.ByID(id)
+	if !ok {
+		return fmt.Sprintf("Error: unknown app %q", id)
+	}
 	if app.AppType == "store" && app.PackageSID != "" {
 		if err := a.fw.BlockStoreApp(app.PackageSID, app.Name); err != nil {
 			return fmt.Sprintf("Error: %v", err)
 		}
+		a.owners.Claim(policy.OwnerManual, []string{policy.PackageKey(app.Name)})
 		return "Blocked"
 	}
 	if len(app.Executables) == 0 {
@@ -96,29 +257,137 @@ func (a *App) BlockInstalledApp(app apps.InstalledApp) string {
 			return fmt.Sprintf("Error: %v", err)
 		}
 	}
+	a.tracker.Track(app, app.Executables)
 	return "Blocked"
 }
 
 // UnblockInstalledApp unblocks an app based on its type
-func (a *App) UnblockInstalledApp(app apps.InstalledApp) string {
+func (a *App) UnblockInstalledApp(id string) string {
 	if a.fw == nil {
 		return "Error: Firewall not available"
 	}
+	app, ok := a.catalog.ByID(id)
+	if !ok {
+		return fmt.Sprintf("Error: unknown app %q", id)
+	}
 	if app.AppType == "store" {
+		key := policy.PackageKey(app.Name)
+		if others := a.owners.Others(policy.OwnerManual, key); len(others) > 0 {
+			return heldMessage(others)
+		}
 		if err := a.fw.UnblockStoreApp(app.Name); err != nil {
 			return fmt.Sprintf("Error: %v", err)
 		}
+		a.owners.Release(policy.OwnerManual, []string{key})
 		return "Unblocked"
 	}
 	if len(app.Executables) == 0 {
 		return "No executables to unblock"
 	}
-	for _, err := range a.fw.UnblockApps(app.Executables) {
+	free, held := a.splitHeld(app.Executables)
+	if err := a.unblockManual(free); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if len(held) > 0 {
+		return heldMessage(heldOwners(held))
+	}
+	return "Unblocked"
+}
+
+// BlockOnlyKinds blocks the app's executables of the given kinds, such as
+// "updater", and lifts Enodia blocks on its other executables
+func (a *App) BlockOnlyKinds(id string, kinds []string) string {
+	app, ok := a.catalog.ByID(id)
+	if !ok {
+		return fmt.Sprintf("Error: unknown app %q", id)
+	}
+	return a.blockSelection(app, app.ExecutablesOfKind(kinds...), app.ExecutablesExcept(kinds...))
+}
+
+// BlockAllExceptKinds blocks the app's executables except those of the
+// given kinds, such as "uninstaller", and lifts blocks on those
+func (a *App) BlockAllExceptKinds(id string, kinds []string) string {
+	app, ok := a.catalog.ByID(id)
+	if !ok {
+		return fmt.Sprintf("Error: unknown app %q", id)
+	}
+	return a.blockSelection(app, app.ExecutablesExcept(kinds...), app.ExecutablesOfKind(kinds...))
+}
+
+// blockSelection blocks one part of an app's executables and unblocks the rest
+func (a *App) blockSelection(app apps.InstalledApp, block, unblock []string) string {
+	if a.fw == nil {
+		return "Error: Firewall not available"
+	}
+	if len(block) == 0 {
+		return "No executables to block"
+	}
+	for _, err := range a.fw.BlockApps(block) {
 		if err != nil {
 			return fmt.Sprintf("Error: %v", err)
 		}
 	}
-	return "Unblocked"
+	a.tracker.Track(app, block)
+	// Executables other blocks hold stay blocked
+	free, _ := a.splitHeld(unblock)
+	if err := a.unblockManual(free); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Blocked"
+}
+
+// unblockManual removes the rules of executables only the user holds and
+// stops tracking those it removed
+func (a *App) unblockManual(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	var unblocked []string
+	var firstErr error
+	for path, err := range a.fw.UnblockApps(paths) {
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		unblocked = append(unblocked, path)
+	}
+	a.tracker.Forget(unblocked)
+	return firstErr
+}
+
+// heldOwners collects what holds the kept rules of several paths
+func heldOwners(held map[string][]string) []string {
+	seen := make(map[string]bool)
+	var owners []string
+	for _, others := range held {
+		for _, owner := range others {
+			if !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+	return owners
+}
+
+// SetExecutableKind overrides the detected kind of an executable
+func (a *App) SetExecutableKind(path, kind string) string {
+	if err := a.kinds.Set(path, kind); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	a.catalog.Update(a.kinds.Apply)
+	return "Updated"
+}
+
+// ResetExecutableKind drops the override of an executable's kind
+func (a *App) ResetExecutableKind(path string) string {
+	if err := a.kinds.Clear(path); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	a.catalog.Update(a.kinds.Apply)
+	return "Updated"
 }
 
 // GetBlockedApps returns all apps with Enodia firewall rules
@@ -129,3 +398,306 @@ func (a *App) GetBlockedApps() []firewall.BlockedApp {
 	blocked, _ := a.fw.GetBlockedApps()
 	return blocked
 }
+
+// GetAppStatus returns every discovered app with its block state, and
+// the blocked executables and packages that belong to no app as orphans
+func (a *App) GetAppStatus() policy.StatusView {
+	return policy.JoinStatus(a.discoveredApps(), a.GetBlockedApps())
+}
+
+// GetChangeFeed returns the apps installed, updated and removed between
+// discoveries, newest first
+func (a *App) GetChangeFeed() []apps.Change {
+	return a.changes.Changes()
+}
+
+// ClearChangeFeed empties the change feed
+func (a *App) ClearChangeFeed() string {
+	if err := a.changes.Clear(); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Cleared"
+}
+
+// GetAutoBlockSettings returns the block applied to newly installed apps
+func (a *App) GetAutoBlockSettings() policy.AutoBlockSettings {
+	return a.autoBlock.Settings()
+}
+
+// SetAutoBlockSettings turns the auto-block of newly installed apps on
+// or off and sets which executables it blocks
+func (a *App) SetAutoBlockSettings(settings policy.AutoBlockSettings) string {
+	if err := a.autoBlock.SetSettings(settings); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Updated"
+}
+
+// GetReviewQueue returns the newly installed apps that were blocked
+// automatically and wait for review
+func (a *App) GetReviewQueue() []policy.ReviewItem {
+	return a.autoBlock.Queue()
+}
+
+// ApproveApp lifts the auto-block of a queued app
+func (a *App) ApproveApp(id string) string {
+	if err := a.autoBlock.Approve(id); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Unblocked"
+}
+
+// RejectApp keeps the auto-block of a queued app, which is then tracked
+// like any other block so it survives updates
+func (a *App) RejectApp(id string) string {
+	item, err := a.autoBlock.Reject(id)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if app, ok := a.catalog.ByID(id); ok && len(item.Executables) > 0 {
+		a.tracker.Track(app, item.Executables)
+	} else {
+		a.trackBlocked(item.Executables)
+	}
+	return "Blocked"
+}
+
+// GetTags returns the user tags and automatic categories of the
+// discovered apps, with how many apps carry each
+func (a *App) GetTags() []apps.TagSummary {
+	return apps.SummarizeTags(a.discoveredApps())
+}
+
+// SetAppTags replaces the user tags of an app
+func (a *App) SetAppTags(id string, tags []string) string {
+	if err := a.tags.Set(id, tags); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	a.retag()
+	return "Updated"
+}
+
+// TagApps puts a tag on several apps
+func (a *App) TagApps(tag string, ids []string) string {
+	if err := a.tags.Add(tag, ids); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	a.retag()
+	return "Updated"
+}
+
+// UntagApps takes a tag off several apps
+func (a *App) UntagApps(tag string, ids []string) string {
+	if err := a.tags.Remove(tag, ids); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	a.retag()
+	return "Updated"
+}
+
+// retag applies changed tags to the discovered apps and their tag blocks
+func (a *App) retag() {
+	a.catalog.Update(a.tags.Apply)
+	_, packages := a.installedPackages()
+	a.tagBlocks.Apply(a.discoveredApps(), packages)
+}
+
+// BlockTag blocks every app with a tag or category, such as "games" or
+// "publisher:Valve", including apps that get it later
+func (a *App) BlockTag(tag string) string {
+	if a.tagBlocks == nil {
+		return "Error: Firewall not available"
+	}
+	_, packages := a.installedPackages()
+	if err := a.tagBlocks.Block(tag, a.discoveredApps(), packages); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Blocked"
+}
+
+// UnblockTag removes a tag block and its rules
+func (a *App) UnblockTag(tag string) string {
+	if a.tagBlocks == nil {
+		return "Error: Firewall not available"
+	}
+	if err := a.tagBlocks.Unblock(tag); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Unblocked"
+}
+
+// GetTagBlocks returns all tag blocks
+func (a *App) GetTagBlocks() []policy.TagBlock {
+	if a.tagBlocks == nil {
+		return []policy.TagBlock{}
+	}
+	return a.tagBlocks.Blocks()
+}
+
+// GetOrphanedRules returns the Enodia rules whose executable no longer
+// exists or whose package is no longer installed
+func (a *App) GetOrphanedRules() []policy.OrphanedRule {
+	if a.fw == nil {
+		return []policy.OrphanedRule{}
+	}
+	rules, err := a.fw.ListRules()
+	if err != nil {
+		log.Printf("[Enodia] Warning: Could not list rules: %v", err)
+		return []policy.OrphanedRule{}
+	}
+	sids, _ := a.installedPackages()
+	return policy.FindOrphanedRules(rules, sids)
+}
+
+// RemoveOrphanedRules removes the given orphaned rules by name. Rules
+// that are not orphaned are left alone.
+func (a *App) RemoveOrphanedRules(names []string) map[string]string {
+	result := make(map[string]string)
+	if a.fw == nil {
+		for _, name := range names {
+			result[name] = "Error: Firewall not available"
+		}
+		return result
+	}
+
+	orphans := make(map[string]policy.OrphanedRule)
+	for _, o := range a.GetOrphanedRules() {
+		orphans[o.Name] = o
+	}
+	// Owners are forgotten once every orphaned rule of a path is gone, not
+	// when only one direction's rule is removed
+	remaining := make(map[string]int)
+	for _, o := range orphans {
+		if key := orphanKey(o); key != "" {
+			remaining[key]++
+		}
+	}
+	var remove []string
+	for _, name := range names {
+		if _, ok := orphans[name]; !ok {
+			result[name] = "Error: Not an orphaned rule"
+			continue
+		}
+		remove = append(remove, name)
+	}
+
+	var gone []string
+	for name, err := range a.fw.RemoveRules(remove) {
+		if err != nil {
+			result[name] = fmt.Sprintf("Error: %v", err)
+			continue
+		}
+		result[name] = "Removed"
+		if key := orphanKey(orphans[name]); key != "" {
+			if remaining[key]--; remaining[key] == 0 {
+				gone = append(gone, key)
+			}
+		}
+	}
+	a.tracker.Forget(gone)
+	a.owners.Drop(gone)
+	return result
+}
+
+// orphanKey returns the ownership key of an orphaned rule: its executable
+// path, or for package rules the part of the name after the direction
+func orphanKey(o policy.OrphanedRule) string {
+	switch {
+	case o.AppPath != "":
+		return o.AppPath
+	case o.PackageSID != "":
+		return strings.TrimPrefix(strings.TrimPrefix(o.Name, firewall.RULE_PREFIX_OUT), firewall.RULE_PREFIX_IN)
+	}
+	return ""
+}
+
+// PurgeAllRules removes every rule Enodia created, together with the
+// folder, publisher and tag blocks and tracked executables that would bring
+// rules back
+func (a *App) PurgeAllRules() string {
+	if a.fw == nil {
+		return "Error: Firewall not available"
+	}
+	for _, f := range a.folders.BlockedFolders() {
+		if err := a.folders.UnblockFolder(f.Path); err != nil {
+			log.Printf("[Enodia] Warning: Could not unblock folder %s: %v", f.Path, err)
+		}
+	}
+	for _, b := range a.publishers.Blocks() {
+		if err := a.publishers.Unblock(b.Signer); err != nil {
+			log.Printf("[Enodia] Warning: Could not unblock publisher %s: %v", b.Signer, err)
+		}
+	}
+	for _, b := range a.tagBlocks.Blocks() {
+		if err := a.tagBlocks.Unblock(b.Tag); err != nil {
+			log.Printf("[Enodia] Warning: Could not unblock tag %s: %v", b.Tag, err)
+		}
+	}
+	a.autoBlock.Clear()
+	a.tracker.Clear()
+	a.owners.Clear()
+	if _, err := a.fw.PurgeRules(); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Purged"
+}
+
+// BlockFolder blocks every executable under a folder, including ones added later
+func (a *App) BlockFolder(path string) string {
+	if a.folders == nil {
+		return "Error: Firewall not available"
+	}
+	if err := a.folders.BlockFolder(path); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Blocked"
+}
+
+// UnblockFolder lifts a folder block and removes its rules
+func (a *App) UnblockFolder(path string) string {
+	if a.folders == nil {
+		return "Error: Firewall not available"
+	}
+	if err := a.folders.UnblockFolder(path); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Unblocked"
+}
+
+// GetBlockedFolders returns all folders blocked as a whole
+func (a *App) GetBlockedFolders() []policy.BlockedFolder {
+	if a.folders == nil {
+		return []policy.BlockedFolder{}
+	}
+	return a.folders.BlockedFolders()
+}
+
+// BlockPublisher blocks every current and future executable signed by a signer
+func (a *App) BlockPublisher(signer string) string {
+	if a.publishers == nil {
+		return "Error: Firewall not available"
+	}
+	if err := a.publishers.Block(signer, a.discoveredApps()); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Blocked"
+}
+
+// UnblockPublisher removes a publisher block and its rules
+func (a *App) UnblockPublisher(signer string) string {
+	if a.publishers == nil {
+		return "Error: Firewall not available"
+	}
+	if err := a.publishers.Unblock(signer); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return "Unblocked"
+}
+
+// GetBlockedPublishers returns all publisher blocks
+func (a *App) GetBlockedPublishers() []policy.PublisherBlock {
+	if a.publishers == nil {
+		return []policy.PublisherBlock{}
+	}
+	return a.publishers.Blocks()
+}